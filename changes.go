@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// maxCompareFiles ... the maximum number of files GitHub includes when
+// comparing two commits, comparisons at the limit may be incomplete
+const maxCompareFiles = 300
+
+// comparison ... used internally to represent the response body
+// when comparing two commits using the GitHub REST API
+// https://docs.github.com/en/rest/reference/repos#compare-two-commits
+type comparison struct {
+	Files []struct {
+		Filename         string `json:"filename"`
+		PreviousFilename string `json:"previous_filename"`
+	} `json:"files"`
+}
+
+// changedFiles ... returns the names of the files changed between the base and
+// head commits of the project, renamed files are returned by both names, the
+// second return value is false if GitHub truncated the list of files
+// https://docs.github.com/en/rest/reference/repos#compare-two-commits
+func (v *githubClient) changedFiles(project *circleci.Project, base string, head string) ([]string, bool, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/compare/%s...%s", v.baseURL, project.Username, project.Reponame, url.PathEscape(base), url.PathEscape(head))
+	resp, err := v.do("GET", u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("non-success status code returned %s", resp.Status)
+	}
+	var c comparison
+	err = json.NewDecoder(resp.Body).Decode(&c)
+	if err != nil {
+		return nil, false, err
+	}
+	var files []string
+	for _, f := range c.Files {
+		files = append(files, f.Filename)
+		if len(f.PreviousFilename) > 0 {
+			files = append(files, f.PreviousFilename)
+		}
+	}
+	return files, len(c.Files) < maxCompareFiles, nil
+}
+
+// matchPaths ... returns true if any of the files matches any of the patterns,
+// patterns use the syntax of path.Match, a pattern matching a directory
+// matches every file beneath that directory
+func matchPaths(patterns []string, files []string) (bool, error) {
+	for _, f := range files {
+		for dir := f; dir != "." && dir != "/"; dir = path.Dir(dir) {
+			for _, p := range patterns {
+				matched, err := path.Match(p, dir)
+				if err != nil {
+					return false, fmt.Errorf("invalid path_filter pattern %q -> %v", p, err)
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// unchangedPaths ... returns true if no file matching the path filter of the
+// entry changed between the revision of the last relevant build and the ref
+// being built, entries are never skipped when the files changed cannot be
+// determined, such as when there is no previous build or the project is not
+// hosted on GitHub
+func unchangedPaths(client circleci.API, gh *githubClient, project *circleci.Project, input *circleci.BuildProjectInput, e *entry, skipStatus string) (bool, error) {
+	if project.VCS() != circleci.VCSGitHub {
+		infof("Not filtering paths of project %q, only GitHub projects can be compared\n", project.Reponame)
+		return false, nil
+	}
+	head := input.Revision
+	if len(head) == 0 {
+		head = input.Branch
+	}
+	if len(head) == 0 {
+		head = input.Tag
+	}
+	// the revision being built is compared against the last build of the
+	// branch or tag, so the revision is not used to find that build
+	rawBuilds, err := client.FindBuildSummaries(project, logOutput, &circleci.BuildProjectInput{
+		Branch: input.Branch,
+		Tag:    input.Tag,
+		Fork:   input.Fork,
+	})
+	if err != nil {
+		return false, err
+	}
+	last := lastStoppedBuild(filterSkipStatus(rawBuilds, skipStatus))
+	if last == nil || len(last.Revision) == 0 {
+		return false, nil
+	}
+	files, complete, err := gh.changedFiles(project, last.Revision, head)
+	if err != nil {
+		return false, fmt.Errorf("failed to compare %s to %s -> %v", last.Revision, head, err)
+	}
+	if !complete {
+		infof("Not filtering paths of project %q, too many files changed since %s\n", project.Reponame, last.Revision)
+		return false, nil
+	}
+	changed, err := matchPaths(e.PathFilter, files)
+	if err != nil {
+		return false, err
+	}
+	return !changed, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+func TestMatchPaths(t *testing.T) {
+	tt := map[string]struct {
+		patterns    []string
+		files       []string
+		expected    bool
+		expectedErr string
+	}{
+		"file":          {patterns: []string{"README.md"}, files: []string{"README.md"}, expected: true},
+		"directory":     {patterns: []string{"services/api"}, files: []string{"services/api/cmd/main.go"}, expected: true},
+		"glob":          {patterns: []string{"docs/*.md"}, files: []string{"main.go", "docs/usage.md"}, expected: true},
+		"glob subdir":   {patterns: []string{"services/*"}, files: []string{"services/web/index.html"}, expected: true},
+		"no match":      {patterns: []string{"services/api"}, files: []string{"services/web/index.html", "services/apiv2/main.go"}},
+		"no files":      {patterns: []string{"services/api"}},
+		"invalid":       {patterns: []string{"[a"}, files: []string{"main.go"}, expectedErr: "invalid path_filter pattern"},
+		"multi pattern": {patterns: []string{"docs", "services/web"}, files: []string{"services/web/index.html"}, expected: true},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := matchPaths(tc.patterns, tc.files)
+			if len(tc.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("matchPaths() failed. Expected error: %q, got: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matchPaths() failed: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("matchPaths() failed. Expected: %t, got: %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUnchangedPaths(t *testing.T) {
+	var (
+		path  string
+		files []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		var items []string
+		for _, f := range files {
+			items = append(items, fmt.Sprintf(`{"filename": %q}`, f))
+		}
+		fmt.Fprintf(w, `{"files": [%s]}`, strings.Join(items, ","))
+	}))
+	defer srv.Close()
+	gh := newGitHubClient("")
+	gh.baseURL = srv.URL
+	e := &entry{PathFilter: []string{"services/api"}}
+	input := &circleci.BuildProjectInput{Branch: "master"}
+	tt := map[string]struct {
+		vcs      string
+		revision string
+		files    []string
+		expected bool
+		compared bool
+	}{
+		"unchanged":     {vcs: "github", revision: "abc123", files: []string{"services/web/index.html"}, expected: true, compared: true},
+		"changed":       {vcs: "github", revision: "abc123", files: []string{"services/api/main.go"}, compared: true},
+		"no revision":   {vcs: "github"},
+		"not on github": {vcs: "bitbucket", revision: "abc123"},
+		"truncated":     {vcs: "github", revision: "abc123", files: make([]string, maxCompareFiles), compared: true},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			path, files = "", tc.files
+			client := mockClient{Revision: tc.revision}
+			project := &circleci.Project{Username: "org", Reponame: "test1", Vcs: tc.vcs}
+			actual, err := unchangedPaths(client, gh, project, input, e, defaultSkipStatus)
+			if err != nil {
+				t.Fatalf("unchangedPaths() failed: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("unchangedPaths() failed. Expected: %t, got: %t", tc.expected, actual)
+			}
+			if tc.compared && path != "/repos/org/test1/compare/abc123...master" {
+				t.Errorf("unchangedPaths() failed: unexpected request: %q", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package circleci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cache keys of the responses cached on disk
+const (
+	cacheKeyMe       = "me"
+	cacheKeyProjects = "projects"
+)
+
+// diskCache ... used internally to persist the responses of Me and Projects
+// between runs, entries are stored per access key and expire after ttl, failures
+// reading or writing the cache are ignored, since it only avoids requests
+type diskCache struct {
+	dir string
+	ttl time.Duration
+	//replaced in tests, defaults to time.Now
+	now func() time.Time
+}
+
+// cacheEntry ... used internally to represent the contents of a cache file
+type cacheEntry struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// WithCache ... caches the responses of Me and Projects in files within dir for
+// ttl, so that they are not requested again by later runs, the cached responses
+// are removed when CircleCI rejects the access key, and the cached projects
+// when a project is followed or unfollowed
+func WithCache(dir string, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = &diskCache{dir: dir, ttl: ttl, now: time.Now}
+	}
+}
+
+// path ... returns the path of the file caching key, the access key and
+// base URL are hashed so each account has its own entries
+func (d *diskCache) path(c *Client, key string) string {
+	sum := sha256.Sum256([]byte(c.Token + " " + c.baseURL.String()))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:8])+"-"+key+".json")
+}
+
+// get ... decodes the cached response of key into output, returns
+// false if there is no cached response or it has expired
+func (d *diskCache) get(c *Client, key string, output interface{}) bool {
+	b, err := ioutil.ReadFile(d.path(c, key))
+	if err != nil {
+		return false
+	}
+	var e cacheEntry
+	err = json.Unmarshal(b, &e)
+	if err != nil || d.now().Sub(e.StoredAt) > d.ttl {
+		return false
+	}
+	return json.Unmarshal(e.Data, output) == nil
+}
+
+// put ... caches the response of key
+func (d *diskCache) put(c *Client, key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(&cacheEntry{StoredAt: d.now(), Data: data})
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(d.dir, 0700)
+	if err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(d.path(c, key), b, 0600)
+}
+
+// remove ... removes the cached response of key
+func (d *diskCache) remove(c *Client, key string) {
+	_ = os.Remove(d.path(c, key))
+}
+
+// clear ... removes every cached response of the access key
+func (d *diskCache) clear(c *Client) {
+	for _, key := range []string{cacheKeyMe, cacheKeyProjects} {
+		d.remove(c, key)
+	}
+}
+
+// authError ... returns true if err shows CircleCI rejected the access key
+func authError(err error) bool {
+	reqErr, ok := err.(RequestError)
+	return ok && (reqErr.Code == http.StatusUnauthorized || reqErr.Code == http.StatusForbidden)
+}
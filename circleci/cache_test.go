@@ -0,0 +1,115 @@
+package circleci
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var (
+		requests []string
+		status   = http.StatusOK
+	)
+	c := NewClient(nil, "token", WithCache(dir, time.Hour), WithRetry(1, 0))
+	c.cache.now = func() time.Time { return now }
+	c.requester = func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		requests = append(requests, path)
+		if status != http.StatusOK {
+			return RequestError{Code: status, Message: http.StatusText(status)}
+		}
+		switch path {
+		case "me":
+			return json.Unmarshal([]byte(`{"login": "org"}`), output)
+		case "projects":
+			return json.Unmarshal([]byte(`[{"username": "org", "reponame": "test1"}]`), output)
+		}
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		me, err := c.Me(os.Stdout)
+		assert.NilError(t, err)
+		assert.Equal(t, "org", me.Username)
+		projects, err := c.Projects(os.Stdout)
+		assert.NilError(t, err)
+		assert.Equal(t, "test1", projects[0].Reponame)
+	}
+	assert.DeepEqual(t, []string{"me", "projects"}, requests)
+
+	// a client with another access key does not share the cache
+	other := NewClient(nil, "other", WithCache(dir, time.Hour))
+	var me User
+	assert.Assert(t, !other.cache.get(other, cacheKeyMe, &me))
+
+	// expired entries are requested again
+	now = now.Add(2 * time.Hour)
+	_, err = c.Me(os.Stdout)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"me", "projects", "me"}, requests)
+
+	// the cache is cleared when the access key is rejected
+	status = http.StatusUnauthorized
+	_, err = c.BuildSummary(&Project{Username: "org", Reponame: "test1", Vcs: "github"}, os.Stdout, nil)
+	assert.Error(t, err, "Unauthorized")
+	assert.Assert(t, !c.cache.get(c, cacheKeyMe, &me))
+	var projects []*Project
+	assert.Assert(t, !c.cache.get(c, cacheKeyProjects, &projects))
+}
+
+func TestCacheFollowProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		requests []string
+		followed bool
+	)
+	c := NewClient(nil, "token", WithCache(dir, time.Hour), WithRetry(1, 0))
+	c.requester = func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		requests = append(requests, path)
+		switch path {
+		case "projects":
+			if followed {
+				return json.Unmarshal([]byte(`[{"username": "org", "reponame": "test1", "followed": true},
+					{"username": "org", "reponame": "test2", "followed": true}]`), output)
+			}
+			return json.Unmarshal([]byte(`[{"username": "org", "reponame": "test1", "followed": true}]`), output)
+		case "project/github/org/test2/follow":
+			followed = true
+			return json.Unmarshal([]byte(`{"following": true}`), output)
+		}
+		return nil
+	}
+	project := &Project{Username: "org", Reponame: "test2", Vcs: "github"}
+	byName := func(p *Project) bool { return p.Reponame == project.Reponame }
+
+	_, err = c.Projects(os.Stdout)
+	assert.NilError(t, err)
+	// following removes the cached projects
+	assert.NilError(t, c.FollowProject(project, os.Stdout))
+	p, err := c.FindProject(os.Stdout, byName)
+	assert.NilError(t, err)
+	assert.Equal(t, true, p.Followed)
+	assert.DeepEqual(t, []string{"projects", "project/github/org/test2/follow", "projects"}, requests)
+
+	// a miss in the cached projects requests the projects again
+	requests = nil
+	c.cache.put(c, cacheKeyProjects, []*Project{{Username: "org", Reponame: "test1"}})
+	p, err = c.FindProject(os.Stdout, byName)
+	assert.NilError(t, err)
+	assert.Equal(t, "test2", p.Reponame)
+	assert.DeepEqual(t, []string{"projects"}, requests)
+}
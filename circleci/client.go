@@ -1,12 +1,16 @@
 package circleci
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,20 +19,242 @@ type Client struct {
 	//initialized http client, if not provided, will be empty client
 	client *http.Client
 	//circleci access key used for all requests
-	Token     string
-	baseURL   *url.URL
-	requester requestFunc
+	Token string
+	//duration to wait for a workflow that is on hold to be approved,
+	//if zero, workflows that are on hold are not waited on
+	ApprovalTimeout time.Duration
+	//determines how WaitForProjectBuild waits for builds, BuildChain by default
+	WaitStrategy WaitStrategy
+	//determines the CircleCI API version used by methods implemented
+	//using both versions, APIv1 by default
+	APIVersion APIVersion
+	//if true, the job timeout of a build waited on using BuildChain starts
+	//once the build is running, so time spent queued is not counted
+	ActiveJobTimeout bool
+	//if non-zero, a build waited on using BuildChain that has not started
+	//running within QueueTimeout fails with a *QueueTimeoutError
+	QueueTimeout time.Duration
+	//if set, decides whether a finished build waited on using BuildChain
+	//succeeded, by default a build succeeds unless it failed
+	BuildSucceeded func(*Build) bool
+	//if set, decides whether a finished workflow waited on using WorkflowStatus,
+	//or generated by a setup workflow, succeeded, by default a workflow
+	//succeeds if its status is success
+	WorkflowSucceeded func(*Workflow) bool
+	//if set, each request and response is logged to DebugLogger,
+	//the access key is always redacted
+	DebugLogger io.Writer
+	//duration between each poll of the status of a build or workflow
+	//while waiting for it to finish, defaults to 2 seconds
+	PollInterval time.Duration
+	//if non-zero, bounds each attempt of each request to CircleCI, so a
+	//single request that hangs cannot consume the entire job timeout
+	RequestTimeout time.Duration
+	//headers added to every request, such as those required by
+	//a gateway, they never replace the headers set by the client
+	DefaultHeaders http.Header
+	baseURL        *url.URL
+	requester      requestFunc
+	userAgent      string
+	//number of attempts and seconds between attempts for each request
+	retryAttempts     int
+	retryIntervalSecs int
+	//username builds are expected to be attributed to, replaces the current user
+	buildActor string
+	//if set, the responses of Me and Projects are cached on disk
+	cache *diskCache
+	//if set, waiting and requests stop once ctx is done, see WithContext
+	ctx context.Context
+	//state shared with the copies returned by WithContext, see shared
+	state *clientState
 }
 
-// NewClient ... returns a *circleci.Client
-func NewClient(client *http.Client, token string) *Client {
-	c := &Client{Token: token}
+// clientState ... used internally to hold the state of a client that is
+// shared with the copies of the client returned by WithContext
+type clientState struct {
+	//current user, cached by currentUser
+	me   *User
+	meMu sync.Mutex
+	//deprecation warnings already recorded, and those not yet
+	//logged, see warnDeprecation
+	warned       map[string]bool
+	deprecations []string
+	warnedMu     sync.Mutex
+	//throttles requests using the rate limit headers returned by CircleCI
+	limiter rateLimiter
+}
+
+// stateMu ... guards the lazy initialization of the state of each client
+var stateMu sync.Mutex
+
+// shared ... used internally to return the state of the client,
+// which is initialized on first use
+func (c *Client) shared() *clientState {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if c.state == nil {
+		c.state = &clientState{}
+	}
+	return c.state
+}
+
+// WithContext ... returns a copy of the client whose requests and waiting
+// stop once ctx is done, returning the error of ctx, the copy shares the
+// cached current user and rate limit of the client
+func (c *Client) WithContext(ctx context.Context) API {
+	state := c.shared()
+	cc := *c
+	cc.ctx = ctx
+	cc.state = state
+	return &cc
+}
+
+// context ... used internally to return the context of the client,
+// or context.Background if it is not set
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// defaultPollInterval ... the default duration between each poll of a build or workflow
+const defaultPollInterval = 2 * time.Second
+
+// pollInterval ... used internally to return the PollInterval of the client,
+// or the default if it is not set
+func (c *Client) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// BaseURL ... returns the base URL of the CircleCI API v1.1 used by the client
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// Version ... the version of grace-circleci-builder, used in the default User-Agent
+const Version = "0.2.0"
+
+// defaultUserAgent ... the User-Agent sent with each request when not overridden
+const defaultUserAgent = "grace-circleci-builder/" + Version
+
+// Option ... configures optional settings of a *circleci.Client
+type Option func(*Client)
+
+// WithUserAgent ... overrides the User-Agent header sent with each request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithBuildActor ... overrides the username that triggered builds are expected
+// to be attributed to, instead of the user returned by Me, required when the
+// token belongs to a machine user whose builds are attributed differently
+func WithBuildActor(username string) Option {
+	return func(c *Client) {
+		c.buildActor = username
+	}
+}
+
+const (
+	// defaultMaxIdleConnsPerHost ... the default number of idle connections kept open to CircleCI
+	defaultMaxIdleConnsPerHost = 10
+	// defaultMaxConnsPerHost ... the default limit of connections open to CircleCI
+	defaultMaxConnsPerHost = 20
+)
+
+// WithConnectionLimits ... limits the number of idle and total connections
+// opened to CircleCI, only applies if the http client uses an *http.Transport
+func WithConnectionLimits(maxIdleConnsPerHost, maxConnsPerHost int) Option {
+	return func(c *Client) {
+		configureTransport(c, func(t *http.Transport) {
+			t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+			t.MaxConnsPerHost = maxConnsPerHost
+		})
+	}
+}
+
+// WithProxy ... sends all requests through the proxy at proxyURL, rather than
+// the proxy configured by the environment, http, https and socks5 proxies are
+// supported, if proxyURL is invalid every request fails with the parse error,
+// only applies if the http client uses an *http.Transport
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) {
+		u, err := url.Parse(proxyURL)
+		if err == nil && (len(u.Scheme) == 0 || len(u.Host) == 0) {
+			err = fmt.Errorf("proxy URL must include a scheme and host: %q", proxyURL)
+		}
+		configureTransport(c, func(t *http.Transport) {
+			t.Proxy = func(*http.Request) (*url.URL, error) {
+				return u, err
+			}
+		})
+	}
+}
+
+// configureTransport ... used internally to modify a copy of the client's
+// *http.Transport, does nothing if the client uses another http.RoundTripper
+func configureTransport(c *Client, configure func(*http.Transport)) {
+	var t *http.Transport
+	switch rt := c.client.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return
+	}
+	configure(t)
+	// copy the client so that a caller provided client is not modified
+	client := *c.client
+	client.Transport = t
+	c.client = &client
+}
+
+// WithHeaders ... adds headers to every request sent to CircleCI, headers
+// set by the client, such as Accept and Content-Type, are not replaced
+func WithHeaders(headers http.Header) Option {
+	return func(c *Client) {
+		if c.DefaultHeaders == nil {
+			c.DefaultHeaders = http.Header{}
+		}
+		for k, v := range headers {
+			for _, value := range v {
+				c.DefaultHeaders.Add(k, value)
+			}
+		}
+	}
+}
+
+// WithRetry ... sets the number of attempts made for each request
+// and the number of seconds to wait between failed attempts
+func WithRetry(attempts int, intervalSecs int) Option {
+	return func(c *Client) {
+		c.retryAttempts = attempts
+		c.retryIntervalSecs = intervalSecs
+	}
+}
+
+// NewClient ... returns a *circleci.Client, if client is nil a client
+// limiting the number of connections opened to CircleCI is used
+func NewClient(client *http.Client, token string, opts ...Option) *Client {
+	c := &Client{Token: token, client: client}
 	if client == nil {
 		c.client = &http.Client{}
+		WithConnectionLimits(defaultMaxIdleConnsPerHost, defaultMaxConnsPerHost)(c)
 	}
 	c.requester = request
 	// baseURL ... used internally to represent the base URL path for CircleCI API v1.1
 	c.baseURL = &url.URL{Scheme: "https", Host: "circleci.com", Path: "/api/v1.1/"}
+	c.userAgent = defaultUserAgent
+	c.retryAttempts, c.retryIntervalSecs = defaultRetryAttempts, defaultRetryIntervalSecs
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
@@ -50,6 +276,8 @@ type Project struct {
 	Reponame string `json:"reponame"`
 	Vcs      string `json:"vcs_type"`
 	VcsURL   string `json:"vcs_url"`
+	//true if the current user follows the project, only set by Projects
+	Followed bool `json:"followed"`
 }
 
 // BuildProjectInput ... contains data necessary to send a new project build
@@ -63,6 +291,45 @@ type BuildProjectInput struct {
 	//The git tag to build. Cannot be used with branch and revision
 	//parameters.
 	Tag string `json:"tag,omitempty"`
+	//Pipeline parameters, when provided the build is triggered
+	//as a pipeline using the CircleCI API v2. Cannot be used
+	//with revision parameter.
+	Parameters map[string]interface{} `json:"-"`
+	//Names of approval jobs to approve automatically
+	//while waiting for the build, other approval jobs
+	//are left on hold.
+	AutoApproveJobs []string `json:"-"`
+	//Builds of forked pull requests are attributed to the
+	//fork author, when true any build triggered using the
+	//API is accepted as a build triggered by the current user.
+	Fork bool `json:"-"`
+	//Number of a GitHub pull request, the result of merging
+	//the pull request into its base branch is built. Cannot
+	//be used with branch, revision or tag parameters.
+	PullRequestMerge int `json:"-"`
+	//Number of times the failed jobs of the workflow are
+	//rerun when it fails, for workflows with flaky jobs,
+	//the jobs that succeeded are not run again.
+	RerunFailedJobs int `json:"-"`
+}
+
+// mergeRef ... returns the ref of the merge of the pull request
+func (bpi *BuildProjectInput) mergeRef() string {
+	return fmt.Sprintf("pull/%d/merge", bpi.PullRequestMerge)
+}
+
+// request ... used internally to return the input sent to CircleCI, when
+// PullRequestMerge is set a copy building the merge ref is returned
+func (bpi *BuildProjectInput) request() (*BuildProjectInput, error) {
+	if bpi.PullRequestMerge == 0 {
+		return bpi, nil
+	}
+	if len(bpi.Branch) > 0 || len(bpi.Revision) > 0 || len(bpi.Tag) > 0 {
+		return nil, fmt.Errorf("pull request merge cannot be used with branch, revision or tag: %s", bpi)
+	}
+	in := *bpi
+	in.Branch = bpi.mergeRef()
+	return &in, nil
 }
 
 // matchSummary ... returns true if the given *BuildSummaryOutput matches the
@@ -71,17 +338,49 @@ func (bpi *BuildProjectInput) matchSummary(summary *BuildSummaryOutput) bool {
 	if len(bpi.Tag) > 0 && summary.VcsTag != bpi.Tag {
 		return false
 	}
-	if len(bpi.Revision) > 0 && summary.Revision != bpi.Revision {
+	if len(bpi.Revision) > 0 && !matchRevision(summary.Revision, bpi.Revision) {
 		return false
 	}
 	if len(bpi.Branch) > 0 && summary.Branch != bpi.Branch {
 		return false
 	}
+	// CircleCI may report the branch of a merge build
+	// as the merge ref or as pull/:number
+	if bpi.PullRequestMerge > 0 &&
+		summary.Branch != bpi.mergeRef() &&
+		summary.Branch != fmt.Sprintf("pull/%d", bpi.PullRequestMerge) {
+		return false
+	}
 	return true
 }
 
+// matchRevision ... returns true if either revision is a prefix of the other,
+// allowing abbreviated commit hashes to match the full hash returned by CircleCI
+func matchRevision(a, b string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// whyAPI ... the why property of builds triggered using the API
+const whyAPI = "api"
+
+// matchUser ... returns true if the given *BuildSummaryOutput was triggered by
+// the given user, or if Fork is set and the build was triggered using the API
+func (bpi *BuildProjectInput) matchUser(summary *BuildSummaryOutput, me *User) bool {
+	if summary.User != nil && summary.User.Username == me.Username {
+		return true
+	}
+	return bpi.Fork && summary.Why == whyAPI
+}
+
 // String ... returns the string formatted version of a BuildProjectInput
 func (bpi *BuildProjectInput) String() string {
+	if bpi.PullRequestMerge > 0 {
+		return fmt.Sprintf("[Branch: %q, Revision: %q, Tag: %q, PullRequestMerge: %d]", bpi.Branch, bpi.Revision, bpi.Tag, bpi.PullRequestMerge)
+	}
 	return fmt.Sprintf("[Branch: %q, Revision: %q, Tag: %q]", bpi.Branch, bpi.Revision, bpi.Tag)
 }
 
@@ -99,25 +398,21 @@ func (b buildProjectOutput) String() string {
 	return fmt.Sprintf("Status: %d, Body: %q", b.Status, b.Body)
 }
 
+// maxFindInterval ... the maximum interval between attempts
+// to find the build summary of a newly triggered build
+const maxFindInterval = 8 * time.Second
+
 // BuildProject ... attempts to trigger a new project build,
 // waits the next build job to start, then returns the *BuildSummaryObject
-// for that build job
+// for that build job, if the pipeline triggered using the CircleCI API v2 was
+// created without any workflows a *NoBuildsTriggeredError is returned, and if
+// it errored a *PipelineErroredError is returned
 func (c *Client) BuildProject(project *Project, logger io.Writer, input *BuildProjectInput, waitTimeout time.Duration) (*BuildSummaryOutput, error) {
-	var output buildProjectOutput
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
-		url := fmt.Sprintf("project/%s/%s/%s/build", project.Vcs, project.Username, project.Reponame)
-		err := c.requester(c, "POST", url, nil, input, &output)
-		if err != nil {
-			logf(logger, "BuildProject failed, POST /%s -> %v", url, err)
-		}
-		return err
-	})
+	pipeline, err := c.startProjectBuild(project, logger, input)
 	if err != nil {
 		return nil, err
 	}
-	if output.Status != http.StatusOK {
-		return nil, fmt.Errorf("failed to start project build: %s", output)
-	}
+	triggered := time.Now()
 	//nolint:godox
 	// 12/14/2018 - BLA
 	// TODO: Fix this if CircleCI ever fixes their API
@@ -125,15 +420,21 @@ func (c *Client) BuildProject(project *Project, logger io.Writer, input *BuildPr
 	// starting a project build, so we must wait a while and try to find
 	// a matching build that was created around this time
 	// if we wait longer than 1 minute we'll give up
+	// back off between attempts, builds usually appear within a few seconds
 	after := time.Now().Add(-3 * time.Second)
 	var summary *BuildSummaryOutput
-	err = waiter(time.Second, time.Now().Add(waitTimeout), func(count int) (bool, error) {
-		if count%10 == 0 {
-			logf(logger, "waiting for a build summary matching the project: %s\n", project.Reponame)
+	err = backoffWaiter(c.context(), time.Second, maxFindInterval, time.Now().Add(waitTimeout), func(count int) (bool, error) {
+		if count%3 == 0 {
+			infof(logger, "waiting for a build summary matching the project: %s\n", project.Reponame)
 		}
 		summary, err = c.findBuildSummary(project, logger, input, after)
 		if err != nil {
 			if _, ok := err.(*summaryNotFoundError); ok {
+				// a pipeline triggered using the CircleCI API v2 without
+				// workflows will never start a build, so stop waiting
+				if pipeline != nil && time.Since(triggered) >= pipelineGracePeriod {
+					return false, c.checkPipelineWorkflows(project, logger, input, pipeline)
+				}
 				return false, nil
 			}
 			// should we care about this error if it happens within 1 minute
@@ -148,35 +449,191 @@ func (c *Client) BuildProject(project *Project, logger io.Writer, input *BuildPr
 	return summary, nil
 }
 
+// BuildKnownProject ... behaves like BuildProject for a project resolved earlier,
+// such as by FindProject during a previous run, trusting the project instead of
+// following and finding it again, if CircleCI no longer finds the project a
+// *ProjectNotFollowedError is returned, so the caller can follow it again
+func (c *Client) BuildKnownProject(project *Project, logger io.Writer, input *BuildProjectInput, waitTimeout time.Duration) (*BuildSummaryOutput, error) {
+	if project == nil || len(project.Vcs) == 0 || len(project.Username) == 0 || len(project.Reponame) == 0 {
+		return nil, fmt.Errorf("a known project must have a vcs, username and reponame")
+	}
+	summary, err := c.BuildProject(project, logger, input, waitTimeout)
+	if err != nil {
+		return nil, notFollowed(project, err)
+	}
+	return summary, nil
+}
+
+// pipelineGracePeriod ... the time a pipeline triggered by BuildProject is
+// given to create its workflows, before a pipeline without any workflows is
+// considered to have had every workflow filtered by the configuration
+var pipelineGracePeriod = 5 * time.Second
+
+// pipeline states returned by the CircleCI API v2, a pipeline is created once
+// its configuration has been processed and its workflows have been created
+const (
+	pipelineStateCreated = "created"
+	pipelineStateErrored = "errored"
+)
+
+// NoBuildsTriggeredError ... BuildProject triggered the project, but no build
+// matching the input was started, usually because the filters in the
+// configuration of the project exclude every workflow for the branch or tag
+type NoBuildsTriggeredError struct {
+	Message string
+}
+
+func (e *NoBuildsTriggeredError) Error() string {
+	return e.Message
+}
+
+// PipelineErroredError ... the pipeline triggered by BuildProject errored,
+// usually because the configuration of the project is invalid
+type PipelineErroredError struct {
+	Message string
+}
+
+func (e *PipelineErroredError) Error() string {
+	return e.Message
+}
+
+// checkPipelineWorkflows ... used internally to return a NoBuildsTriggeredError if
+// the pipeline has been created without any workflows, or a PipelineErroredError
+// if the pipeline errored, otherwise returns nil so that waiting continues
+func (c *Client) checkPipelineWorkflows(project *Project, logger io.Writer, input *BuildProjectInput, pipeline *Pipeline) error {
+	p, err := c.GetPipelineByNumber(project, logger, pipeline.Number)
+	if err != nil {
+		return fmt.Errorf("failed to get pipeline %d of project %s -> %v", pipeline.Number, project.Reponame, err)
+	}
+	switch p.State {
+	case pipelineStateErrored:
+		var messages []string
+		for _, e := range p.Errors {
+			messages = append(messages, e.Message)
+		}
+		return &PipelineErroredError{Message: fmt.Sprintf("pipeline %d of project %s matching %s errored: %s", p.Number, project.Reponame, input, strings.Join(messages, ", "))}
+	case pipelineStateCreated:
+		workflows, err := c.PipelineWorkflows(p.ID, logger)
+		if err != nil {
+			return fmt.Errorf("failed to get the workflows of pipeline %d of project %s -> %v", p.Number, project.Reponame, err)
+		}
+		if len(workflows) == 0 {
+			return &NoBuildsTriggeredError{Message: fmt.Sprintf("no builds were triggered for project %s matching %s, pipeline %d has no workflows (possibly filtered by config)", project.Reponame, input, p.Number)}
+		}
+	}
+	return nil
+}
+
+// startProjectBuild ... used internally to trigger a new project build, if
+// pipeline parameters are provided the build is triggered as a pipeline
+// using the CircleCI API v2 and the pipeline is returned, otherwise the
+// CircleCI API v1.1 is used and the returned pipeline is nil
+func (c *Client) startProjectBuild(project *Project, logger io.Writer, input *BuildProjectInput) (*Pipeline, error) {
+	if len(input.Parameters) > 0 {
+		return c.TriggerOnly(project, logger, input)
+	}
+	input, err := input.request()
+	if err != nil {
+		return nil, err
+	}
+	var output buildProjectOutput
+	err = c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/build", project.v1Path())
+		err := c.requester(c, "POST", url, nil, input, &output)
+		if err != nil {
+			logf(logger, "BuildProject failed, POST /%s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.Status != http.StatusOK {
+		return nil, fmt.Errorf("failed to start project build: %s", output)
+	}
+	return nil, nil
+}
+
 // findBuildSummary ... used internally to locate a BuildSummary that was executed
 // by the current user and was queued after the provided 'after' time.Time
 func (c *Client) findBuildSummary(project *Project, logger io.Writer, input *BuildProjectInput, after time.Time) (*BuildSummaryOutput, error) {
-	summaries, err := c.BuildSummary(project, logger, nil)
+	summaries, err := c.recentBuildSummaries(project, logger, input)
 	if err != nil {
 		return nil, err
 	}
-	// don't store response but leave call to c.Me() for
-	// restoration later after CircleCI restores the username
-	// property inside the build summary response
-	me, err := c.Me(logger)
+	// the current user is cached, since this is called
+	// repeatedly while waiting for a build to appear
+	me, err := c.currentUser(logger)
 	if err != nil {
 		return nil, err
 	}
 	for _, summary := range summaries {
-		if summary.QueuedAt == nil {
+		// jobs that have not started have no build number to wait on yet
+		if summary.QueuedAt == nil || summary.BuildNum == 0 {
 			continue
 		}
 		if input.matchSummary(summary) &&
-			summary.User.Username == me.Username &&
+			input.matchUser(summary, me) &&
 			summary.QueuedAt.Sub(after) > 0 {
 			return summary, nil
 		}
 	}
-	return nil, &summaryNotFoundError{fmt.Sprintf("BuildSummary not found matching this project: %s", project)}
+	return nil, &summaryNotFoundError{fmt.Sprintf("BuildSummary not found matching this project: %s", project.Reponame)}
+}
+
+// statusCanceled ... the status of builds and workflows that were canceled
+const statusCanceled = "canceled"
+
+// BuildCanceledError ... a build or workflow was canceled while calling WaitForProjectBuild,
+// returned regardless of continueOnFail, since it usually means someone deliberately stopped it
+type BuildCanceledError struct {
+	Message string
+}
+
+func (e *BuildCanceledError) Error() string {
+	return e.Message
+}
+
+// statusInfrastructureFail ... the status of a build that failed due to
+// a problem with CircleCI, rather than the project being built
+const statusInfrastructureFail = "infrastructure_fail"
+
+// InfrastructureFailError ... a build failed due to a problem with CircleCI while
+// calling WaitForProjectBuild, unlike a failed build it may succeed if it is
+// triggered again, so it is returned regardless of continueOnFail
+type InfrastructureFailError struct {
+	Message string
+}
+
+func (e *InfrastructureFailError) Error() string {
+	return e.Message
+}
+
+// JobTimeoutError ... a build or workflow did not finish within the job timeout
+// while calling WaitForProjectBuild, unlike a failed build it may succeed if
+// it is triggered again
+type JobTimeoutError struct {
+	Message string
+}
+
+func (e *JobTimeoutError) Error() string {
+	return e.Message
+}
+
+// QueueTimeoutError ... a build did not leave the queue within the QueueTimeout
+// of the client, which indicates CircleCI has no capacity or the configuration
+// prevents any jobs from running, rather than a slow build
+type QueueTimeoutError struct {
+	Message string
+}
+
+func (e *QueueTimeoutError) Error() string {
+	return e.Message
 }
 
 // WaitForProjectBuild ... waits for all build jobs within the given project
-// to complete, if a build job fails, will return an error immediately
+// to complete, if a build job fails, will return an error immediately, the
+// client's WaitStrategy determines how builds are waited on
 // waitTimeout is the time to wait for the next build, before giving up
 // jobTimeout is the duration to wait for the build to complete, before giving up
 func (c *Client) WaitForProjectBuild(
@@ -187,18 +644,57 @@ func (c *Client) WaitForProjectBuild(
 	jobTimeout time.Duration,
 	waitTimeout time.Duration,
 	continueOnFail bool) error {
+	return c.waitForProjectBuild(project, logger, input, summary, jobTimeout, waitTimeout, continueOnFail, nil)
+}
+
+// waitForProjectBuild ... used internally by WaitForProjectBuild and
+// WaitForProjectBuildEvents, events may be nil
+// nolint: gocyclo
+func (c *Client) waitForProjectBuild(
+	project *Project,
+	logger io.Writer,
+	input *BuildProjectInput,
+	summary *BuildSummaryOutput,
+	jobTimeout time.Duration,
+	waitTimeout time.Duration,
+	continueOnFail bool,
+	events chan<- BuildEvent) error {
+	triggered := BuildEvent{Type: EventTriggered, Project: project, BuildNum: summary.BuildNum, Lifecycle: summary.Lifecycle, Status: summary.Status}
+	sendEvent(events, triggered)
+	if c.WaitStrategy == WorkflowStatus {
+		workflow, err := c.waitForWorkflowStatus(project, logger, input, summary, jobTimeout, continueOnFail)
+		finished := BuildEvent{Type: EventFinished, Project: project, BuildNum: summary.BuildNum, Lifecycle: lifecycleFinished, Status: "success"}
+		switch {
+		case err != nil:
+			finished.Status = "failed"
+		case workflow != nil:
+			// continueOnFail may hide a failed workflow, report its actual status
+			finished.Status = workflow.Status
+		}
+		sendEvent(events, finished)
+		return err
+	}
 	buildNum := summary.BuildNum
 	for {
-		build, err := c.waitForBuild(project, logger, buildNum, jobTimeout)
+		build, err := c.waitForBuild(project, logger, buildNum, jobTimeout, events)
 		if err != nil {
 			return err
 		}
-		if *build.Failed {
+		if build.Status == statusCanceled {
+			return &BuildCanceledError{Message: fmt.Sprintf("build %s [%d] was canceled", project.Reponame, buildNum)}
+		}
+		if !c.buildSucceeded(build) {
+			if build.Status != statusInfrastructureFail && input.RerunFailedJobs > 0 && build.Workflow != nil {
+				return c.rerunFailedBuild(project, logger, input, build, jobTimeout, continueOnFail)
+			}
+			if build.Status == statusInfrastructureFail {
+				return &InfrastructureFailError{Message: fmt.Sprintf("build %s [%d] failed due to a CircleCI infrastructure failure", project.Reponame, buildNum)}
+			}
 			if continueOnFail {
-				logf(logger, "build %s [%d] failed, continue on failure is enabled for this project\n", project.Reponame, buildNum)
+				infof(logger, "build %s [%d] failed, continue on failure is enabled for this project\n", project.Reponame, buildNum)
 				return nil
 			}
-			return fmt.Errorf("build %s [%d] failed", project.Reponame, buildNum)
+			return c.buildFailedError(project, logger, build)
 		}
 		if build.Workflow == nil {
 			return fmt.Errorf("could not obtain workflow details from build %d", buildNum)
@@ -206,32 +702,64 @@ func (c *Client) WaitForProjectBuild(
 		s, err := c.waitForNextBuild(project, logger, input, build.Workflow.WorkflowID, waitTimeout)
 		if err != nil {
 			if _, ok := err.(*timeoutExceededError); ok {
+				// the workflow may be waiting on an approval job, if it
+				// was approved, continue waiting for the next build
+				approved, aerr := c.waitForApproval(project, logger, build.Workflow.WorkflowID, input.AutoApproveJobs)
+				if aerr != nil {
+					return aerr
+				}
+				if approved {
+					continue
+				}
 				// Assuming all builds are completed and the last
 				// waiter call returned no results, which is expected
 				// after the last build completes
-				return finalWorkflowStatus(c, project, logger, input, build.Workflow.WorkflowID)
+				err = finalWorkflowStatus(c.context(), c, project, logger, input, build.Workflow.WorkflowID, continueOnFail, c.BuildSucceeded)
+				if err != nil {
+					return err
+				}
+				// a setup workflow completing only means the
+				// pipeline's remaining workflows were generated
+				setup, err := c.GetWorkflow(build.Workflow.WorkflowID, logger)
+				if err != nil {
+					// if the workflow is unavailable, fallback to
+					// treating the workflow as not a setup workflow
+					logf(logger, "failed to get workflow %s [%s], not checking for generated workflows -> %v\n", project.Reponame, build.Workflow.WorkflowID, err)
+					return nil
+				}
+				return c.waitForGeneratedWorkflows(project, logger, setup, jobTimeout, continueOnFail)
 			}
 			return err
 		}
 		buildNum = s.BuildNum
+		sendEvent(events, BuildEvent{Type: EventTriggered, Project: project, BuildNum: s.BuildNum, Lifecycle: s.Lifecycle, Status: s.Status})
 	}
 }
 
+// buildSucceeded ... used internally to return true if the finished build
+// succeeded, using the BuildSucceeded predicate of the client if it is set
+func (c *Client) buildSucceeded(build *Build) bool {
+	if c.BuildSucceeded != nil {
+		return c.BuildSucceeded(build)
+	}
+	return build.Status != statusInfrastructureFail && (build.Failed == nil || !*build.Failed)
+}
+
 // waitForNextBuild ... used internally to wait for the next build job within a given project
 // and matches the provided workflowID, waitTimeout is the duration to wait before giving up
 // nolint: gocyclo
 func (c *Client) waitForNextBuild(project *Project, logger io.Writer, input *BuildProjectInput, workflowID string, waitTimeout time.Duration) (*BuildSummaryOutput, error) {
 	var summary *BuildSummaryOutput
-	me, err := c.Me(logger)
+	me, err := c.currentUser(logger)
 	if err != nil {
 		return nil, err
 	}
-	err = waiter(time.Second, time.Now().Add(waitTimeout), func(count int) (bool, error) {
+	err = waiter(c.context(), time.Second, time.Now().Add(waitTimeout), func(count int) (bool, error) {
 		if count%10 == 0 {
-			logf(logger, "waiting for the next build summary matching the project: %s and workflowId: %s\n", project.Reponame, workflowID)
+			infof(logger, "waiting for the next build summary matching the project: %s and workflowId: %s\n", project.Reponame, workflowID)
 		}
 		var summaries []*BuildSummaryOutput
-		summaries, err = c.BuildSummary(project, logger, nil)
+		summaries, err = c.recentBuildSummaries(project, logger, input)
 		if err != nil {
 			// should this be returned to the caller, logging for now - BLA
 			log.Printf("failed to enumerate build summaries: %v\n", err)
@@ -239,7 +767,8 @@ func (c *Client) waitForNextBuild(project *Project, logger io.Writer, input *Bui
 
 		for _, s := range summaries {
 			if input.matchSummary(s) &&
-				s.User.Username == me.Username &&
+				s.BuildNum > 0 &&
+				input.matchUser(s, me) &&
 				s.Lifecycle != lifecycleFinished &&
 				s.Workflow != nil &&
 				s.Workflow.WorkflowID == workflowID {
@@ -257,32 +786,56 @@ func (c *Client) waitForNextBuild(project *Project, logger io.Writer, input *Bui
 
 // waitForBuild ... used internally to wait for the build matching the given
 // buildNum to complete, does not validate that the build was successful
-// jobTimeout is the duration to wait before giving up
-func (c *Client) waitForBuild(project *Project, logger io.Writer, buildNum int, jobTimeout time.Duration) (*Build, error) {
-	const sleepSec = 2
+// jobTimeout is the duration to wait before giving up, when ActiveJobTimeout
+// is set the jobTimeout starts once the build is running, when QueueTimeout is
+// set a build that has not left the queue in time fails, the progress of the
+// build is sent to events if it is not nil, waiting stops once the context
+// of the client is done
+func (c *Client) waitForBuild(project *Project, logger io.Writer, buildNum int, jobTimeout time.Duration, events chan<- BuildEvent) (*Build, error) {
 	var (
-		count   int
-		endTime = time.Now().Add(jobTimeout)
+		count    int
+		endTime  = time.Now().Add(jobTimeout)
+		started  = !c.ActiveJobTimeout
+		queueEnd = time.Now().Add(c.QueueTimeout)
+		dequeued = c.QueueTimeout <= 0
+		progress buildProgress
 	)
 	for {
-		if time.Now().After(endTime) {
-			return nil, fmt.Errorf("job timeout exceeded while waiting for build %s [%d] to finish", project.Reponame, buildNum)
+		if started && time.Now().After(endTime) {
+			return nil, &JobTimeoutError{Message: fmt.Sprintf("job timeout exceeded while waiting for build %s [%d] to finish", project.Reponame, buildNum)}
 		}
 		if count%10 == 0 {
-			logf(logger, "waiting for build %s [%d] to finish\n", project.Reponame, buildNum)
+			infof(logger, "waiting for build %s [%d] to finish\n", project.Reponame, buildNum)
+		}
+		if err := sleepContext(c.context(), c.pollInterval()); err != nil {
+			return nil, err
 		}
-		time.Sleep(sleepSec * time.Second)
 		build, err := c.GetBuild(project, logger, buildNum)
 		if err != nil {
 			//should we return this error? logging for now - BLA
 			logf(logger, "failed to get build %s [%d] -> %v\n", project.Reponame, buildNum, err)
 			continue
 		}
+		progress.update(events, project, build)
 		// Lifecycle options:
 		//:queued, :scheduled, :not_run, :not_running, :running or :finished
 		if build.Lifecycle == lifecycleFinished {
+			if len(build.Executor()) > 0 {
+				infof(logger, "build %s [%d] finished with status %s on executor %s, resource class %s\n",
+					project.Reponame, buildNum, build.Status, build.Executor(), build.ResourceClass())
+			}
 			return build, nil
 		}
+		if !dequeued {
+			dequeued = !queuedLifecycle(build.Lifecycle)
+			if !dequeued && time.Now().After(queueEnd) {
+				return nil, &QueueTimeoutError{Message: fmt.Sprintf("build %s [%d] never started (stuck in queue for %s)", project.Reponame, buildNum, c.QueueTimeout)}
+			}
+		}
+		if !started && build.Lifecycle == lifecycleRunning {
+			infof(logger, "build %s [%d] is running, starting the job timeout of %s\n", project.Reponame, buildNum, jobTimeout)
+			started, endTime = true, time.Now().Add(jobTimeout)
+		}
 		count++
 	}
 }
@@ -296,12 +849,19 @@ type BuildSummaryInput struct {
 	Offset int
 	//Restricts which builds are returned. Set to "completed", "successful", "failed", "running", or defaults to no filter.
 	Filter string
+	//Restricts the builds returned to the branch, filtering is done by CircleCI.
+	Branch string
 }
 
 // BuildSummary ... requests build summaries for all recent builds
-// in the given project
+// in the given project, the project does not need to be followed, but
+// a *ProjectNotFollowedError is returned if CircleCI cannot find it, when the
+// APIVersion is APIv2 the summaries are mapped from the jobs of recent pipelines
 // https://circleci.com/docs/api/v1-reference/#recent-builds-project
 func (c *Client) BuildSummary(project *Project, logger io.Writer, input *BuildSummaryInput) ([]*BuildSummaryOutput, error) {
+	if c.APIVersion == APIv2 {
+		return c.buildSummaryV2(project, logger, input)
+	}
 	params := url.Values{}
 	if input != nil {
 		if input.Limit > 0 {
@@ -314,17 +874,21 @@ func (c *Client) BuildSummary(project *Project, logger io.Writer, input *BuildSu
 			params.Set("filter", input.Filter)
 		}
 	}
+	path := project.v1Path()
+	if input != nil && len(input.Branch) > 0 {
+		// https://circleci.com/docs/api/v1-reference/#recent-builds-project-branch
+		path += "/tree/" + url.PathEscape(input.Branch)
+	}
 	var output []*BuildSummaryOutput
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
-		url := fmt.Sprintf("project/%s/%s/%s", project.Vcs, project.Username, project.Reponame)
-		err := c.requester(c, "GET", url, params, input, &output)
+	err := c.retry(logger, func() error {
+		err := c.requester(c, "GET", path, params, input, &output)
 		if err != nil {
-			logf(logger, "BuildSummary failed, GET /%s -> %v", url, err)
+			logf(logger, "BuildSummary failed, GET /%s -> %v", path, err)
 		}
 		return err
 	})
 	if err != nil {
-		return nil, err
+		return nil, notFollowed(project, err)
 	}
 	return output, nil
 }
@@ -358,25 +922,120 @@ type BuildSummaryOutput struct {
 	Revision  string     `json:"vcs_revision"`
 	User      *User      `json:"user"`
 	QueuedAt  *time.Time `json:"usage_queued_at"`
+	StartTime *time.Time `json:"start_time"`
 	StoppedAt *time.Time `json:"stop_time"`
 	Vcs       string     `json:"vcs_type"`
 	VcsTag    string     `json:"vcs_tag"`
+	BuildURL  string     `json:"build_url"`
+	//reason the build was triggered, api, github, retry, etc
+	Why string `json:"why"`
+	//number of the build this build is a retry of, nil if it is not a retry
+	RetryOf *int `json:"retry_of"`
+	//commit author and message
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+	Subject     string `json:"subject"`
+	Body        string `json:"body"`
 	//This may need to change later, CircleCI returns
 	//what appears to be an array, as a single object
 	Workflow *BuildWorkflow `json:"workflows"`
+	//explain problems with the build, such as configuration errors
+	Messages []*BuildMessage `json:"messages"`
+}
+
+// QueueDuration ... returns the time the build spent queued before starting,
+// zero if the build was not queued or has not started
+func (b *BuildSummaryOutput) QueueDuration() time.Duration {
+	if b.QueuedAt == nil || b.StartTime == nil {
+		return 0
+	}
+	return b.StartTime.Sub(*b.QueuedAt)
+}
+
+// RunDuration ... returns the time the build spent running,
+// zero if the build has not started or has not stopped
+func (b *BuildSummaryOutput) RunDuration() time.Duration {
+	if b.StartTime == nil || b.StoppedAt == nil {
+		return 0
+	}
+	return b.StoppedAt.Sub(*b.StartTime)
+}
+
+// BuildSummariesForBranches ... returns the recent build summaries of each of the
+// given branches in the project, keyed by branch, one unfiltered page of build
+// summaries is requested first, only branches without builds in that page are
+// requested individually, branches without builds are not included in the map
+func (c *Client) BuildSummariesForBranches(project *Project, logger io.Writer, branches []string) (map[string][]*BuildSummaryOutput, error) {
+	const limit = 100
+	output := make(map[string][]*BuildSummaryOutput, len(branches))
+	for _, b := range branches {
+		output[b] = nil
+	}
+	results, err := c.BuildSummary(project, logger, &BuildSummaryInput{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if _, ok := output[result.Branch]; ok {
+			output[result.Branch] = append(output[result.Branch], result)
+		}
+	}
+	for branch, summaries := range output {
+		if len(summaries) > 0 {
+			continue
+		}
+		// a partial page contains every build of the project
+		if len(results) < limit {
+			delete(output, branch)
+			continue
+		}
+		summaries, err = c.BuildSummary(project, logger, &BuildSummaryInput{Limit: limit, Branch: branch})
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) == 0 {
+			delete(output, branch)
+			continue
+		}
+		output[branch] = summaries
+	}
+	return output, nil
 }
 
 // FindBuildSummaries ... returns all build summaries matching in the project and
 // the details in the build project input, that were initiated by the current user
 func (c *Client) FindBuildSummaries(project *Project, logger io.Writer, input *BuildProjectInput) ([]*BuildSummaryOutput, error) {
 	var (
-		selector BuildSummaryInput
+		selector = BuildSummaryInput{Branch: input.Branch}
 		output   []*BuildSummaryOutput
 	)
-	me, err := c.Me(logger)
+	me, err := c.currentUser(logger)
 	if err != nil {
 		return nil, err
 	}
+	// collect all matching jobs, regardless of status
+	collect := func(result *BuildSummaryOutput) {
+		if input.matchSummary(result) &&
+			result.Reponame == project.Reponame &&
+			result.Lifecycle == lifecycleFinished &&
+			input.matchUser(result, me) {
+			// push this into output for further filtering based on status
+			// and workflowID
+			output = append(output, result)
+		}
+	}
+	if c.APIVersion == APIv2 {
+		// the CircleCI API v2 pages by cursor, paging by offset would
+		// request every pipeline before the offset again for each page
+		err = c.walkBuildSummariesV2(project, logger, input.Branch, func(s *BuildSummaryOutput) error {
+			collect(s)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return output, nil
+	}
 	selector.Limit = 100
 	// while we receive 100 records in response, continue polling for more records
 	for resultNum := selector.Limit; resultNum == selector.Limit; selector.Offset += selector.Limit {
@@ -385,26 +1044,119 @@ func (c *Client) FindBuildSummaries(project *Project, logger io.Writer, input *B
 			return nil, err
 		}
 		resultNum = len(results)
-		// collect all matching jobs, regardless of status
 		for _, result := range results {
-			if input.matchSummary(result) &&
-				result.Reponame == project.Reponame &&
-				result.Lifecycle == lifecycleFinished &&
-				result.User.Username == me.Username {
-				// push this into output for further filtering based on status
-				// and workflowID
-				output = append(output, result)
-			}
+			collect(result)
 		}
 	}
 	return output, nil
 }
 
-// Projects ... requests all projects visible to the current user
+// RunningBuilds ... returns the build summaries of the project that have not
+// finished, those queued, scheduled, waiting to run or running, only the most
+// recent page of build summaries is requested, since builds in flight are recent
+func (c *Client) RunningBuilds(project *Project, logger io.Writer) ([]*BuildSummaryOutput, error) {
+	results, err := c.BuildSummary(project, logger, &BuildSummaryInput{Limit: 100})
+	if err != nil {
+		return nil, err
+	}
+	var running []*BuildSummaryOutput
+	for _, result := range results {
+		if result.Lifecycle == lifecycleFinished || result.Lifecycle == lifecycleNotRun {
+			continue
+		}
+		running = append(running, result)
+	}
+	return running, nil
+}
+
+// BuildNotFoundError ... a build was not found when calling LatestSuccessfulBuild
+type BuildNotFoundError struct {
+	Message string
+}
+
+func (e *BuildNotFoundError) Error() string {
+	return e.Message
+}
+
+// LatestSuccessfulBuild ... returns the most recently stopped successful build
+// summary on the given branch of the project, build summaries are requested
+// newest first, so paging stops at the first page containing a successful build
+func (c *Client) LatestSuccessfulBuild(project *Project, logger io.Writer, branch string) (*BuildSummaryOutput, error) {
+	builds, err := c.latestSuccessfulBuilds(project, logger, branch, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(builds) == 0 {
+		return nil, &BuildNotFoundError{Message: fmt.Sprintf("failed to locate a successful build on branch %q for project: %s", branch, project.Reponame)}
+	}
+	return builds[0], nil
+}
+
+// BuildDurationDelta ... returns the run durations of the two most recently stopped
+// successful builds on the given branch of the project, current is the duration of
+// the latest build and previous the duration of the build before it
+func (c *Client) BuildDurationDelta(project *Project, logger io.Writer, branch string) (current, previous time.Duration, err error) {
+	builds, err := c.latestSuccessfulBuilds(project, logger, branch, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(builds) < 2 {
+		return 0, 0, &BuildNotFoundError{Message: fmt.Sprintf("failed to locate two successful builds on branch %q for project: %s", branch, project.Reponame)}
+	}
+	return builds[0].RunDuration(), builds[1].RunDuration(), nil
+}
+
+// latestSuccessfulBuilds ... used internally to return up to count of the most
+// recently stopped successful build summaries on the given branch, newest first,
+// paging stops at the first page where count successful builds have been found
+func (c *Client) latestSuccessfulBuilds(project *Project, logger io.Writer, branch string, count int) ([]*BuildSummaryOutput, error) {
+	var (
+		selector BuildSummaryInput
+		builds   []*BuildSummaryOutput
+	)
+	selector.Limit = 100
+	for resultNum := selector.Limit; resultNum == selector.Limit && len(builds) < count; selector.Offset += selector.Limit {
+		results, err := c.BuildSummary(project, logger, &selector)
+		if err != nil {
+			return nil, err
+		}
+		resultNum = len(results)
+		for _, result := range results {
+			if result.Branch != branch ||
+				result.Outcome != "success" ||
+				result.StoppedAt == nil {
+				continue
+			}
+			builds = append(builds, result)
+		}
+	}
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].StoppedAt.After(*builds[j].StoppedAt)
+	})
+	if len(builds) > count {
+		builds = builds[:count]
+	}
+	return builds, nil
+}
+
+// Projects ... requests all projects visible to the current user, the
+// projects are read from the cache instead when WithCache is used
 // https://circleci.com/docs/api/v1-reference/#projects
 func (c *Client) Projects(logger io.Writer) ([]*Project, error) {
 	var projects []*Project
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
+	if c.cache != nil && c.cache.get(c, cacheKeyProjects, &projects) {
+		return projects, nil
+	}
+	return c.RefreshProjects(logger)
+}
+
+// RefreshProjects ... requests all projects visible to the current user,
+// ignoring the cache when WithCache is used, the cache is updated with
+// the projects returned
+// https://circleci.com/docs/api/v1-reference/#projects
+func (c *Client) RefreshProjects(logger io.Writer) ([]*Project, error) {
+	var projects []*Project
+	err := c.retry(logger, func() error {
 		err := c.requester(c, "GET", "projects", nil, nil, &projects)
 		if err != nil {
 			logf(logger, "Projects failed, GET /projects -> %v", err)
@@ -414,6 +1166,9 @@ func (c *Client) Projects(logger io.Writer) ([]*Project, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.cache != nil {
+		c.cache.put(c, cacheKeyProjects, projects)
+	}
 	return projects, nil
 }
 
@@ -426,8 +1181,8 @@ type followResponse struct {
 // https://circleci.com/docs/api/v1-reference/#follow-project
 func (c *Client) FollowProject(project *Project, logger io.Writer) error {
 	var resp followResponse
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
-		url := fmt.Sprintf("project/%s/%s/%s/follow", project.Vcs, project.Username, project.Reponame)
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/follow", project.v1Path())
 		err := c.requester(c, "POST", url, nil, nil, &resp)
 		if err != nil {
 			logf(logger, "FollowProject failed, POST /%s -> %v", url, err)
@@ -437,6 +1192,10 @@ func (c *Client) FollowProject(project *Project, logger io.Writer) error {
 	if err != nil {
 		return err
 	}
+	// the cached projects no longer reflect the followed projects
+	if c.cache != nil {
+		c.cache.remove(c, cacheKeyProjects)
+	}
 	if !resp.Following {
 		return fmt.Errorf("attempted to follow %s, following property still false", project.VcsURL)
 	}
@@ -448,8 +1207,8 @@ func (c *Client) FollowProject(project *Project, logger io.Writer) error {
 // https://circleci.com/docs/api/v1-reference/#follow-project
 func (c *Client) UnfollowProject(project *Project, logger io.Writer) error {
 	var resp followResponse
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
-		url := fmt.Sprintf("project/%s/%s/%s/unfollow", project.Vcs, project.Username, project.Reponame)
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/unfollow", project.v1Path())
 		err := c.requester(c, "POST", url, nil, nil, &resp)
 		if err != nil {
 			logf(logger, "UnfollowProject failed, POST /%s -> %v", url, err)
@@ -459,12 +1218,72 @@ func (c *Client) UnfollowProject(project *Project, logger io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if c.cache != nil {
+		c.cache.remove(c, cacheKeyProjects)
+	}
 	if resp.Following {
 		return fmt.Errorf("attempted to unfollow %s, following property still true", project.VcsURL)
 	}
 	return nil
 }
 
+// ProjectDetails ... represents the object returned by calling
+// /project/:project-slug on the CircleCI API v2
+// https://circleci.com/docs/api/v2/#get-a-project
+type ProjectDetails struct {
+	ID               string         `json:"id"`
+	Slug             string         `json:"slug"`
+	Name             string         `json:"name"`
+	OrganizationName string         `json:"organization_name"`
+	VcsInfo          ProjectVcsInfo `json:"vcs_info"`
+}
+
+// ProjectVcsInfo ... represents the vcs_info property of the project details
+type ProjectVcsInfo struct {
+	VcsURL        string `json:"vcs_url"`
+	Provider      string `json:"provider"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// GetProject ... returns the *ProjectDetails of the project using the CircleCI API v2,
+// a *ProjectNotFollowedError is returned if CircleCI cannot find the project
+// https://circleci.com/docs/api/v2/#get-a-project
+func (c *Client) GetProject(project *Project, logger io.Writer) (*ProjectDetails, error) {
+	var details ProjectDetails
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%sproject/%s", apiV2Path, project.Slug())
+		err := c.requester(c, "GET", url, nil, nil, &details)
+		if err != nil {
+			logf(logger, "GetProject failed, GET %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, notFollowed(project, err)
+	}
+	return &details, nil
+}
+
+// messageResponse ... represents the generic message object
+// returned by many CircleCI API v2 endpoints
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// ClearBuildCache ... attempts to clear the build cache of the project
+// using the CircleCI API v2
+func (c *Client) ClearBuildCache(project *Project, logger io.Writer) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%sproject/%s/build_cache", apiV2Path, project.Slug())
+		err := c.requester(c, "DELETE", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "ClearBuildCache failed, DELETE %s -> %v", url, err)
+		}
+		return err
+	})
+}
+
 // ProjectNotFoundError ... a project was not found when calling FindProject
 type ProjectNotFoundError struct {
 	Message string
@@ -474,22 +1293,61 @@ func (p *ProjectNotFoundError) Error() string {
 	return p.Message
 }
 
+// ProjectNotFollowedError ... CircleCI returned not found when reading from a project,
+// usually because the project has not been followed by the current user
+type ProjectNotFollowedError struct {
+	Message string
+}
+
+func (p *ProjectNotFollowedError) Error() string {
+	return p.Message
+}
+
+// notFollowed ... used internally to return a *ProjectNotFollowedError
+// if CircleCI did not find the project, otherwise err is returned
+func notFollowed(project *Project, err error) error {
+	if isNotFound(err) {
+		return &ProjectNotFollowedError{Message: fmt.Sprintf("project %s was not found, it may not be followed by the current user -> %v", project.VcsURL, err)}
+	}
+	return err
+}
+
 // FindProject ... requests all projects visible to the current user
 // then calls the provided matcher on each project until the first match
-// is found or returns an error
+// is found or returns an error, when WithCache is used and no cached project
+// matches, the projects are requested again before returning an error
 func (c *Client) FindProject(logger io.Writer, matcher func(*Project) bool) (*Project, error) {
 	projects, err := c.Projects(logger)
 	if err != nil {
 		return nil, err
 	}
-	for _, p := range projects {
-		if matcher(p) {
+	if p := findProject(projects, matcher); p != nil {
+		return p, nil
+	}
+	// the cached projects may predate the project being followed
+	if c.cache != nil {
+		projects, err = c.RefreshProjects(logger)
+		if err != nil {
+			return nil, err
+		}
+		if p := findProject(projects, matcher); p != nil {
 			return p, nil
 		}
 	}
 	return nil, &ProjectNotFoundError{Message: "failed to locate a project using the given matcher"}
 }
 
+// findProject ... used internally to return the first project
+// matching matcher, or nil if no project matches
+func findProject(projects []*Project, matcher func(*Project) bool) *Project {
+	for _, p := range projects {
+		if matcher(p) {
+			return p
+		}
+	}
+	return nil
+}
+
 // User ... represents a genericized form of the user object
 // returned by calling /me or /buildNum on the CircleCI API v1.1
 // /me: https://circleci.com/docs/api/v1-reference/#user
@@ -501,11 +1359,15 @@ type User struct {
 	DisplayName string `json:"name"`
 }
 
-// Me ... returns the current user
+// Me ... returns the current user, the user is read from
+// the cache instead when WithCache is used
 // https://circleci.com/docs/api/v1-reference/#user
 func (c *Client) Me(logger io.Writer) (*User, error) {
 	var me User
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
+	if c.cache != nil && c.cache.get(c, cacheKeyMe, &me) {
+		return &me, nil
+	}
+	err := c.retry(logger, func() error {
 		err := c.requester(c, "GET", "me", nil, nil, &me)
 		if err != nil {
 			logf(logger, "Me failed, GET /me -> %v", err)
@@ -515,9 +1377,95 @@ func (c *Client) Me(logger io.Writer) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.cache != nil {
+		c.cache.put(c, cacheKeyMe, &me)
+	}
 	return &me, nil
 }
 
+// Usage ... represents the plan information returned by calling /me
+// on the CircleCI API v1.1, CircleCI does not expose a remaining credit
+// balance through the API, so only the plan and trial details are available
+// https://circleci.com/docs/api/v1-reference/#user
+type Usage struct {
+	//plan name, empty if the user is not on a paid plan
+	Plan string `json:"plan"`
+	//number of containers available to the user
+	Containers int `json:"containers"`
+	//maximum parallelism available to the user
+	Parallelism int `json:"parallelism"`
+	//negative once the trial has ended
+	DaysLeftInTrial int        `json:"days_left_in_trial"`
+	TrialEnd        *time.Time `json:"trial_end"`
+}
+
+// GetUsage ... returns the *Usage for the current user
+// https://circleci.com/docs/api/v1-reference/#user
+func (c *Client) GetUsage(logger io.Writer) (*Usage, error) {
+	var usage Usage
+	err := c.retry(logger, func() error {
+		err := c.requester(c, "GET", "me", nil, nil, &usage)
+		if err != nil {
+			logf(logger, "GetUsage failed, GET /me -> %v", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// currentUser ... used internally to return the user builds are attributed
+// to, if a build actor is configured using WithBuildActor, a *User with that
+// username is returned without requesting the current user, otherwise the
+// user is requested once and cached for the lifetime of the client
+func (c *Client) currentUser(logger io.Writer) (*User, error) {
+	if len(c.buildActor) > 0 {
+		return &User{Username: c.buildActor}, nil
+	}
+	state := c.shared()
+	state.meMu.Lock()
+	defer state.meMu.Unlock()
+	if state.me != nil {
+		return state.me, nil
+	}
+	me, err := c.Me(logger)
+	if err != nil {
+		return nil, err
+	}
+	state.me = me
+	return me, nil
+}
+
+// Organization ... represents an organization object returned by
+// calling /me/collaborations on the CircleCI API v2
+// https://circleci.com/docs/api/v2/#collaborations
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Vcs  string `json:"vcs_type"`
+	Slug string `json:"slug"`
+}
+
+// Organizations ... returns the organizations the current user is a collaborator of
+// https://circleci.com/docs/api/v2/#collaborations
+func (c *Client) Organizations(logger io.Writer) ([]*Organization, error) {
+	var orgs []*Organization
+	err := c.retry(logger, func() error {
+		url := apiV2Path + "me/collaborations"
+		err := c.requester(c, "GET", url, nil, nil, &orgs)
+		if err != nil {
+			logf(logger, "Organizations failed, GET %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
 // Build ... a genericized form of the object returned by
 // calling /$buildNum on the CircleCI API v1.1
 // https://circleci.com/docs/api/v1-reference/#build
@@ -541,20 +1489,150 @@ type Build struct {
 	//This may need to change later, CircleCI returns
 	//what appears to be an array, as a single object
 	Workflow *BuildWorkflow `json:"workflows"`
+	Steps    []*BuildStep   `json:"steps"`
+	//connection details, populated for builds rerun with SSH
+	Nodes []*BuildNode `json:"node"`
+	//explain problems with the build, such as configuration errors
+	Messages []*BuildMessage `json:"messages"`
+	//version of the CircleCI platform the build ran on, such as 2.0
+	Platform string `json:"platform"`
+	//executor and resource class the build ran on
+	Picard *BuildPicard `json:"picard"`
+}
+
+// BuildPicard ... represents the picard property of a build,
+// describing the executor and resource class the build ran on
+type BuildPicard struct {
+	// docker, machine, macos or windows
+	Executor      string              `json:"executor"`
+	ResourceClass *BuildResourceClass `json:"resource_class"`
+}
+
+// BuildResourceClass ... represents the resource_class property of a BuildPicard
+type BuildResourceClass struct {
+	// small, medium, large, xlarge, etc
+	Class string  `json:"class"`
+	CPU   float64 `json:"cpu"`
+	//memory in megabytes
+	RAM int `json:"ram"`
+}
+
+// Executor ... returns the executor the build ran on, such
+// as docker or macos, empty if CircleCI did not provide it
+func (b *Build) Executor() string {
+	if b.Picard == nil {
+		return ""
+	}
+	return b.Picard.Executor
+}
+
+// ResourceClass ... returns the resource class the build ran on,
+// such as medium or large, empty if CircleCI did not provide it
+func (b *Build) ResourceClass() string {
+	if b.Picard == nil || b.Picard.ResourceClass == nil {
+		return ""
+	}
+	return b.Picard.ResourceClass.Class
+}
+
+// BuildMessage ... represents a message object returned in the messages
+// property of a build, such as an explanation of a configuration error
+type BuildMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// BuildNode ... represents a node object returned in
+// the node property of a build
+type BuildNode struct {
+	PublicIPAddr string `json:"public_ip_addr"`
+	Port         int    `json:"port"`
+	Username     string `json:"username"`
+	SSHEnabled   bool   `json:"ssh_enabled"`
 }
 
 // GetBuild ... returns a *Build for the given buildNum, or an
-// error if the request to CircleCI failed
+// error if the request to CircleCI failed, the project does not need to be
+// followed, so a Project returned by ProjectFromURL may be used directly, but
+// a *ProjectNotFollowedError is returned if CircleCI cannot find it, when
+// the APIVersion is APIv2 the build is mapped from the job and has no steps
 func (c *Client) GetBuild(project *Project, logger io.Writer, buildNum int) (*Build, error) {
+	if c.APIVersion == APIv2 {
+		return c.getBuildV2(project, logger, buildNum)
+	}
 	var build Build
-	err := retrier(retrierIntervalSecs, retrierAttempts, func() error {
-		url := fmt.Sprintf("project/%s/%s/%s/%d", project.Vcs, project.Username, project.Reponame, buildNum)
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/%d", project.v1Path(), buildNum)
 		err := c.requester(c, "GET", url, nil, nil, &build)
 		if err != nil {
 			logf(logger, "GetBuild failed, GET /%s -> %v", url, err)
 		}
 		return err
 	})
+	if err != nil {
+		return nil, notFollowed(project, err)
+	}
+	return &build, nil
+}
+
+// GetBuildSummary ... returns the *BuildSummaryOutput of the given buildNum, the
+// summary form of the build without its steps, the project does not need to be
+// followed, so a Project returned by ProjectFromURL may be used directly, but a
+// *ProjectNotFollowedError is returned if CircleCI cannot find it, when the
+// APIVersion is APIv2 the summary is mapped from the job
+// https://circleci.com/docs/api/v1-reference/#build
+func (c *Client) GetBuildSummary(project *Project, logger io.Writer, buildNum int) (*BuildSummaryOutput, error) {
+	if c.APIVersion == APIv2 {
+		return c.getBuildSummaryV2(project, logger, buildNum)
+	}
+	var summary BuildSummaryOutput
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/%d", project.v1Path(), buildNum)
+		err := c.requester(c, "GET", url, nil, nil, &summary)
+		if err != nil {
+			logf(logger, "GetBuildSummary failed, GET /%s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, notFollowed(project, err)
+	}
+	return &summary, nil
+}
+
+// RetryBuildWithSSH ... reruns the build matching buildNum with SSH enabled, returns
+// the *BuildSummaryOutput of the new build, the SSH connection details are available
+// in the Nodes of the new build once it is running
+// https://circleci.com/docs/api/v1-reference/#retry-build
+func (c *Client) RetryBuildWithSSH(project *Project, logger io.Writer, buildNum int) (*BuildSummaryOutput, error) {
+	var summary BuildSummaryOutput
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/%d/ssh", project.v1Path(), buildNum)
+		err := c.requester(c, "POST", url, nil, nil, &summary)
+		if err != nil {
+			logf(logger, "RetryBuildWithSSH failed, POST /%s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// CancelBuild ... attempts to cancel the build matching buildNum,
+// returns the *Build after cancellation was requested
+// https://circleci.com/docs/api/v1-reference/#cancel-build
+func (c *Client) CancelBuild(project *Project, logger io.Writer, buildNum int) (*Build, error) {
+	var build Build
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/%d/cancel", project.v1Path(), buildNum)
+		err := c.requester(c, "POST", url, nil, nil, &build)
+		if err != nil {
+			logf(logger, "CancelBuild failed, POST /%s -> %v", url, err)
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -563,16 +1641,61 @@ func (c *Client) GetBuild(project *Project, logger io.Writer, buildNum int) (*Bu
 
 // API provides an interface to enable mocking the CircleCI REST client
 type API interface {
+	WithContext(context.Context) API
 	BuildProject(*Project, io.Writer, *BuildProjectInput, time.Duration) (*BuildSummaryOutput, error)
+	BuildKnownProject(*Project, io.Writer, *BuildProjectInput, time.Duration) (*BuildSummaryOutput, error)
 	WaitForProjectBuild(*Project, io.Writer, *BuildProjectInput, *BuildSummaryOutput, time.Duration, time.Duration, bool) error
+	WaitForProjectBuildEvents(*Project, io.Writer, *BuildProjectInput, *BuildSummaryOutput, time.Duration, time.Duration, bool, chan<- BuildEvent) error
 	BuildSummary(*Project, io.Writer, *BuildSummaryInput) ([]*BuildSummaryOutput, error)
 	FindBuildSummaries(*Project, io.Writer, *BuildProjectInput) ([]*BuildSummaryOutput, error)
+	BuildSummariesForBranches(*Project, io.Writer, []string) (map[string][]*BuildSummaryOutput, error)
+	LatestSuccessfulBuild(*Project, io.Writer, string) (*BuildSummaryOutput, error)
+	BuildDurationDelta(*Project, io.Writer, string) (time.Duration, time.Duration, error)
+	RunningBuilds(*Project, io.Writer) ([]*BuildSummaryOutput, error)
 	Projects(io.Writer) ([]*Project, error)
+	RefreshProjects(io.Writer) ([]*Project, error)
 	FollowProject(*Project, io.Writer) error
 	UnfollowProject(*Project, io.Writer) error
+	TeardownProject(*Project, io.Writer) error
+	ListEnvVars(*Project, io.Writer) ([]*EnvVar, error)
+	DeleteEnvVar(*Project, io.Writer, string) error
+	ListCheckoutKeys(*Project, io.Writer) ([]*CheckoutKey, error)
+	DeleteCheckoutKey(*Project, io.Writer, string) error
+	ClearBuildCache(*Project, io.Writer) error
 	FindProject(io.Writer, func(*Project) bool) (*Project, error)
+	GetProject(*Project, io.Writer) (*ProjectDetails, error)
 	Me(io.Writer) (*User, error)
+	Organizations(io.Writer) ([]*Organization, error)
+	GetUsage(io.Writer) (*Usage, error)
 	GetBuild(*Project, io.Writer, int) (*Build, error)
+	GetBuildSummary(*Project, io.Writer, int) (*BuildSummaryOutput, error)
+	CancelBuild(*Project, io.Writer, int) (*Build, error)
+	RetryBuildWithSSH(*Project, io.Writer, int) (*BuildSummaryOutput, error)
+	TriggerPipeline(*Project, io.Writer, *TriggerPipelineInput) (*Pipeline, error)
+	TriggerOnly(*Project, io.Writer, *BuildProjectInput) (*Pipeline, error)
+	TriggerBuild(*Project, io.Writer, *BuildProjectInput) (*BuildHandle, error)
+	PollHandle(*BuildHandle, io.Writer) (*BuildStatus, error)
+	GetPipelineConfig(string, io.Writer) (*PipelineConfig, error)
+	PipelineWorkflows(string, io.Writer) ([]*Workflow, error)
+	GetPipelineByNumber(*Project, io.Writer, int) (*Pipeline, error)
+	GetPipelineBuilds(*Project, io.Writer, int) ([]*BuildSummaryOutput, error)
+	WaitForPipeline(*Project, io.Writer, string, time.Duration) (*PipelineResult, error)
+	GetWorkflow(string, io.Writer) (*Workflow, error)
+	WaitForWorkflow(*Project, io.Writer, string, time.Duration) (*Workflow, error)
+	WorkflowJobs(string, io.Writer) ([]*WorkflowJob, error)
+	GetJob(*Project, io.Writer, int) (*JobDetail, error)
+	GetWorkflowJobStatus(string, string, io.Writer) (*WorkflowJob, error)
+	ApproveJob(string, string, io.Writer) error
+	BuildTests(*Project, io.Writer, int) ([]*TestResult, error)
+	RunBuild(*RunBuildInput) (*BuildResult, error)
+	CancelWorkflow(string, io.Writer) error
+	RerunWorkflow(string, io.Writer, bool) (string, error)
+	ListContexts(string, io.Writer) ([]*Context, error)
+	CreateContext(string, io.Writer, string) (*Context, error)
+	DeleteContext(string, io.Writer) error
+	ListContextEnvVars(string, io.Writer) ([]*ContextEnvVar, error)
+	AddContextEnvVar(string, io.Writer, string, string) (*ContextEnvVar, error)
+	RemoveContextEnvVar(string, io.Writer, string) error
 }
 
 var _ API = (*Client)(nil)
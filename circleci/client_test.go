@@ -1,13 +1,18 @@
 package circleci
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +23,17 @@ func boolPtr(b bool) *bool {
 	return &b
 }
 
+// newTestClient ... returns a *Client sending every request to requester,
+// or CircleCI if requester is nil, failed requests are not retried
+func newTestClient(t *testing.T, requester requestFunc) *Client {
+	t.Helper()
+	c := NewClient(&http.Client{}, "", WithRetry(1, 0))
+	if requester != nil {
+		c.requester = requester
+	}
+	return c
+}
+
 //nolint:gochecknoglobals
 var apiStub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	var resp string
@@ -44,6 +60,182 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestUserAgent(t *testing.T) {
+	tt := map[string]struct {
+		opts     []Option
+		expected string
+	}{
+		"default":  {expected: "grace-circleci-builder/" + Version},
+		"override": {opts: []Option{WithUserAgent("test/1.0")}, expected: "test/1.0"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var actual string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actual = r.UserAgent()
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			assert.NilError(t, err)
+			c := NewClient(nil, "", tc.opts...)
+			c.baseURL = u
+			var output User
+			err = request(c, "GET", "me", nil, nil, &output)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	var actual http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual = r.Header
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	c := NewClient(nil, "", WithHeaders(http.Header{
+		"X-Proxy-Token": {"secret"},
+		"Content-Type":  {"text/plain"},
+	}))
+	c.baseURL = u
+	var output User
+	err = request(c, "POST", "me", nil, &output, &output)
+	assert.NilError(t, err)
+	assert.Equal(t, "secret", actual.Get("X-Proxy-Token"))
+	assert.DeepEqual(t, []string{"application/json"}, actual["Content-Type"])
+	assert.DeepEqual(t, []string{"application/json"}, actual["Accept"])
+}
+
+func TestRequestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	defer close(done)
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	c := NewClient(nil, "", WithRetry(1, 0))
+	c.baseURL = u
+	c.RequestTimeout = 50 * time.Millisecond
+	start := time.Now()
+	_, err = c.Me(os.Stdout)
+	assert.ErrorContains(t, err, "context deadline exceeded")
+	assert.Assert(t, time.Since(start) < time.Second)
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Sunset", "Wed, 01 Jun 2022 00:00:00 GMT")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	var global, buf bytes.Buffer
+	log.SetOutput(&global)
+	defer log.SetOutput(os.Stderr)
+	c := NewClient(nil, "")
+	c.baseURL = u
+	var output User
+	for i := 0; i < 3; i++ {
+		err = c.retry(&buf, func() error {
+			return request(c, "GET", "me", nil, nil, &output)
+		})
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, "", global.String())
+	assert.Equal(t, 1, strings.Count(buf.String(), "Deprecation: true"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "Sunset: Wed, 01 Jun 2022 00:00:00 GMT"))
+	assert.Assert(t, strings.Contains(buf.String(), "deprecation notice for GET me"))
+}
+
+type testRoundTripper struct{}
+
+func (testRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func TestConnectionLimits(t *testing.T) {
+	t.Run("default client limits connections", func(t *testing.T) {
+		c := NewClient(nil, "")
+		tr, ok := c.client.Transport.(*http.Transport)
+		assert.Assert(t, ok, "expected *http.Transport, got %T", c.client.Transport)
+		assert.Equal(t, defaultMaxIdleConnsPerHost, tr.MaxIdleConnsPerHost)
+		assert.Equal(t, defaultMaxConnsPerHost, tr.MaxConnsPerHost)
+	})
+	t.Run("provided client is used", func(t *testing.T) {
+		hc := &http.Client{Transport: testRoundTripper{}}
+		c := NewClient(hc, "")
+		assert.Equal(t, hc, c.client)
+	})
+	t.Run("custom transport limits", func(t *testing.T) {
+		hc := &http.Client{Transport: &http.Transport{}}
+		c := NewClient(hc, "", WithConnectionLimits(1, 2))
+		tr := c.client.Transport.(*http.Transport)
+		assert.Equal(t, 1, tr.MaxIdleConnsPerHost)
+		assert.Equal(t, 2, tr.MaxConnsPerHost)
+		assert.Equal(t, 0, hc.Transport.(*http.Transport).MaxConnsPerHost)
+	})
+	t.Run("custom round tripper is not replaced", func(t *testing.T) {
+		hc := &http.Client{Transport: testRoundTripper{}}
+		c := NewClient(hc, "", WithConnectionLimits(1, 2))
+		assert.Equal(t, testRoundTripper{}, c.client.Transport)
+	})
+}
+
+func TestWithProxy(t *testing.T) {
+	t.Run("requests are sent through the proxy", func(t *testing.T) {
+		var actual string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actual = r.URL.String()
+			_, _ = w.Write([]byte(`{"login": "org"}`))
+		}))
+		defer proxy.Close()
+		c := NewClient(nil, "", WithProxy(proxy.URL))
+		c.baseURL = &url.URL{Scheme: "http", Host: "circleci.invalid", Path: "/api/v1.1/"}
+		me, err := c.Me(os.Stdout)
+		assert.NilError(t, err)
+		assert.Equal(t, "org", me.Username)
+		assert.Equal(t, "http://circleci.invalid/api/v1.1/me?circle-token=", actual)
+	})
+	t.Run("socks5 proxy", func(t *testing.T) {
+		c := NewClient(nil, "", WithProxy("socks5://localhost:1080"))
+		tr := c.client.Transport.(*http.Transport)
+		u, err := tr.Proxy(nil)
+		assert.NilError(t, err)
+		assert.Equal(t, "socks5://localhost:1080", u.String())
+		// connection limits are preserved
+		assert.Equal(t, defaultMaxConnsPerHost, tr.MaxConnsPerHost)
+	})
+	t.Run("invalid proxy", func(t *testing.T) {
+		c := NewClient(nil, "", WithProxy("localhost"))
+		_, err := c.client.Transport.(*http.Transport).Proxy(nil)
+		assert.Error(t, err, `proxy URL must include a scheme and host: "localhost"`)
+	})
+}
+
+func TestWithRetry(t *testing.T) {
+	c := NewClient(nil, "", WithRetry(2, 0))
+	var count int
+	err := c.retry(os.Stdout, func() error {
+		count++
+		return fmt.Errorf("test error")
+	})
+	assert.Error(t, err, "test error")
+	assert.Equal(t, 2, count)
+}
+
 func TestFollow(t *testing.T) {
 	var c *Client
 	token := os.Getenv("CIRCLECI_TOKEN")
@@ -52,11 +244,8 @@ func TestFollow(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		c = &Client{
-			client:    &http.Client{},
-			baseURL:   u,
-			requester: request,
-		}
+		c = newTestClient(t, request)
+		c.baseURL = u
 	} else {
 		c = NewClient(nil, token)
 	}
@@ -192,8 +381,6 @@ func testBuildProjectRequest(t *testing.T, bpoStatus int, meUser string, bsoUser
 
 // nolint: dupl
 func TestFollowProject(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	project := Project{
 		Username: "org",
 		Reponame: "test1",
@@ -229,12 +416,10 @@ func TestFollowProject(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					output.(*followResponse).Following = tc.Following
-					return tc.Err
-				}}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				output.(*followResponse).Following = tc.Following
+				return tc.Err
+			})
 
 			err := client.FollowProject(&project, os.Stdout)
 			if tc.Expected == "" {
@@ -248,8 +433,6 @@ func TestFollowProject(t *testing.T) {
 
 // nolint: funlen, gomnd
 func TestWaitForProjectBuild(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	project := Project{
 		Username: "org",
 		Reponame: "test1",
@@ -257,14 +440,15 @@ func TestWaitForProjectBuild(t *testing.T) {
 		VcsURL:   "https://github.com/org/test1",
 	}
 	tt := map[string]struct {
-		jobTimeout  time.Duration
-		waitTimeout time.Duration
-		build       Build
-		user        User
-		summary     string
-		Err         error
-		Expected    string
-		slow        bool
+		jobTimeout     time.Duration
+		waitTimeout    time.Duration
+		build          Build
+		user           User
+		summary        string
+		continueOnFail bool
+		Err            error
+		Expected       string
+		slow           bool
 	}{
 		"job timeout exceeded": {
 			jobTimeout:  time.Duration(1) * time.Second,
@@ -285,6 +469,49 @@ func TestWaitForProjectBuild(t *testing.T) {
 			Err:      nil,
 			Expected: "build test1 [0] failed",
 		},
+		"job canceled": {
+			jobTimeout:  time.Duration(1) * time.Minute,
+			waitTimeout: time.Minute,
+			build: Build{
+				Lifecycle: "finished",
+				Status:    "canceled",
+				Failed:    boolPtr(true),
+			},
+			Err:      nil,
+			Expected: "build test1 [0] was canceled",
+		},
+		"job infrastructure failure": {
+			jobTimeout:  time.Duration(1) * time.Minute,
+			waitTimeout: time.Minute,
+			build: Build{
+				Lifecycle: "finished",
+				Status:    "infrastructure_fail",
+				Failed:    boolPtr(true),
+			},
+			Err:      nil,
+			Expected: "build test1 [0] failed due to a CircleCI infrastructure failure",
+		},
+		"job failed with continue on fail": {
+			jobTimeout:  time.Duration(1) * time.Minute,
+			waitTimeout: time.Minute,
+			build: Build{
+				Lifecycle: "finished",
+				Failed:    boolPtr(true),
+			},
+			continueOnFail: true,
+			Expected:       "",
+		},
+		"job infrastructure failure with continue on fail": {
+			jobTimeout:  time.Duration(1) * time.Minute,
+			waitTimeout: time.Minute,
+			build: Build{
+				Lifecycle: "finished",
+				Status:    "infrastructure_fail",
+				Failed:    boolPtr(true),
+			},
+			continueOnFail: true,
+			Expected:       "build test1 [0] failed due to a CircleCI infrastructure failure",
+		},
 		"could not obtain workflow details": {
 			jobTimeout:  time.Duration(1) * time.Minute,
 			waitTimeout: time.Minute,
@@ -323,27 +550,26 @@ func TestWaitForProjectBuild(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					switch v := output.(type) {
-					case *Build:
-						output.(*Build).Lifecycle = tc.build.Lifecycle
-						output.(*Build).Failed = tc.build.Failed
-						output.(*Build).Workflow = tc.build.Workflow
-						output.(*Build).BuildNum = tc.build.BuildNum
-						//Change values for second query
-						tc.build.Lifecycle = "finished"
-						tc.build.Failed = boolPtr(false)
-					case *User:
-						output.(*User).Username = project.Username
-					case *[]*BuildSummaryOutput:
-						err := json.Unmarshal([]byte(tc.summary), output)
-						if err != nil {
-							return fmt.Errorf("failed to decode response: %v", err)
-						}
-						//Change values for second query
-						tc.summary = `[{
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch v := output.(type) {
+				case *Build:
+					output.(*Build).Lifecycle = tc.build.Lifecycle
+					output.(*Build).Failed = tc.build.Failed
+					output.(*Build).Status = tc.build.Status
+					output.(*Build).Workflow = tc.build.Workflow
+					output.(*Build).BuildNum = tc.build.BuildNum
+					//Change values for second query
+					tc.build.Lifecycle = "finished"
+					tc.build.Failed = boolPtr(false)
+				case *User:
+					output.(*User).Username = project.Username
+				case *[]*BuildSummaryOutput:
+					err := json.Unmarshal([]byte(tc.summary), output)
+					if err != nil {
+						return fmt.Errorf("failed to decode response: %v", err)
+					}
+					//Change values for second query
+					tc.summary = `[{
 							"build_num": 42,
 							"username": "org",
 							"lifecycle": "finished",
@@ -353,15 +579,15 @@ func TestWaitForProjectBuild(t *testing.T) {
 							"user": {"login": "org"},
 							"status": "success"
 						}]`
-						tc.build.Lifecycle = "finished"
-					default:
-						return fmt.Errorf("unknown output type: %T", v)
-					}
-					return tc.Err
-				}}
+					tc.build.Lifecycle = "finished"
+				default:
+					return fmt.Errorf("unknown output type: %T", v)
+				}
+				return tc.Err
+			})
 			in := &BuildProjectInput{}
 			sum := &BuildSummaryOutput{}
-			err := client.WaitForProjectBuild(&project, os.Stdout, in, sum, tc.jobTimeout, tc.waitTimeout, false)
+			err := client.WaitForProjectBuild(&project, os.Stdout, in, sum, tc.jobTimeout, tc.waitTimeout, tc.continueOnFail)
 			if tc.Expected == "" {
 				assert.NilError(t, err)
 			} else {
@@ -373,8 +599,6 @@ func TestWaitForProjectBuild(t *testing.T) {
 
 // nolint: funlen, gomnd, dupl
 func TestBuildSummary(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	project := Project{
 		Username: "org",
 		Reponame: "test1",
@@ -441,15 +665,13 @@ func TestBuildSummary(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					err := json.Unmarshal([]byte(tc.resp), output)
-					if err != nil {
-						return fmt.Errorf("failed to decode response: %v", err)
-					}
-					return tc.err
-				}}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				err := json.Unmarshal([]byte(tc.resp), output)
+				if err != nil {
+					return fmt.Errorf("failed to decode response: %v", err)
+				}
+				return tc.err
+			})
 			actual, err := client.BuildSummary(&project, os.Stdout, &tc.in)
 			if tc.expectedErr == "" {
 				assert.NilError(t, err)
@@ -463,8 +685,6 @@ func TestBuildSummary(t *testing.T) {
 
 // nolint: funlen, gomnd, dupl
 func TestFindBuildSummaries(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	project := Project{
 		Username: "org",
 		Reponame: "test1",
@@ -523,21 +743,19 @@ func TestFindBuildSummaries(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					switch v := output.(type) {
-					case *User:
-						output.(*User).Username = project.Username
-					default:
-						_ = v // eat v
-						err := json.Unmarshal([]byte(tc.resp), output)
-						if err != nil {
-							return fmt.Errorf("failed to decode response: %v", err)
-						}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch v := output.(type) {
+				case *User:
+					output.(*User).Username = project.Username
+				default:
+					_ = v // eat v
+					err := json.Unmarshal([]byte(tc.resp), output)
+					if err != nil {
+						return fmt.Errorf("failed to decode response: %v", err)
 					}
-					return tc.err
-				}}
+				}
+				return tc.err
+			})
 			actual, err := client.FindBuildSummaries(&project, os.Stdout, &tc.in)
 			if tc.expectedErr == "" {
 				assert.NilError(t, err)
@@ -549,6 +767,258 @@ func TestFindBuildSummaries(t *testing.T) {
 	}
 }
 
+func TestLatestSuccessfulBuild(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "gh",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	resp := `[{
+		"build_num": 41,
+		"branch": "master",
+		"outcome": "success",
+		"stop_time": "2020-01-01T00:00:00Z"
+	},{
+		"build_num": 42,
+		"branch": "master",
+		"outcome": "success",
+		"stop_time": "2020-01-02T00:00:00Z"
+	},{
+		"build_num": 43,
+		"branch": "master",
+		"outcome": "failed",
+		"stop_time": "2020-01-03T00:00:00Z"
+	},{
+		"build_num": 44,
+		"branch": "feature",
+		"outcome": "success",
+		"stop_time": "2020-01-04T00:00:00Z"
+	}]`
+	tt := map[string]struct {
+		branch      string
+		expectedErr string
+		expected    int
+	}{
+		"latest success": {branch: "master", expected: 42},
+		"other branch":   {branch: "feature", expected: 44},
+		"not found": {
+			branch:      "release",
+			expectedErr: `failed to locate a successful build on branch "release" for project: test1`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				return json.Unmarshal([]byte(resp), output)
+			})
+			actual, err := client.LatestSuccessfulBuild(&project, os.Stdout, tc.branch)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+				assert.Equal(t, tc.expected, actual.BuildNum)
+			} else {
+				_, ok := err.(*BuildNotFoundError)
+				assert.Assert(t, ok, "expected *BuildNotFoundError, got %T", err)
+				assert.Error(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestBuildDurationDelta(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "gh"}
+	resp := `[{
+		"build_num": 41,
+		"branch": "master",
+		"outcome": "success",
+		"start_time": "2020-01-01T00:00:00Z",
+		"stop_time": "2020-01-01T00:05:00Z"
+	},{
+		"build_num": 42,
+		"branch": "master",
+		"outcome": "success",
+		"start_time": "2020-01-02T00:00:00Z",
+		"stop_time": "2020-01-02T00:10:00Z"
+	},{
+		"build_num": 43,
+		"branch": "master",
+		"outcome": "failed",
+		"start_time": "2020-01-03T00:00:00Z",
+		"stop_time": "2020-01-03T00:01:00Z"
+	},{
+		"build_num": 44,
+		"branch": "feature",
+		"outcome": "success",
+		"start_time": "2020-01-04T00:00:00Z",
+		"stop_time": "2020-01-04T00:01:00Z"
+	}]`
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		return json.Unmarshal([]byte(resp), output)
+	})
+	current, previous, err := client.BuildDurationDelta(&project, os.Stdout, "master")
+	assert.NilError(t, err)
+	assert.Equal(t, 10*time.Minute, current)
+	assert.Equal(t, 5*time.Minute, previous)
+
+	_, _, err = client.BuildDurationDelta(&project, os.Stdout, "feature")
+	_, ok := err.(*BuildNotFoundError)
+	assert.Assert(t, ok, "expected *BuildNotFoundError, got %T", err)
+	assert.Error(t, err, `failed to locate two successful builds on branch "feature" for project: test1`)
+}
+
+func TestWaitForBuildActiveJobTimeout(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	for _, active := range []bool{false, true} {
+		active := active
+		t.Run(fmt.Sprintf("active %t", active), func(t *testing.T) {
+			var polls int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				polls++
+				lifecycle := "queued"
+				switch {
+				case polls == 6:
+					lifecycle = "running"
+				case polls > 6:
+					lifecycle = "finished"
+				default:
+					// queued for longer than the job timeout
+					time.Sleep(20 * time.Millisecond)
+				}
+				return json.Unmarshal([]byte(fmt.Sprintf(`{"build_num": 42, "lifecycle": %q}`, lifecycle)), output)
+			})
+			client.PollInterval = time.Millisecond
+			client.ActiveJobTimeout = active
+			build, err := client.waitForBuild(&project, os.Stdout, 42, 50*time.Millisecond, nil)
+			if !active {
+				_, ok := err.(*JobTimeoutError)
+				assert.Assert(t, ok, "expected *JobTimeoutError, got %T", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, "finished", build.Lifecycle)
+		})
+	}
+}
+
+func TestBuildKnownProject(t *testing.T) {
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "project/github/org/test1/build", path)
+		return RequestError{Code: http.StatusNotFound, Message: "Project not found"}
+	})
+	_, err := client.BuildKnownProject(&Project{Username: "org", Reponame: "test1"}, os.Stdout, &BuildProjectInput{}, time.Second)
+	assert.Error(t, err, "a known project must have a vcs, username and reponame")
+
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github", VcsURL: "https://github.com/org/test1"}
+	_, err = client.BuildKnownProject(project, os.Stdout, &BuildProjectInput{Branch: "master"}, time.Second)
+	_, ok := err.(*ProjectNotFollowedError)
+	assert.Assert(t, ok, "expected *ProjectNotFollowedError, got %T", err)
+}
+
+func TestWaitForBuildContext(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var polls int
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		polls++
+		return json.Unmarshal([]byte(`{"build_num": 42, "lifecycle": "running"}`), output)
+	})
+	client.PollInterval = 5 * time.Millisecond
+	c := client.WithContext(ctx).(*Client)
+	_, err := c.waitForBuild(&project, os.Stdout, 42, time.Minute, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// the build is no longer polled once the context is done
+	stopped := polls
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, polls)
+}
+
+func TestBuildSucceeded(t *testing.T) {
+	tt := map[string]struct {
+		build     Build
+		succeeded func(*Build) bool
+		expected  bool
+	}{
+		"success":               {build: Build{Status: "success", Failed: boolPtr(false)}, expected: true},
+		"failed":                {build: Build{Status: "failed", Failed: boolPtr(true)}},
+		"infrastructure failed": {build: Build{Status: "infrastructure_fail", Failed: boolPtr(false)}},
+		"failed accepted by predicate": {
+			build:     Build{Status: "failed", Failed: boolPtr(true)},
+			succeeded: func(b *Build) bool { return b.Status != "infrastructure_fail" },
+			expected:  true,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := &Client{BuildSucceeded: tc.succeeded}
+			assert.Equal(t, tc.expected, client.buildSucceeded(&tc.build))
+		})
+	}
+}
+
+func TestWaitForBuildQueueTimeout(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		lifecycles  []string
+		expectedErr string
+	}{
+		"stuck in queue": {
+			lifecycles:  []string{"queued", "scheduled", "not_running"},
+			expectedErr: "build test1 [42] never started (stuck in queue for 30ms)",
+		},
+		"started": {
+			lifecycles: []string{"queued", "running", "running", "running", "finished"},
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var polls int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				lifecycle := tc.lifecycles[len(tc.lifecycles)-1]
+				if polls < len(tc.lifecycles) {
+					lifecycle = tc.lifecycles[polls]
+				}
+				polls++
+				time.Sleep(15 * time.Millisecond)
+				return json.Unmarshal([]byte(fmt.Sprintf(`{"build_num": 42, "lifecycle": %q}`, lifecycle)), output)
+			})
+			client.PollInterval = time.Millisecond
+			client.QueueTimeout = 30 * time.Millisecond
+			build, err := client.waitForBuild(&project, os.Stdout, 42, time.Minute, nil)
+			if len(tc.expectedErr) > 0 {
+				assert.Error(t, err, tc.expectedErr)
+				_, ok := err.(*QueueTimeoutError)
+				assert.Assert(t, ok, "expected *QueueTimeoutError, got %T", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, "finished", build.Lifecycle)
+		})
+	}
+}
+
+func TestRunningBuilds(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "project/github/org/test1", path)
+		assert.Equal(t, "100", params.Get("limit"))
+		return json.Unmarshal([]byte(`[
+				{"build_num": 45, "lifecycle": "queued"},
+				{"build_num": 44, "lifecycle": "running"},
+				{"build_num": 43, "lifecycle": "not_run"},
+				{"build_num": 42, "lifecycle": "finished"}
+			]`), output)
+	})
+	running, err := client.RunningBuilds(&project, os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(running))
+	assert.Equal(t, 45, running[0].BuildNum)
+	assert.Equal(t, 44, running[1].BuildNum)
+}
+
 // nolint: funlen, gomnd
 func TestFilterBuildSummariesByWorkflowStatus(t *testing.T) {
 	tt := map[string]struct {
@@ -635,10 +1105,94 @@ func TestFilterBuildSummariesByWorkflowStatus(t *testing.T) {
 	}
 }
 
+func TestFilterBuildSummariesByLatestRetry(t *testing.T) {
+	retryOf := func(n int) *int { return &n }
+	in := []*BuildSummaryOutput{
+		{BuildNum: 44, Status: "failed", RetryOf: retryOf(43)},
+		{BuildNum: 43, Status: "retried", RetryOf: retryOf(41)},
+		{BuildNum: 42, Status: "success"},
+		{BuildNum: 41, Status: "retried"},
+	}
+	actual := FilterBuildSummariesByLatestRetry(in)
+	assert.DeepEqual(t, []*BuildSummaryOutput{in[0], in[2]}, actual)
+	assert.Assert(t, FilterBuildSummariesByLatestRetry(nil) == nil)
+}
+
+func TestFilterBuildSummariesByDateRange(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	before, during, after := start.Add(-time.Hour), start.Add(time.Hour), end.Add(time.Hour)
+	in := []*BuildSummaryOutput{
+		{BuildNum: 41, QueuedAt: &before},
+		{BuildNum: 42, QueuedAt: &during},
+		{BuildNum: 43, QueuedAt: &after},
+		{BuildNum: 44},
+		{BuildNum: 45, QueuedAt: &start},
+		{BuildNum: 46, QueuedAt: &end},
+	}
+	tt := map[string]struct {
+		in       []*BuildSummaryOutput
+		expected []*BuildSummaryOutput
+	}{
+		"nil input": {},
+		"within range": {
+			in:       in,
+			expected: []*BuildSummaryOutput{in[1], in[4], in[5]},
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual := FilterBuildSummariesByDateRange(tc.in, start, end)
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFilterBuildSummariesByAuthor(t *testing.T) {
+	var in []*BuildSummaryOutput
+	err := json.Unmarshal([]byte(`[{
+		"build_num": 41,
+		"author_name": "Jane Doe",
+		"author_email": "jane.doe@example.com",
+		"subject": "Fix deploy script",
+		"body": "Closes #12"
+	},{
+		"build_num": 42,
+		"author_name": "John Doe",
+		"author_email": "john.doe@example.com",
+		"subject": "Merge pull request #13 from org/feature",
+		"body": "Add deploy workflow"
+	}]`), &in)
+	assert.NilError(t, err)
+	tt := map[string]struct {
+		author   string
+		message  string
+		expected []*BuildSummaryOutput
+	}{
+		"author name":            {author: "jane doe", expected: in[:1]},
+		"author email":           {author: "john.doe@example.com", expected: in[1:]},
+		"unknown author":         {author: "nobody"},
+		"commit message subject": {message: "Fix deploy", expected: in[:1]},
+		"commit message body":    {message: "deploy", expected: in},
+		"unknown commit message": {message: "revert"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var actual []*BuildSummaryOutput
+			if len(tc.author) > 0 {
+				actual = FilterBuildSummariesByAuthor(in, tc.author)
+			} else {
+				actual = FilterBuildSummariesByCommitMessage(in, tc.message)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
 // nolint: funlen
 func TestProjects(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	tt := map[string]struct {
 		resp        string
 		err         error
@@ -691,15 +1245,13 @@ func TestProjects(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					err := json.Unmarshal([]byte(tc.resp), output)
-					if err != nil {
-						return fmt.Errorf("failed to decode response: %v", err)
-					}
-					return tc.err
-				}}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				err := json.Unmarshal([]byte(tc.resp), output)
+				if err != nil {
+					return fmt.Errorf("failed to decode response: %v", err)
+				}
+				return tc.err
+			})
 			actual, err := client.Projects(os.Stdout)
 			if tc.expectedErr == "" {
 				assert.NilError(t, err)
@@ -713,8 +1265,6 @@ func TestProjects(t *testing.T) {
 
 // nolint: dupl
 func TestUnfollowProject(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	project := Project{
 		Username: "org",
 		Reponame: "test1",
@@ -750,12 +1300,10 @@ func TestUnfollowProject(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					output.(*followResponse).Following = tc.Following
-					return tc.Err
-				}}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				output.(*followResponse).Following = tc.Following
+				return tc.Err
+			})
 
 			err := client.UnfollowProject(&project, os.Stdout)
 			if tc.Expected == "" {
@@ -767,10 +1315,53 @@ func TestUnfollowProject(t *testing.T) {
 	}
 }
 
+func TestClearBuildCache(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := []struct {
+		Name     string
+		Err      error
+		Expected string
+		slow     bool
+	}{{
+		Name:     "successfully clear build cache",
+		Err:      nil,
+		Expected: "",
+	}, {
+		Name:     "error from requester function",
+		Err:      fmt.Errorf("test error"),
+		Expected: "test error",
+		slow:     true,
+	}}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.slow && testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				assert.Equal(t, "DELETE", method)
+				assert.Equal(t, "/api/v2/project/gh/org/test1/build_cache", path)
+				output.(*messageResponse).Message = "Build cache cleared"
+				return tc.Err
+			})
+
+			err := client.ClearBuildCache(&project, os.Stdout)
+			if tc.Expected == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.Expected)
+			}
+		})
+	}
+}
+
 // nolint: funlen
 func TestFindProject(t *testing.T) {
-	// Speed up testing by reducing retry interfaval and attempts
-	retrierIntervalSecs, retrierAttempts = 3, 1
 	tt := map[string]struct {
 		URL         string
 		resp        string
@@ -847,15 +1438,13 @@ func TestFindProject(t *testing.T) {
 			if tc.slow && testing.Short() {
 				t.Skip("skipping test in short mode.")
 			}
-			client := &Client{
-				client: &http.Client{},
-				requester: func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
-					err := json.Unmarshal([]byte(tc.resp), output)
-					if err != nil {
-						return fmt.Errorf("failed to decode response: %v", err)
-					}
-					return tc.err
-				}}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				err := json.Unmarshal([]byte(tc.resp), output)
+				if err != nil {
+					return fmt.Errorf("failed to decode response: %v", err)
+				}
+				return tc.err
+			})
 			actual, err := client.FindProject(os.Stdout, func(p *Project) bool {
 				return p.VcsURL == tc.URL
 			})
@@ -869,6 +1458,248 @@ func TestFindProject(t *testing.T) {
 	}
 }
 
+func TestOrganizations(t *testing.T) {
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "/api/v2/me/collaborations", path)
+		return json.Unmarshal([]byte(`[{
+				"id": "1",
+				"vcs_type": "github",
+				"name": "org",
+				"avatar_url": "https://avatars.githubusercontent.com/u/1",
+				"slug": "gh/org"
+			}]`), output)
+	})
+	actual, err := client.Organizations(os.Stdout)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []*Organization{{ID: "1", Name: "org", Vcs: "github", Slug: "gh/org"}}, actual)
+}
+
+func TestBuildSummariesForBranches(t *testing.T) {
+	project, err := ProjectFromURL("https://github.com/org/test1")
+	assert.NilError(t, err)
+	tt := map[string]struct {
+		pageSize int
+		expected map[string]int
+		requests []string
+	}{
+		"full page": {
+			pageSize: 100,
+			expected: map[string]int{"master": 50, "develop": 50, "release": 1},
+			requests: []string{"project/github/org/test1", "project/github/org/test1/tree/gone", "project/github/org/test1/tree/release"},
+		},
+		"partial page": {
+			pageSize: 10,
+			expected: map[string]int{"master": 5, "develop": 5},
+			requests: []string{"project/github/org/test1"},
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var requests []string
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				requests = append(requests, path)
+				var summaries []*BuildSummaryOutput
+				switch path {
+				case "project/github/org/test1":
+					for i := 0; i < tc.pageSize; i++ {
+						branch := "master"
+						if i%2 == 1 {
+							branch = "develop"
+						}
+						summaries = append(summaries, &BuildSummaryOutput{BuildNum: i, Branch: branch})
+					}
+				case "project/github/org/test1/tree/release":
+					summaries = append(summaries, &BuildSummaryOutput{BuildNum: 1000, Branch: "release"})
+				}
+				*(output.(*[]*BuildSummaryOutput)) = summaries
+				return nil
+			})
+			actual, err := client.BuildSummariesForBranches(project, os.Stdout, []string{"master", "develop", "release", "gone"})
+			assert.NilError(t, err)
+			counts := make(map[string]int)
+			for branch, summaries := range actual {
+				counts[branch] = len(summaries)
+			}
+			assert.DeepEqual(t, tc.expected, counts)
+			sort.Strings(requests)
+			assert.DeepEqual(t, tc.requests, requests)
+		})
+	}
+}
+
+func TestBuildSummaryBranch(t *testing.T) {
+	project, err := ProjectFromURL("https://github.com/org/test1")
+	assert.NilError(t, err)
+	tt := map[string]struct {
+		input    *BuildSummaryInput
+		expected string
+	}{
+		"no input":          {expected: "/api/v1.1/project/github/org/test1"},
+		"no branch":         {input: &BuildSummaryInput{Limit: 1}, expected: "/api/v1.1/project/github/org/test1"},
+		"branch":            {input: &BuildSummaryInput{Branch: "master"}, expected: "/api/v1.1/project/github/org/test1/tree/master"},
+		"branch with slash": {input: &BuildSummaryInput{Branch: "feature/x"}, expected: "/api/v1.1/project/github/org/test1/tree/feature%2Fx"},
+		"branch with hash":  {input: &BuildSummaryInput{Branch: "fix#1"}, expected: "/api/v1.1/project/github/org/test1/tree/fix%231"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var actual string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actual = r.URL.EscapedPath()
+				_, _ = w.Write([]byte(`[]`))
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/api/v1.1/")
+			assert.NilError(t, err)
+			c := NewClient(nil, "", WithRetry(1, 0))
+			c.baseURL = u
+			_, err = c.BuildSummary(project, os.Stdout, tc.input)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestNotFollowed(t *testing.T) {
+	project, err := ProjectFromURL("https://github.com/org/test1")
+	assert.NilError(t, err)
+	tt := map[string]struct {
+		version APIVersion
+		get     func(c *Client) error
+	}{
+		"BuildSummary": {get: func(c *Client) error {
+			_, err := c.BuildSummary(project, os.Stdout, nil)
+			return err
+		}},
+		"BuildSummary v2": {version: APIv2, get: func(c *Client) error {
+			_, err := c.BuildSummary(project, os.Stdout, nil)
+			return err
+		}},
+		"GetBuild": {get: func(c *Client) error {
+			_, err := c.GetBuild(project, os.Stdout, 42)
+			return err
+		}},
+		"GetBuild v2": {version: APIv2, get: func(c *Client) error {
+			_, err := c.GetBuild(project, os.Stdout, 42)
+			return err
+		}},
+		"GetBuildSummary": {get: func(c *Client) error {
+			_, err := c.GetBuildSummary(project, os.Stdout, 42)
+			return err
+		}},
+		"GetBuildSummary v2": {version: APIv2, get: func(c *Client) error {
+			_, err := c.GetBuildSummary(project, os.Stdout, 42)
+			return err
+		}},
+		"GetProject": {get: func(c *Client) error {
+			_, err := c.GetProject(project, os.Stdout)
+			return err
+		}},
+		"GetJob": {get: func(c *Client) error {
+			_, err := c.GetJob(project, os.Stdout, 42)
+			return err
+		}},
+		"BuildTests": {get: func(c *Client) error {
+			_, err := c.BuildTests(project, os.Stdout, 42)
+			return err
+		}},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var count int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				count++
+				return RequestError{Code: http.StatusNotFound, Message: "non-success status code returned 404 Not Found: Project not found"}
+			})
+			client.APIVersion = tc.version
+			// a not found response is not retried
+			client.retryAttempts = 3
+			err := tc.get(client)
+			_, ok := err.(*ProjectNotFollowedError)
+			assert.Assert(t, ok, "expected *ProjectNotFollowedError, got %T", err)
+			assert.Error(t, err, "project https://github.com/org/test1 was not found, it may not be followed by the current user -> non-success status code returned 404 Not Found: Project not found")
+			assert.Equal(t, 1, count)
+		})
+	}
+}
+
+func TestCancelBuild(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "POST", method)
+		assert.Equal(t, "project/github/org/test1/42/cancel", path)
+		return json.Unmarshal([]byte(`{"build_num": 42, "lifecycle": "finished", "outcome": "canceled"}`), output)
+	})
+	actual, err := client.CancelBuild(&project, os.Stdout, 42)
+	assert.NilError(t, err)
+	assert.Equal(t, 42, actual.BuildNum)
+}
+
+func TestRetryBuildWithSSH(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "POST", method)
+		assert.Equal(t, "project/github/org/test1/42/ssh", path)
+		return json.Unmarshal([]byte(`{"build_num": 43, "lifecycle": "queued", "why": "retry"}`), output)
+	})
+	actual, err := client.RetryBuildWithSSH(&project, os.Stdout, 42)
+	assert.NilError(t, err)
+	assert.Equal(t, 43, actual.BuildNum)
+	assert.Equal(t, "retry", actual.Why)
+}
+
+func TestGetProject(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "/api/v2/project/gh/org/test1", path)
+		return json.Unmarshal([]byte(`{
+				"slug": "gh/org/test1",
+				"name": "test1",
+				"organization_name": "org",
+				"vcs_info": {"vcs_url": "https://github.com/org/test1", "provider": "GitHub", "default_branch": "main"}
+			}`), output)
+	})
+	actual, err := client.GetProject(&project, os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, "main", actual.VcsInfo.DefaultBranch)
+	assert.Equal(t, "gh/org/test1", actual.Slug)
+}
+
+func TestGetUsage(t *testing.T) {
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "me", path)
+		return json.Unmarshal([]byte(`{
+				"login": "org",
+				"plan": "performance",
+				"containers": 4,
+				"parallelism": 16,
+				"days_left_in_trial": -150,
+				"trial_end": "2019-12-28T22:02:15Z"
+			}`), output)
+	})
+	trialEnd := time.Date(2019, 12, 28, 22, 2, 15, 0, time.UTC)
+	actual, err := client.GetUsage(os.Stdout)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Usage{Plan: "performance", Containers: 4, Parallelism: 16, DaysLeftInTrial: -150, TrialEnd: &trialEnd}, actual)
+}
+
 type finalWorkflowStatusTestCase struct {
 	API
 
@@ -877,6 +1708,8 @@ type finalWorkflowStatusTestCase struct {
 	workflowCount int
 	failureIndex  int
 	workflowIndex int
+	//failures are expected to be ignored
+	continueOnFail bool
 }
 
 func (c *finalWorkflowStatusTestCase) BuildSummary(_ *Project, _ io.Writer, input *BuildSummaryInput) ([]*BuildSummaryOutput, error) {
@@ -918,6 +1751,14 @@ func TestFinalWorkflowStatus(t *testing.T) {
 			failureIndex:  6,
 			workflowIndex: 2,
 		},
+		"failed_workflow_continue_on_fail": {
+			input:          input,
+			workflowCount:  5,
+			jobCount:       9,
+			failureIndex:   6,
+			workflowIndex:  2,
+			continueOnFail: true,
+		},
 		"success_workflow": {
 			input:         input,
 			workflowCount: 5,
@@ -933,10 +1774,463 @@ func TestFinalWorkflowStatus(t *testing.T) {
 		)
 		t.Run(name, func(t *testing.T) {
 			workflowName := fmt.Sprintf("wf_id-%d", tc.workflowIndex)
-			err := finalWorkflowStatus(tc, nil, nil, input, workflowName)
-			if tc.failureIndex >= 0 && err == nil {
+			err := finalWorkflowStatus(context.Background(), tc, nil, os.Stdout, input, workflowName, tc.continueOnFail, nil)
+			if tc.failureIndex >= 0 && !tc.continueOnFail && err == nil {
 				t.Errorf("%s should have failed at job index: %d for workflow name: %s", name, tc.failureIndex, workflowName)
 			}
+			if tc.continueOnFail && err != nil {
+				t.Errorf("%s should have continued on failure, got: %v", name, err)
+			}
+		})
+	}
+}
+
+func TestProjectFromURL(t *testing.T) {
+	expected := &Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		rawurl      string
+		expectedErr string
+		expected    *Project
+	}{
+		"https":                     {rawurl: "https://github.com/org/test1", expected: expected},
+		"https with .git":           {rawurl: "https://github.com/org/test1.git", expected: expected},
+		"https with trailing slash": {rawurl: "https://github.com/org/test1/", expected: expected},
+		"ssh":                       {rawurl: "git@github.com:org/test1.git", expected: expected},
+		"ssh without .git":          {rawurl: "git@github.com:org/test1", expected: expected},
+		"ssh with scheme":           {rawurl: "ssh://git@github.com/org/test1.git", expected: expected},
+		"missing repository": {
+			rawurl:      "git@github.com:org",
+			expectedErr: "path not properly formatted: https://github.com/org",
+		},
+		"missing host": {
+			rawurl:      "org/test1",
+			expectedErr: "host not properly formatted: org/test1",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := ProjectFromURL(tc.rawurl)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestProjectFromSlug(t *testing.T) {
+	tt := map[string]struct {
+		slug        string
+		expectedErr string
+		expected    *Project
+	}{
+		"github": {
+			slug:     "gh/org/test1",
+			expected: &Project{Username: "org", Reponame: "test1", Vcs: "github", VcsURL: "https://github.com/org/test1"},
+		},
+		"github long form": {
+			slug:     "github/org/test1",
+			expected: &Project{Username: "org", Reponame: "test1", Vcs: "github", VcsURL: "https://github.com/org/test1"},
+		},
+		"bitbucket": {
+			slug:     "bb/org/test1",
+			expected: &Project{Username: "org", Reponame: "test1", Vcs: "bitbucket", VcsURL: "https://bitbucket.org/org/test1"},
+		},
+		"missing repository": {
+			slug:        "gh/org",
+			expectedErr: `slug not properly formatted: "gh/org", must be vcs/org/repo`,
+		},
+		"too many parts": {
+			slug:        "gh/org/test1/extra",
+			expectedErr: `slug not properly formatted: "gh/org/test1/extra", must be vcs/org/repo`,
+		},
+		"unknown vcs": {
+			slug:        "gl/org/test1",
+			expectedErr: `invalid vcs for slug: gl/org/test1 -> unsupported version control system: "gl", must be one of github (gh) or bitbucket (bb)`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := ProjectFromSlug(tc.slug)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	tt := map[string]struct {
+		contentType string
+		body        string
+		expected    string
+	}{
+		"json message": {
+			contentType: "application/json",
+			body:        `{"message": "Project not found"}`,
+			expected:    "non-success status code returned 404 Not Found: Project not found",
+		},
+		"text body": {
+			contentType: "text/plain",
+			body:        "not found",
+			expected:    "non-success status code returned 404 Not Found: not found",
+		},
+		"truncated text body": {
+			contentType: "text/plain",
+			body:        strings.Repeat("a", maxErrorBodyLength+10),
+			expected:    "non-success status code returned 404 Not Found: " + strings.Repeat("a", maxErrorBodyLength) + "...",
+		},
+		"empty body": {
+			expected: "non-success status code returned 404 Not Found",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			assert.NilError(t, err)
+			c := newTestClient(t, request)
+			c.baseURL = u
+			var output messageResponse
+			err = request(c, "GET", "me", nil, nil, &output)
+			reqErr, ok := err.(RequestError)
+			assert.Assert(t, ok, "expected RequestError, got %T", err)
+			assert.Equal(t, http.StatusNotFound, reqErr.Code)
+			assert.Error(t, err, tc.expected)
+		})
+	}
+}
+
+func TestRequestDebugLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"login": "org"}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	var buf strings.Builder
+	c := newTestClient(t, request)
+	c.Token = "secret"
+	c.DebugLogger = &buf
+	c.baseURL = u
+	var output User
+	err = request(c, "GET", "me", nil, nil, &output)
+	assert.NilError(t, err)
+	assert.Equal(t, "org", output.Username)
+	assert.Assert(t, !strings.Contains(buf.String(), "secret"), "token was not redacted: %s", buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), "DEBUG: GET "+srv.URL+"/me?circle-token=REDACTED\n"), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), `-> 200 OK {"login": "org"}`), buf.String())
+}
+
+func TestRequestTransportErrorRedacted(t *testing.T) {
+	var buf strings.Builder
+	c := newTestClient(t, request)
+	c.Token = "secret"
+	c.client = &http.Client{Transport: testRoundTripper{}}
+	_, err := c.Me(&buf)
+	assert.ErrorContains(t, err, "circle-token=REDACTED")
+	assert.Assert(t, !strings.Contains(err.Error(), "secret"), "token was not redacted: %v", err)
+	assert.Assert(t, strings.Contains(buf.String(), "Me failed"), buf.String())
+	assert.Assert(t, !strings.Contains(buf.String(), "secret"), "token was not redacted: %s", buf.String())
+}
+
+func TestQuietWriter(t *testing.T) {
+	var buf strings.Builder
+	infof(&buf, "waiting for build %s [%d] to finish\n", "test1", 1)
+	assert.Equal(t, "waiting for build test1 [1] to finish\n", buf.String())
+
+	buf.Reset()
+	quiet := &QuietWriter{Writer: &buf}
+	infof(quiet, "waiting for build %s [%d] to finish\n", "test1", 1)
+	logf(quiet, "failed to get build %s [%d] -> %v\n", "test1", 1, "timeout")
+	assert.Equal(t, "failed to get build test1 [1] -> timeout\n", buf.String())
+}
+
+func TestRequestDecodeError(t *testing.T) {
+	tt := map[string]struct {
+		body      string
+		transient bool
+		expected  string
+	}{
+		"empty body": {
+			body:      "",
+			transient: true,
+			expected:  "failed to decode response: EOF",
+		},
+		"truncated body": {
+			body:      `{"login": "or`,
+			transient: true,
+			expected:  "failed to decode response: unexpected EOF",
+		},
+		"schema mismatch": {
+			body:     `{"login": 42}`,
+			expected: "json: cannot unmarshal number into Go struct field User.login of type string",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			assert.NilError(t, err)
+			c := newTestClient(t, request)
+			c.baseURL = u
+			var output User
+			err = request(c, "GET", "me", nil, nil, &output)
+			assert.Error(t, err, tc.expected)
+			assert.Equal(t, tc.transient, retryable(err))
+		})
+	}
+}
+
+func TestRetrier(t *testing.T) {
+	tt := map[string]struct {
+		err      error
+		expected int
+	}{
+		"success":         {expected: 1},
+		"transient error": {err: &decodeError{err: io.ErrUnexpectedEOF}, expected: 3},
+		"malformed body":  {err: &decodeError{err: &json.SyntaxError{}}, expected: 1},
+		"schema mismatch": {err: &json.UnmarshalTypeError{Value: "number"}, expected: 1},
+		"requester error": {err: fmt.Errorf("test error"), expected: 3},
+		"not found":       {err: RequestError{Code: http.StatusNotFound, Message: "not found"}, expected: 1},
+		"server error":    {err: RequestError{Code: http.StatusBadGateway, Message: "bad gateway"}, expected: 3},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var count int
+			err := retrier(context.Background(), 0, 3, func() error {
+				count++
+				return tc.err
+			})
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.expected, count)
+		})
+	}
+}
+
+func TestBackoffWaiter(t *testing.T) {
+	t.Run("interval doubles up to max", func(t *testing.T) {
+		var calls []time.Time
+		start := time.Now()
+		err := backoffWaiter(context.Background(), 10*time.Millisecond, 40*time.Millisecond, time.Now().Add(time.Second), func(count int) (bool, error) {
+			calls = append(calls, time.Now())
+			return count == 4, nil
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, 5, len(calls))
+		// 10ms + 20ms + 40ms + 40ms + 40ms
+		assert.Assert(t, calls[4].Sub(start) >= 150*time.Millisecond, "expected at least 150ms, got %s", calls[4].Sub(start))
+	})
+	t.Run("timeout exceeded", func(t *testing.T) {
+		var count int
+		err := backoffWaiter(context.Background(), 50*time.Millisecond, time.Second, time.Now().Add(500*time.Millisecond), func(int) (bool, error) {
+			count++
+			return false, nil
+		})
+		assert.Error(t, err, "time expired while running the checker")
+		// 50ms + 100ms + 200ms, then the last interval is shortened to the remaining 150ms
+		assert.Equal(t, 4, count)
+	})
+	t.Run("context done", func(t *testing.T) {
+		var count int
+		ctx, cancel := context.WithTimeout(context.Background(), 70*time.Millisecond)
+		defer cancel()
+		err := backoffWaiter(ctx, 50*time.Millisecond, time.Second, time.Now().Add(time.Minute), func(int) (bool, error) {
+			count++
+			return false, nil
+		})
+		assert.Equal(t, context.DeadlineExceeded, err)
+		// the checker is not called again once the context is done
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestCurrentUserCached(t *testing.T) {
+	var count int
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		count++
+		output.(*User).Username = "org"
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		me, err := client.currentUser(os.Stdout)
+		assert.NilError(t, err)
+		assert.Equal(t, "org", me.Username)
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestCurrentUserBuildActor(t *testing.T) {
+	var count int
+	client := NewClient(nil, "", WithBuildActor("machine-user"))
+	client.requester = func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		count++
+		return nil
+	}
+	me, err := client.currentUser(os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, "machine-user", me.Username)
+	assert.Equal(t, 0, count)
+}
+
+func TestMatchUser(t *testing.T) {
+	me := &User{Username: "self"}
+	tt := map[string]struct {
+		fork     bool
+		summary  *BuildSummaryOutput
+		expected bool
+	}{
+		"current user":             {summary: &BuildSummaryOutput{User: &User{Username: "self"}, Why: "api"}, expected: true},
+		"other user":               {summary: &BuildSummaryOutput{User: &User{Username: "author"}, Why: "api"}},
+		"fork triggered by api":    {fork: true, summary: &BuildSummaryOutput{User: &User{Username: "author"}, Why: "api"}, expected: true},
+		"fork triggered by github": {fork: true, summary: &BuildSummaryOutput{User: &User{Username: "author"}, Why: "github"}},
+		"fork without user":        {fork: true, summary: &BuildSummaryOutput{Why: "api"}, expected: true},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			input := &BuildProjectInput{Fork: tc.fork}
+			assert.Equal(t, tc.expected, input.matchUser(tc.summary, me))
+		})
+	}
+}
+
+func TestWaitForNextBuild(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		fork     bool
+		expected int
+	}{
+		"current user": {expected: 13},
+		"fork":         {fork: true, expected: 11},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch path {
+				case "me":
+					return json.Unmarshal([]byte(`{"login": "self"}`), output)
+				case "project/github/org/test1":
+					return json.Unmarshal([]byte(`[
+							{"build_num": 14, "lifecycle": "queued", "why": "api", "workflows": {"workflow_id": "other"}, "user": {"login": "self"}},
+							{"build_num": 12, "lifecycle": "queued", "why": "github", "workflows": {"workflow_id": "wf1"}},
+							{"build_num": 11, "lifecycle": "queued", "why": "api", "workflows": {"workflow_id": "wf1"}, "user": {"login": "author"}},
+							{"build_num": 13, "lifecycle": "queued", "why": "api", "workflows": {"workflow_id": "wf1"}, "user": {"login": "self"}},
+							{"build_num": 10, "lifecycle": "finished", "why": "api", "workflows": {"workflow_id": "wf1"}, "user": {"login": "self"}}
+						]`), output)
+				}
+				t.Fatalf("unexpected request: %s %s", method, path)
+				return nil
+			})
+			input := &BuildProjectInput{Fork: tc.fork}
+			summary, err := client.waitForNextBuild(project, os.Stdout, input, "wf1", 5*time.Second)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.expected, summary.BuildNum)
+		})
+	}
+}
+
+func TestMatchRevision(t *testing.T) {
+	full := "d8cbe5e2df067ba5a7eba66376911b064b48a4bf"
+	tt := map[string]struct {
+		revision string
+		expected bool
+	}{
+		"full":        {revision: full, expected: true},
+		"short":       {revision: "d8cbe5e", expected: true},
+		"upper case":  {revision: "D8CBE5E", expected: true},
+		"mismatch":    {revision: "d8cbe5f", expected: false},
+		"no revision": {revision: "", expected: false},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			input := &BuildProjectInput{Revision: "d8cbe5e"}
+			assert.Equal(t, tc.expected, input.matchSummary(&BuildSummaryOutput{Revision: tc.revision}))
+			input.Revision = full
+			assert.Equal(t, tc.expected, input.matchSummary(&BuildSummaryOutput{Revision: tc.revision}))
+		})
+	}
+}
+
+func TestPullRequestMerge(t *testing.T) {
+	input := &BuildProjectInput{PullRequestMerge: 7}
+	req, err := input.request()
+	assert.NilError(t, err)
+	assert.Equal(t, "pull/7/merge", req.Branch)
+	assert.Equal(t, "", input.Branch)
+
+	_, err = (&BuildProjectInput{Branch: "master", PullRequestMerge: 7}).request()
+	assert.Error(t, err, `pull request merge cannot be used with branch, revision or tag: [Branch: "master", Revision: "", Tag: "", PullRequestMerge: 7]`)
+
+	tt := map[string]struct {
+		branch   string
+		expected bool
+	}{
+		"merge ref":    {branch: "pull/7/merge", expected: true},
+		"pull request": {branch: "pull/7", expected: true},
+		"head ref":     {branch: "pull/7/head"},
+		"other":        {branch: "pull/8"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, input.matchSummary(&BuildSummaryOutput{Branch: tc.branch}))
+		})
+	}
+}
+
+func TestValidateVcs(t *testing.T) {
+	assert.NilError(t, ValidateVcs("github"))
+	assert.NilError(t, ValidateVcs("bitbucket"))
+	assert.NilError(t, ValidateVcs("gh"))
+	assert.Error(t, ValidateVcs("gitlab"), `unsupported version control system: "gitlab", must be one of github (gh) or bitbucket (bb)`)
+}
+
+func TestBuildSummaryDurations(t *testing.T) {
+	queued := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	started := queued.Add(time.Minute)
+	stopped := started.Add(5 * time.Minute)
+	tt := map[string]struct {
+		summary       BuildSummaryOutput
+		expectedQueue time.Duration
+		expectedRun   time.Duration
+	}{
+		"finished":      {summary: BuildSummaryOutput{QueuedAt: &queued, StartTime: &started, StoppedAt: &stopped}, expectedQueue: time.Minute, expectedRun: 5 * time.Minute},
+		"never started": {summary: BuildSummaryOutput{QueuedAt: &queued}},
+		"never stopped": {summary: BuildSummaryOutput{QueuedAt: &queued, StartTime: &started}, expectedQueue: time.Minute},
+		"never queued":  {summary: BuildSummaryOutput{StartTime: &started, StoppedAt: &stopped}, expectedRun: 5 * time.Minute},
+		"no times":      {},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedQueue, tc.summary.QueueDuration())
+			assert.Equal(t, tc.expectedRun, tc.summary.RunDuration())
 		})
 	}
 }
@@ -0,0 +1,153 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// Context ... represents a context object returned by calling
+// /context on the CircleCI API v2, contexts share environment
+// variables between the projects of an organization
+// https://circleci.com/docs/api/v2/#tag/Context
+type Context struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// ContextEnvVar ... represents an environment variable object returned by
+// calling /context/:context-id/environment-variable on the CircleCI API v2,
+// values are never returned by CircleCI
+// https://circleci.com/docs/api/v2/#operation/listEnvironmentVariablesFromContext
+type ContextEnvVar struct {
+	Variable  string     `json:"variable"`
+	ContextID string     `json:"context_id"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// contextOwner ... used internally to represent the owner of a context
+// when creating a context using the CircleCI API v2
+type contextOwner struct {
+	Slug string `json:"slug"`
+	Type string `json:"type"`
+}
+
+// createContextInput ... used internally to represent the request body
+// when creating a context using the CircleCI API v2
+type createContextInput struct {
+	Name  string       `json:"name"`
+	Owner contextOwner `json:"owner"`
+}
+
+// ListContexts ... returns the contexts of the organization matching ownerSlug,
+// in the format vcs-slug/org-name, e.g. gh/GSA
+// https://circleci.com/docs/api/v2/#operation/listContexts
+func (c *Client) ListContexts(ownerSlug string, logger io.Writer) ([]*Context, error) {
+	var contexts []*Context
+	path := apiV2Path + "context"
+	err := c.getAllPagesV2(path, logger, url.Values{"owner-slug": {ownerSlug}}, func(items json.RawMessage) error {
+		var page []*Context
+		err := json.Unmarshal(items, &page)
+		contexts = append(contexts, page...)
+		return err
+	})
+	if err != nil {
+		logf(logger, "ListContexts failed, GET %s -> %v", path, err)
+		return nil, err
+	}
+	return contexts, nil
+}
+
+// CreateContext ... creates a context with the given name in the organization
+// matching ownerSlug, in the format vcs-slug/org-name, e.g. gh/GSA
+// https://circleci.com/docs/api/v2/#operation/createContext
+func (c *Client) CreateContext(ownerSlug string, logger io.Writer, name string) (*Context, error) {
+	var context Context
+	input := &createContextInput{
+		Name:  name,
+		Owner: contextOwner{Slug: ownerSlug, Type: "organization"},
+	}
+	err := c.retry(logger, func() error {
+		url := apiV2Path + "context"
+		err := c.requester(c, "POST", url, nil, input, &context)
+		if err != nil {
+			logf(logger, "CreateContext failed, POST %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &context, nil
+}
+
+// DeleteContext ... deletes the context matching contextID
+// and all of its environment variables
+// https://circleci.com/docs/api/v2/#operation/deleteContext
+func (c *Client) DeleteContext(contextID string, logger io.Writer) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%scontext/%s", apiV2Path, contextID)
+		err := c.requester(c, "DELETE", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "DeleteContext failed, DELETE %s -> %v", url, err)
+		}
+		return err
+	})
+}
+
+// ListContextEnvVars ... returns the environment variables of the context matching contextID
+// https://circleci.com/docs/api/v2/#operation/listEnvironmentVariablesFromContext
+func (c *Client) ListContextEnvVars(contextID string, logger io.Writer) ([]*ContextEnvVar, error) {
+	var envVars []*ContextEnvVar
+	path := fmt.Sprintf("%scontext/%s/environment-variable", apiV2Path, contextID)
+	err := c.getAllPagesV2(path, logger, nil, func(items json.RawMessage) error {
+		var page []*ContextEnvVar
+		err := json.Unmarshal(items, &page)
+		envVars = append(envVars, page...)
+		return err
+	})
+	if err != nil {
+		logf(logger, "ListContextEnvVars failed, GET %s -> %v", path, err)
+		return nil, err
+	}
+	return envVars, nil
+}
+
+// AddContextEnvVar ... adds the environment variable to the context matching
+// contextID, replacing the value if the variable already exists
+// https://circleci.com/docs/api/v2/#operation/addEnvironmentVariableToContext
+func (c *Client) AddContextEnvVar(contextID string, logger io.Writer, name string, value string) (*ContextEnvVar, error) {
+	var envVar ContextEnvVar
+	input := map[string]string{"value": value}
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%scontext/%s/environment-variable/%s", apiV2Path, contextID, name)
+		err := c.requester(c, "PUT", url, nil, input, &envVar)
+		if err != nil {
+			logf(logger, "AddContextEnvVar failed, PUT %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &envVar, nil
+}
+
+// RemoveContextEnvVar ... removes the environment variable matching name
+// from the context matching contextID
+// https://circleci.com/docs/api/v2/#operation/deleteEnvironmentVariableFromContext
+func (c *Client) RemoveContextEnvVar(contextID string, logger io.Writer, name string) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%scontext/%s/environment-variable/%s", apiV2Path, contextID, name)
+		err := c.requester(c, "DELETE", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "RemoveContextEnvVar failed, DELETE %s -> %v", url, err)
+		}
+		return err
+	})
+}
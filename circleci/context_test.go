@@ -0,0 +1,58 @@
+package circleci
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestListContexts(t *testing.T) {
+	pages := map[string]string{
+		"":      `{"items": [{"id": "c1", "name": "deploy", "created_at": "2020-01-01T00:00:00Z"}], "next_page_token": "page2"}`,
+		"page2": `{"items": [{"id": "c2", "name": "test", "created_at": "2020-01-02T00:00:00Z"}], "next_page_token": null}`,
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "GET /api/v2/context", method+" "+path)
+		assert.Equal(t, "gh/org", params.Get("owner-slug"))
+		return json.Unmarshal([]byte(pages[params.Get("page-token")]), output)
+	})
+	contexts, err := client.ListContexts("gh/org", os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(contexts))
+	assert.Equal(t, "deploy", contexts[0].Name)
+	assert.Equal(t, "c2", contexts[1].ID)
+}
+
+func TestContextEnvVars(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		requests = append(requests, method+" "+path)
+		switch method {
+		case "GET":
+			return json.Unmarshal([]byte(`{"items": [{"variable": "FOO", "context_id": "c1"}]}`), output)
+		case "PUT":
+			assert.DeepEqual(t, map[string]string{"value": "bar"}, input)
+			return json.Unmarshal([]byte(`{"variable": "BAR", "context_id": "c1"}`), output)
+		}
+		return json.Unmarshal([]byte(`{"message": "ok"}`), output)
+	})
+
+	envVars, err := client.ListContextEnvVars("c1", os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(envVars))
+	assert.Equal(t, "FOO", envVars[0].Variable)
+
+	envVar, err := client.AddContextEnvVar("c1", os.Stdout, "BAR", "bar")
+	assert.NilError(t, err)
+	assert.Equal(t, "BAR", envVar.Variable)
+
+	assert.NilError(t, client.RemoveContextEnvVar("c1", os.Stdout, "FOO"))
+	assert.DeepEqual(t, []string{
+		"GET /api/v2/context/c1/environment-variable",
+		"PUT /api/v2/context/c1/environment-variable/BAR",
+		"DELETE /api/v2/context/c1/environment-variable/FOO",
+	}, requests)
+}
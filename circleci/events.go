@@ -0,0 +1,139 @@
+package circleci
+
+import (
+	"io"
+	"time"
+)
+
+// BuildEventType ... the type of a BuildEvent sent by WaitForProjectBuildEvents
+type BuildEventType string
+
+// Build event types sent by WaitForProjectBuildEvents
+const (
+	//a build job of the workflow was found
+	EventTriggered BuildEventType = "triggered"
+	//the build job is queued, scheduled or waiting to run
+	EventQueued BuildEventType = "queued"
+	//the build job started running
+	EventRunning BuildEventType = "running"
+	//a step of the build job completed, Step contains the name of the step
+	EventStepCompleted BuildEventType = "step_completed"
+	//the build job finished, Status contains the status of the build job
+	EventFinished BuildEventType = "finished"
+)
+
+// BuildEvent ... describes the progress of a build job waited on by
+// WaitForProjectBuildEvents
+type BuildEvent struct {
+	Type     BuildEventType
+	Project  *Project
+	BuildNum int
+	//lifecycle of the build job when the event was sent
+	Lifecycle string
+	//status of the build job, or of the step for EventStepCompleted
+	Status string
+	//name of the step, only set for EventStepCompleted
+	Step string
+	Time time.Time
+}
+
+// WaitForProjectBuildEvents ... behaves like WaitForProjectBuild, but also sends
+// a BuildEvent to events as each build job of the workflow progresses, events is
+// closed when WaitForProjectBuildEvents returns, sends block until the event is
+// received, when the WaitStrategy is WorkflowStatus only the EventTriggered and
+// EventFinished events of the first build job are sent, if events is nil no
+// events are sent and events is not closed
+func (c *Client) WaitForProjectBuildEvents(
+	project *Project,
+	logger io.Writer,
+	input *BuildProjectInput,
+	summary *BuildSummaryOutput,
+	jobTimeout time.Duration,
+	waitTimeout time.Duration,
+	continueOnFail bool,
+	events chan<- BuildEvent) error {
+	if events != nil {
+		defer close(events)
+	}
+	return c.waitForProjectBuild(project, logger, input, summary, jobTimeout, waitTimeout, continueOnFail, events)
+}
+
+// sendEvent ... used internally to send a BuildEvent to events,
+// nothing is sent if events is nil
+func sendEvent(events chan<- BuildEvent, event BuildEvent) {
+	if events == nil {
+		return
+	}
+	event.Time = time.Now()
+	events <- event
+}
+
+// stepCompleted ... used internally to return true if every action
+// of the step has finished running
+func stepCompleted(step *BuildStep) bool {
+	if len(step.Actions) == 0 {
+		return false
+	}
+	for _, a := range step.Actions {
+		if len(a.Status) == 0 || a.Status == lifecycleRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// stepStatus ... used internally to return failed if any action
+// of the step failed, otherwise the status of the first action
+func stepStatus(step *BuildStep) string {
+	for _, a := range step.Actions {
+		if a.failed() {
+			return "failed"
+		}
+	}
+	return step.Actions[0].Status
+}
+
+// buildProgress ... used internally to track the events already
+// sent for a build job, so that each event is only sent once
+type buildProgress struct {
+	lifecycle string
+	steps     map[int]bool
+}
+
+// update ... sends the events for the changes to build since the last update,
+// steps completed are sent after the build starts running and before it finishes
+func (p *buildProgress) update(events chan<- BuildEvent, project *Project, build *Build) {
+	if events == nil {
+		return
+	}
+	if p.steps == nil {
+		p.steps = make(map[int]bool)
+	}
+	event := BuildEvent{Project: project, BuildNum: build.BuildNum, Lifecycle: build.Lifecycle, Status: build.Status}
+	if build.Lifecycle != p.lifecycle {
+		p.lifecycle = build.Lifecycle
+		switch build.Lifecycle {
+		case lifecycleFinished:
+			event.Type = EventFinished
+		case lifecycleRunning:
+			event.Type = EventRunning
+		case "queued", "scheduled", "not_running":
+			event.Type = EventQueued
+		}
+	}
+	if len(event.Type) > 0 && event.Type != EventFinished {
+		sendEvent(events, event)
+	}
+	for i, s := range build.Steps {
+		if p.steps[i] || !stepCompleted(s) {
+			continue
+		}
+		p.steps[i] = true
+		stepEvent := event
+		stepEvent.Type, stepEvent.Step, stepEvent.Status = EventStepCompleted, s.Name, stepStatus(s)
+		sendEvent(events, stepEvent)
+	}
+	if event.Type == EventFinished {
+		sendEvent(events, event)
+	}
+}
@@ -0,0 +1,113 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestWaitForProjectBuildEvents(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	builds := []string{
+		`{"build_num": 42, "lifecycle": "queued"}`,
+		`{"build_num": 42, "lifecycle": "running", "status": "running", "steps": [
+			{"name": "checkout", "actions": [{"status": "success"}]},
+			{"name": "test", "actions": [{"status": "running"}]}]}`,
+		`{"build_num": 42, "lifecycle": "finished", "status": "failed", "failed": true, "steps": [
+			{"name": "checkout", "actions": [{"status": "success"}]},
+			{"name": "test", "actions": [{"status": "failed"}]}]}`,
+	}
+	var polls int
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		resp := builds[len(builds)-1]
+		if polls < len(builds) {
+			resp = builds[polls]
+		}
+		polls++
+		return json.Unmarshal([]byte(resp), output)
+	})
+	client.PollInterval = time.Millisecond
+	events := make(chan BuildEvent, 100)
+	err := client.WaitForProjectBuildEvents(&project, os.Stdout, &BuildProjectInput{}, &BuildSummaryOutput{BuildNum: 42}, time.Minute, time.Minute, false, events)
+	assert.Error(t, err, "build test1 [42] failed")
+
+	var actual []BuildEvent
+	for e := range events {
+		assert.Equal(t, 42, e.BuildNum)
+		assert.Assert(t, !e.Time.IsZero())
+		e.Project, e.Time = nil, time.Time{}
+		actual = append(actual, e)
+	}
+	expected := []BuildEvent{
+		{Type: EventTriggered, BuildNum: 42},
+		{Type: EventQueued, BuildNum: 42, Lifecycle: "queued"},
+		{Type: EventRunning, BuildNum: 42, Lifecycle: "running", Status: "running"},
+		{Type: EventStepCompleted, BuildNum: 42, Lifecycle: "running", Status: "success", Step: "checkout"},
+		{Type: EventStepCompleted, BuildNum: 42, Lifecycle: "finished", Status: "failed", Step: "test"},
+		{Type: EventFinished, BuildNum: 42, Lifecycle: "finished", Status: "failed"},
+	}
+	assert.DeepEqual(t, expected, actual)
+}
+
+func TestWaitForProjectBuildEventsNil(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		return json.Unmarshal([]byte(`{"build_num": 42, "lifecycle": "finished", "status": "failed", "failed": true}`), output)
+	})
+	client.PollInterval = time.Millisecond
+	// a nil events channel is neither sent to nor closed
+	err := client.WaitForProjectBuildEvents(&project, os.Stdout, &BuildProjectInput{}, &BuildSummaryOutput{BuildNum: 42}, time.Minute, time.Minute, false, nil)
+	assert.Error(t, err, "build test1 [42] failed")
+}
+
+func TestWaitForProjectBuildEventsWorkflowStatus(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		status         string
+		continueOnFail bool
+		expectedErr    string
+		expected       string
+	}{
+		"succeeded":         {status: "success", expected: "success"},
+		"failed":            {status: "failed", expectedErr: "workflow test1 [deploy] failed with status: failed", expected: "failed"},
+		"failed continue":   {status: "failed", continueOnFail: true, expected: "failed"},
+		"not run continue":  {status: "not_run", continueOnFail: true, expected: "not_run"},
+		"canceled continue": {status: "canceled", continueOnFail: true, expectedErr: "workflow test1 [deploy] was canceled", expected: "failed"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				if w, ok := output.(*Workflow); ok {
+					*w = Workflow{ID: "test", Name: "deploy", Status: tc.status}
+					return nil
+				}
+				return fmt.Errorf("unexpected request: %s", path)
+			})
+			client.PollInterval = time.Millisecond
+			client.WaitStrategy = WorkflowStatus
+			events := make(chan BuildEvent, 100)
+			summary := &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}}
+			err := client.WaitForProjectBuildEvents(&project, os.Stdout, &BuildProjectInput{}, summary, time.Minute, time.Minute, tc.continueOnFail, events)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+
+			var finished []BuildEvent
+			for e := range events {
+				if e.Type == EventFinished {
+					finished = append(finished, e)
+				}
+			}
+			assert.Equal(t, 1, len(finished))
+			assert.Equal(t, tc.expected, finished[0].Status)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package circleci
+
+import (
+	"io"
+	"time"
+)
+
+// BuildHandle ... tracks a build triggered by TriggerBuild, so that the build
+// can be polled later using PollHandle without waiting for it to complete
+type BuildHandle struct {
+	Project        *Project
+	PipelineID     string
+	PipelineNumber int
+	TriggeredAt    time.Time
+	//populated by PollHandle once the first workflow of the pipeline has started
+	WorkflowID string
+	//populated by PollHandle once the first job of the workflow has started
+	BuildNum int
+}
+
+// Build handle statuses returned by PollHandle
+const (
+	HandlePending  = "pending"
+	HandleRunning  = "running"
+	HandleOnHold   = "on_hold"
+	HandleSuccess  = "success"
+	HandleFailed   = "failed"
+	HandleCanceled = "canceled"
+)
+
+// BuildStatus ... contains the status of a build returned by PollHandle
+type BuildStatus struct {
+	//pending, running, on_hold, success, failed or canceled
+	Status string
+	//true if every workflow of the pipeline has reached a terminal status
+	Finished  bool
+	Workflows []*Workflow
+	//the errors of the pipeline, populated if the pipeline errored
+	Errors []*PipelineError
+}
+
+// TriggerBuild ... triggers a new pipeline for the project using the CircleCI
+// API v2 and returns a *BuildHandle immediately, without waiting for any builds
+// to start, the revision parameter is not supported by the CircleCI API v2
+func (c *Client) TriggerBuild(project *Project, logger io.Writer, input *BuildProjectInput) (*BuildHandle, error) {
+	pipeline, err := c.TriggerOnly(project, logger, input)
+	if err != nil {
+		return nil, err
+	}
+	h := &BuildHandle{
+		Project:        project,
+		PipelineID:     pipeline.ID,
+		PipelineNumber: pipeline.Number,
+		TriggeredAt:    time.Now(),
+	}
+	if pipeline.CreatedAt != nil {
+		h.TriggeredAt = *pipeline.CreatedAt
+	}
+	return h, nil
+}
+
+// PollHandle ... returns the current *BuildStatus of the build tracked by h,
+// the status is pending until the first workflow of the pipeline has started,
+// or failed if the pipeline errored before creating any workflows, the
+// workflow ID and build number of h are populated as they become available
+func (c *Client) PollHandle(h *BuildHandle, logger io.Writer) (*BuildStatus, error) {
+	workflows, err := c.PipelineWorkflows(h.PipelineID, logger)
+	if err != nil {
+		return nil, err
+	}
+	status := &BuildStatus{Status: HandlePending, Workflows: workflows}
+	if len(workflows) == 0 {
+		pipeline, err := c.GetPipelineByNumber(h.Project, logger, h.PipelineNumber)
+		if err != nil {
+			return nil, err
+		}
+		if pipeline.State == pipelineStateErrored {
+			status.Status, status.Finished, status.Errors = HandleFailed, true, pipeline.Errors
+		}
+		return status, nil
+	}
+	if len(h.WorkflowID) == 0 {
+		h.WorkflowID = workflows[0].ID
+	}
+	if h.BuildNum == 0 {
+		jobs, err := c.WorkflowJobs(h.WorkflowID, logger)
+		if err != nil {
+			return nil, err
+		}
+		for _, j := range jobs {
+			if j.JobNumber > 0 {
+				h.BuildNum = j.JobNumber
+				break
+			}
+		}
+	}
+	status.Status, status.Finished = workflowsStatus(workflows)
+	return status, nil
+}
+
+// workflowsStatus ... used internally to combine the statuses of the workflows of a
+// pipeline into a single status, returns true if every workflow has finished
+func workflowsStatus(workflows []*Workflow) (string, bool) {
+	var running, onHold, failed, canceled bool
+	for _, w := range workflows {
+		switch {
+		case w.Status == workflowStatusOnHold:
+			onHold = true
+		case !w.Finished():
+			running = true
+		case w.Status == statusCanceled:
+			canceled = true
+		case w.Status != "success":
+			failed = true
+		}
+	}
+	switch {
+	case running:
+		return HandleRunning, false
+	case onHold:
+		return HandleOnHold, false
+	case canceled:
+		return HandleCanceled, true
+	case failed:
+		return HandleFailed, true
+	}
+	return HandleSuccess, true
+}
@@ -0,0 +1,94 @@
+package circleci
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestPollHandle(t *testing.T) {
+	var workflows, state string
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		switch path {
+		case "/api/v2/pipeline/test/workflow":
+			return json.Unmarshal([]byte(workflows), output)
+		case "/api/v2/project/gh/test/test/pipeline/7":
+			return json.Unmarshal([]byte(state), output)
+		case "/api/v2/workflow/wf1/job":
+			return json.Unmarshal([]byte(`{"items": [{"type": "approval"}, {"job_number": 42}]}`), output)
+		}
+		t.Fatalf("unexpected request: %s %s", method, path)
+		return nil
+	})
+	h := &BuildHandle{Project: &Project{Vcs: "github", Username: "test", Reponame: "test"}, PipelineID: "test", PipelineNumber: 7}
+
+	workflows, state = `{"items": []}`, `{"state": "setup-pending"}`
+	status, err := client.PollHandle(h, os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, HandlePending, status.Status)
+	assert.Assert(t, !status.Finished)
+	assert.Equal(t, "", h.WorkflowID)
+
+	workflows = `{"items": [{"id": "wf1", "status": "running"}, {"id": "wf2", "status": "success"}]}`
+	status, err = client.PollHandle(h, os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, HandleRunning, status.Status)
+	assert.Assert(t, !status.Finished)
+	assert.Equal(t, "wf1", h.WorkflowID)
+	assert.Equal(t, 42, h.BuildNum)
+
+	workflows = `{"items": [{"id": "wf1", "status": "success"}, {"id": "wf2", "status": "success"}]}`
+	status, err = client.PollHandle(h, os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, HandleSuccess, status.Status)
+	assert.Assert(t, status.Finished)
+}
+
+func TestPollHandleErrored(t *testing.T) {
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		switch path {
+		case "/api/v2/pipeline/test/workflow":
+			return json.Unmarshal([]byte(`{"items": []}`), output)
+		case "/api/v2/project/gh/test/test/pipeline/7":
+			return json.Unmarshal([]byte(`{"state": "errored", "errors": [{"type": "config", "message": "invalid config"}]}`), output)
+		}
+		t.Fatalf("unexpected request: %s %s", method, path)
+		return nil
+	})
+	h := &BuildHandle{Project: &Project{Vcs: "github", Username: "test", Reponame: "test"}, PipelineID: "test", PipelineNumber: 7}
+	status, err := client.PollHandle(h, os.Stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, HandleFailed, status.Status)
+	assert.Assert(t, status.Finished)
+	assert.Equal(t, 1, len(status.Errors))
+	assert.Equal(t, "invalid config", status.Errors[0].Message)
+}
+
+func TestWorkflowsStatus(t *testing.T) {
+	tt := map[string]struct {
+		statuses []string
+		expected string
+		finished bool
+	}{
+		"success":  {statuses: []string{"success", "success"}, expected: HandleSuccess, finished: true},
+		"running":  {statuses: []string{"failed", "running"}, expected: HandleRunning},
+		"on hold":  {statuses: []string{"success", "on_hold"}, expected: HandleOnHold},
+		"failed":   {statuses: []string{"success", "failed"}, expected: HandleFailed, finished: true},
+		"canceled": {statuses: []string{"failed", "canceled"}, expected: HandleCanceled, finished: true},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var workflows []*Workflow
+			for _, s := range tc.statuses {
+				workflows = append(workflows, &Workflow{Status: s})
+			}
+			actual, finished := workflowsStatus(workflows)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.finished, finished)
+		})
+	}
+}
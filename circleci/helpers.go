@@ -2,6 +2,7 @@ package circleci
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,23 +16,105 @@ import (
 
 const (
 	lifecycleFinished = "finished"
+	lifecycleNotRun   = "not_run"
+	lifecycleRunning  = "running"
 )
 
-// nolint: gochecknoglobals
-var retrierIntervalSecs, retrierAttempts = 30, 3
+// queuedLifecycle ... returns true if the lifecycle of a build shows
+// it is waiting to be run, rather than running or finished
+func queuedLifecycle(lifecycle string) bool {
+	switch lifecycle {
+	case "queued", "scheduled", "not_running":
+		return true
+	}
+	return false
+}
+
+const (
+	// defaultRetryAttempts ... the default number of attempts made for each request
+	defaultRetryAttempts = 3
+	// defaultRetryIntervalSecs ... the default number of seconds between failed attempts
+	defaultRetryIntervalSecs = 30
+)
 
+// retrier ... calls fn up to attempts times, sleeping intervalSecs between
+// each failed attempt, errors that will never succeed are not retried, and
+// no further attempts are made once ctx is done
 //nolint:unparam
-func retrier(intervalSecs int, attempts int, fn func() error) (err error) {
+func retrier(ctx context.Context, intervalSecs int, attempts int, fn func() error) (err error) {
 	for attempt := 0; attempt < attempts; attempt++ {
 		err = fn()
-		if err == nil {
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return
+		}
+		if sleepErr := sleepContext(ctx, time.Duration(intervalSecs)*time.Second); sleepErr != nil {
 			return
 		}
-		time.Sleep(time.Duration(intervalSecs) * time.Second)
 	}
 	return
 }
 
+// sleepContext ... used internally to sleep for d, returning
+// the error of ctx if ctx is done before d has passed
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retry ... used internally to call retrier using the retry settings of the
+// client, if the client has no retry settings the defaults are used, the
+// cached responses are removed if CircleCI rejects the access key, and any
+// deprecation notices returned by CircleCI are written to logger
+func (c *Client) retry(logger io.Writer, fn func() error) error {
+	attempts, intervalSecs := c.retryAttempts, c.retryIntervalSecs
+	if attempts <= 0 {
+		attempts, intervalSecs = defaultRetryAttempts, defaultRetryIntervalSecs
+	}
+	err := retrier(c.context(), intervalSecs, attempts, fn)
+	c.logDeprecations(logger)
+	if c.cache != nil && authError(err) {
+		c.cache.clear(c)
+	}
+	return err
+}
+
+// retryable ... returns false if err is a schema mismatch or malformed
+// response that will fail the same way no matter how many times it is retried
+func retryable(err error) bool {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		return false
+	case *decodeError:
+		return e.Transient()
+	case RequestError:
+		// the resource does not exist, or the project is not followed
+		return e.Code != http.StatusNotFound
+	}
+	return true
+}
+
+// decodeError ... used internally to signify a response body could
+// not be decoded, empty or truncated bodies are transient and are
+// likely to succeed if retried
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return fmt.Sprintf("failed to decode response: %v", e.err)
+}
+
+// Transient ... returns true if the response body was empty or truncated
+func (e *decodeError) Transient() bool {
+	return e.err == io.EOF || e.err == io.ErrUnexpectedEOF
+}
+
 func logf(logger io.Writer, format string, args ...interface{}) {
 	_, err := fmt.Fprintf(logger, format, args...)
 	if err != nil {
@@ -39,6 +122,21 @@ func logf(logger io.Writer, format string, args ...interface{}) {
 	}
 }
 
+// QuietWriter ... wraps the io.Writer provided as the logger of the client
+// methods, progress messages are discarded so that only failures are written
+type QuietWriter struct {
+	io.Writer
+}
+
+// infof ... used internally to log progress messages, which are
+// discarded if the logger is a *QuietWriter
+func infof(logger io.Writer, format string, args ...interface{}) {
+	if _, ok := logger.(*QuietWriter); ok {
+		return
+	}
+	logf(logger, format, args...)
+}
+
 // isolates the request func for hooking up tests
 type requestFunc func(*Client, string, string, url.Values, interface{}, interface{}) error
 
@@ -52,6 +150,124 @@ func (r RequestError) Error() string {
 	return r.Message
 }
 
+const (
+	// maxErrorBodyRead ... the maximum number of bytes read from an error response body
+	maxErrorBodyRead = 4096
+	// maxErrorBodyLength ... the maximum number of bytes of a non-JSON
+	// error response body to include in a RequestError
+	maxErrorBodyLength = 256
+)
+
+// newRequestError ... used internally to create a RequestError from a
+// non-success response, including the message returned by CircleCI
+func newRequestError(resp *http.Response) RequestError {
+	reqErr := RequestError{
+		Code:    resp.StatusCode,
+		Message: fmt.Sprintf("non-success status code returned %s", resp.Status),
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodyRead))
+	if err != nil || len(bytes.TrimSpace(body)) == 0 {
+		return reqErr
+	}
+	var msg messageResponse
+	if err := json.Unmarshal(body, &msg); err == nil && len(msg.Message) > 0 {
+		reqErr.Message = fmt.Sprintf("%s: %s", reqErr.Message, msg.Message)
+		return reqErr
+	}
+	text := strings.TrimSpace(string(body))
+	if len(body) > maxErrorBodyLength {
+		text = strings.TrimSpace(string(body[:maxErrorBodyLength])) + "..."
+	}
+	reqErr.Message = fmt.Sprintf("%s: %s", reqErr.Message, text)
+	return reqErr
+}
+
+// redactedValue ... replaces secrets when logging
+const redactedValue = "REDACTED"
+
+// redactURL ... used internally to return the string form of u
+// with the circle-token query parameter redacted
+func redactURL(u *url.URL) string {
+	params := u.Query()
+	if _, ok := params["circle-token"]; ok {
+		params.Set("circle-token", redactedValue)
+	}
+	r := *u
+	r.RawQuery = params.Encode()
+	return r.String()
+}
+
+// redactError ... used internally to redact the access key from the URL of
+// a *url.Error returned while requesting u, as the error is logged
+func redactError(err error, u *url.URL) error {
+	if uerr, ok := err.(*url.Error); ok {
+		uerr.URL = redactURL(u)
+	}
+	return err
+}
+
+// debugBody ... used internally to replace a response body that
+// has already been read, while still closing the original body
+type debugBody struct {
+	io.Reader
+	io.Closer
+}
+
+// debugResponse ... used internally to log the status and body of resp,
+// the body is replaced so that it can still be decoded by the caller
+func debugResponse(logger io.Writer, method string, u *url.URL, resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	resp.Body = debugBody{Reader: bytes.NewReader(body), Closer: resp.Body}
+	text := string(body)
+	if len(text) > maxErrorBodyRead {
+		text = text[:maxErrorBodyRead] + "..."
+	}
+	logf(logger, "DEBUG: %s %s -> %s %s\n", method, redactURL(u), resp.Status, text)
+	return nil
+}
+
+// deprecationHeaders ... response headers used by CircleCI
+// to give notice that an endpoint will be removed
+var deprecationHeaders = []string{"Deprecation", "Sunset", "Warning"}
+
+// warnDeprecation ... used internally to record the deprecation headers of a
+// response, each distinct header value is only recorded once by the client,
+// the notices are written to the logger of the request by logDeprecations
+func (c *Client) warnDeprecation(method string, path string, header http.Header) {
+	state := c.shared()
+	state.warnedMu.Lock()
+	defer state.warnedMu.Unlock()
+	for _, name := range deprecationHeaders {
+		for _, value := range header[name] {
+			key := name + ": " + value
+			if state.warned == nil {
+				state.warned = make(map[string]bool)
+			}
+			if state.warned[key] {
+				continue
+			}
+			state.warned[key] = true
+			state.deprecations = append(state.deprecations, fmt.Sprintf("WARNING: CircleCI returned a deprecation notice for %s %s, %s\n", method, path, key))
+		}
+	}
+}
+
+// logDeprecations ... used internally to write the deprecation
+// notices recorded by warnDeprecation to logger
+func (c *Client) logDeprecations(logger io.Writer) {
+	state := c.shared()
+	state.warnedMu.Lock()
+	deprecations := state.deprecations
+	state.deprecations = nil
+	state.warnedMu.Unlock()
+	for _, d := range deprecations {
+		logf(logger, "%s", d)
+	}
+}
+
 // request ... used internally to process requests to CircleCI
 // nolint: gocyclo
 func request(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
@@ -60,12 +276,26 @@ func request(c *Client, method string, path string, params url.Values, input int
 	}
 	params.Set("circle-token", c.Token)
 
-	u := c.baseURL.ResolveReference(&url.URL{Path: path, RawQuery: params.Encode()})
+	// parse the path, so that escaped characters such as the
+	// slashes in branch names are not escaped a second time
+	ref, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	ref.RawQuery = params.Encode()
+	u := c.baseURL.ResolveReference(ref)
 
 	req, err := http.NewRequest(method, u.String(), nil)
 	if err != nil {
-		return err
+		return redactError(err, u)
 	}
+	ctx := c.context()
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+	req = req.WithContext(ctx)
 
 	if input != nil {
 		var buf bytes.Buffer
@@ -76,22 +306,48 @@ func request(c *Client, method string, path string, params url.Values, input int
 		req.Body = ioutil.NopCloser(&buf)
 	}
 
-	req.Header.Add("Accept", "application/json")
+	// merge the default headers first, so they never replace our own
+	for k, v := range c.DefaultHeaders {
+		req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
+	userAgent := c.userAgent
+	if len(userAgent) == 0 {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
 
-	resp, err := c.client.Do(req)
+	if c.DebugLogger != nil {
+		logf(c.DebugLogger, "DEBUG: %s %s\n", method, redactURL(u))
+	}
+
+	limiter := &c.shared().limiter
+	err = limiter.wait(ctx)
 	if err != nil {
 		return err
 	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return redactError(err, u)
+	}
+	limiter.observe(time.Now(), resp.StatusCode, resp.Header)
 	defer func() {
 		err = resp.Body.Close()
 		if err != nil {
 			log.Printf("failed to close response body -> %v\n", err)
 		}
 	}()
+	if c.DebugLogger != nil {
+		err = debugResponse(c.DebugLogger, method, u, resp)
+		if err != nil {
+			return err
+		}
+	}
+	c.warnDeprecation(method, path, resp.Header)
 
 	if resp.StatusCode >= http.StatusMultipleChoices || resp.StatusCode < http.StatusOK {
-		return fmt.Errorf("non-success status code returned %s", resp.Status)
+		return newRequestError(resp)
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(output)
@@ -99,7 +355,7 @@ func request(c *Client, method string, path string, params url.Values, input int
 		if val, ok := err.(*json.UnmarshalTypeError); ok {
 			return val
 		}
-		return fmt.Errorf("failed to decode response: %v", err)
+		return &decodeError{err: err}
 	}
 
 	return nil
@@ -117,14 +373,29 @@ func (e *timeoutExceededError) Error() string {
 
 // waiter ... calls checker func every interval until checker returns bool, nil
 // or endTime is reached, if endTime is reached a timeoutExceededError will be
-// returned
-func waiter(interval time.Duration, endTime time.Time, checker func(int) (bool, error)) error {
+// returned, if ctx is done first the error of ctx is returned
+func waiter(ctx context.Context, interval time.Duration, endTime time.Time, checker func(int) (bool, error)) error {
+	return backoffWaiter(ctx, interval, interval, endTime, checker)
+}
+
+// backoffWaiter ... behaves like waiter, but doubles the interval after each call
+// to checker, up to maxInterval, the final interval is shortened so that checker
+// is not called long after endTime
+func backoffWaiter(ctx context.Context, interval time.Duration, maxInterval time.Duration, endTime time.Time, checker func(int) (bool, error)) error {
 	var count int
 	for {
-		if time.Now().After(endTime) {
+		remaining := time.Until(endTime)
+		if remaining < 0 {
 			return &timeoutExceededError{Message: "time expired while running the checker"}
 		}
-		time.Sleep(interval)
+		sleep := interval
+		if sleep > remaining {
+			sleep = remaining
+		}
+		err := sleepContext(ctx, sleep)
+		if err != nil {
+			return err
+		}
 		done, err := checker(count)
 		if err != nil {
 			return err
@@ -133,19 +404,26 @@ func waiter(interval time.Duration, endTime time.Time, checker func(int) (bool,
 			return nil
 		}
 		count++
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
 	}
 }
 
 // finalWorkflowStatus checks all build summaries related to the provided workflowID
-// if any build has a status not equal to success will return an error
-func finalWorkflowStatus(c API, project *Project, logger io.Writer, input *BuildProjectInput, workflowID string) error {
+// if any build has a status not equal to success will return an error, unless
+// continueOnFail is true, in which case the failure is only logged, if succeeded
+// is not nil it decides whether each build without a success status succeeded,
+// the build summaries are no longer retried once ctx is done
+func finalWorkflowStatus(ctx context.Context, c API, project *Project, logger io.Writer, input *BuildProjectInput, workflowID string, continueOnFail bool, succeeded func(*Build) bool) error {
 	var (
 		summaries []*BuildSummaryOutput
 		err       error
 	)
 	// retry up to 3 times, once every five seconds
 	// this should allow us to be resilient to intermittent webservice availability issues
-	err = retrier(5, 3, func() error {
+	err = retrier(ctx, 5, 3, func() error {
 		summaries, err = c.BuildSummary(project, logger, nil)
 		if err != nil {
 			return fmt.Errorf("failed to enumerate build summaries: %v", err)
@@ -161,6 +439,19 @@ func finalWorkflowStatus(c API, project *Project, logger io.Writer, input *Build
 			s.Workflow != nil &&
 			s.Workflow.WorkflowID == workflowID &&
 			s.Status != "success" {
+			if succeeded != nil {
+				build, err := c.GetBuild(project, logger, s.BuildNum)
+				if err != nil {
+					return err
+				}
+				if succeeded(build) {
+					continue
+				}
+			}
+			if continueOnFail {
+				infof(logger, "workflow %s [%s->%s] failed with status: %s, continue on failure is enabled for this project\n", s.Reponame, s.Workflow.WorkflowName, s.Workflow.JobName, s.Status)
+				return nil
+			}
 			return fmt.Errorf("workflow %s [%s->%s] failed with status: %s", s.Reponame, s.Workflow.WorkflowName, s.Workflow.JobName, s.Status)
 		}
 	}
@@ -212,15 +503,73 @@ func FilterBuildSummariesByWorkflowStatus(input []*BuildSummaryOutput, status st
 	return output
 }
 
+// FilterBuildSummariesByLatestRetry ... takes a slice of build summaries and returns
+// a new slice excluding the summaries that were retried by another summary in the
+// slice, so that each chain of retries is represented only by its latest attempt
+func FilterBuildSummariesByLatestRetry(input []*BuildSummaryOutput) (output []*BuildSummaryOutput) {
+	retried := make(map[int]bool)
+	for _, b := range input {
+		if b.RetryOf != nil {
+			retried[*b.RetryOf] = true
+		}
+	}
+	for _, b := range input {
+		if !retried[b.BuildNum] {
+			output = append(output, b)
+		}
+	}
+	return output
+}
+
+// FilterBuildSummariesByDateRange ... takes a slice of build summaries and returns
+// a new slice containing the summaries queued within the inclusive range of start
+// and end, summaries that were never queued are excluded
+func FilterBuildSummariesByDateRange(input []*BuildSummaryOutput, start, end time.Time) (output []*BuildSummaryOutput) {
+	for _, b := range input {
+		if b.QueuedAt == nil {
+			continue
+		}
+		if b.QueuedAt.Before(start) || b.QueuedAt.After(end) {
+			continue
+		}
+		output = append(output, b)
+	}
+	return output
+}
+
+// FilterBuildSummariesByAuthor ... takes a slice of build summaries and returns a
+// new slice containing the summaries whose commit author name or email matches
+// author, the comparison is case-insensitive
+func FilterBuildSummariesByAuthor(input []*BuildSummaryOutput, author string) (output []*BuildSummaryOutput) {
+	for _, b := range input {
+		if strings.EqualFold(b.AuthorName, author) || strings.EqualFold(b.AuthorEmail, author) {
+			output = append(output, b)
+		}
+	}
+	return output
+}
+
+// FilterBuildSummariesByCommitMessage ... takes a slice of build summaries and returns
+// a new slice containing the summaries whose commit subject or body contains message
+func FilterBuildSummariesByCommitMessage(input []*BuildSummaryOutput, message string) (output []*BuildSummaryOutput) {
+	for _, b := range input {
+		if strings.Contains(b.Subject, message) || strings.Contains(b.Body, message) {
+			output = append(output, b)
+		}
+	}
+	return output
+}
+
 // ProjectFromURL ... takes a code repository path and converts it
-// to a Project object - only tested on github paths
+// to a Project object - only tested on github paths, supports both
+// https URLs and SSH URLs in the SCP-style form git@host:org/repo.git
 func ProjectFromURL(rawurl string) (*Project, error) {
 	const minParts = 2
-	u, err := url.Parse(rawurl)
+	u, err := parseRepositoryURL(rawurl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %s -> %v", rawurl, err)
 	}
-	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"), "/")
 	if len(parts) < minParts {
 		return nil, fmt.Errorf("path not properly formatted: %s", u)
 	}
@@ -228,6 +577,7 @@ func ProjectFromURL(rawurl string) (*Project, error) {
 	if len(vcs) < minParts {
 		return nil, fmt.Errorf("host not properly formatted: %s", u)
 	}
+	u.Path, u.RawQuery, u.Fragment = fmt.Sprintf("/%s/%s", parts[0], parts[1]), "", ""
 	return &Project{
 		Username: parts[0],
 		Reponame: parts[1],
@@ -235,3 +585,56 @@ func ProjectFromURL(rawurl string) (*Project, error) {
 		VcsURL:   u.String(),
 	}, nil
 }
+
+// ProjectFromSlug ... takes a CircleCI project slug in the format vcs/org/repo,
+// such as gh/GSA/grace-circleci-builder, and converts it to a Project object,
+// the vcs may be in the short or long form accepted by ParseVCS
+func ProjectFromSlug(slug string) (*Project, error) {
+	const slugParts = 3
+	parts := strings.Split(slug, "/")
+	if len(parts) != slugParts || len(parts[1]) == 0 || len(parts[2]) == 0 {
+		return nil, fmt.Errorf("slug not properly formatted: %q, must be vcs/org/repo", slug)
+	}
+	vcs, err := ParseVCS(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid vcs for slug: %s -> %v", slug, err)
+	}
+	u := &url.URL{Scheme: "https", Host: vcs.host(), Path: fmt.Sprintf("/%s/%s", parts[1], parts[2])}
+	return &Project{
+		Username: parts[1],
+		Reponame: parts[2],
+		Vcs:      vcs.V1(),
+		VcsURL:   u.String(),
+	}, nil
+}
+
+// ValidateVcs ... returns an error if vcs is not a version control
+// system type supported by CircleCI, see ParseVCS
+func ValidateVcs(vcs string) error {
+	_, err := ParseVCS(vcs)
+	return err
+}
+
+// parseRepositoryURL ... used internally to parse a repository URL, SCP-style
+// SSH URLs (git@github.com:org/repo.git) are converted to their https form
+func parseRepositoryURL(rawurl string) (*url.URL, error) {
+	if !strings.Contains(rawurl, "://") {
+		if i := strings.Index(rawurl, ":"); i > 0 {
+			host := rawurl[:i]
+			if at := strings.LastIndex(host, "@"); at >= 0 {
+				host = host[at+1:]
+			}
+			return &url.URL{Scheme: "https", Host: host, Path: "/" + strings.TrimLeft(rawurl[i+1:], "/")}, nil
+		}
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "ssh" || u.Scheme == "git" {
+		u.Scheme = "https"
+		u.User = nil
+		u.Host = u.Hostname()
+	}
+	return u, nil
+}
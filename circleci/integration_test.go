@@ -0,0 +1,120 @@
+package circleci
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+const testToken = "test-token"
+
+// recordedRoutes ... maps CircleCI API routes to recorded responses in test_data
+//nolint:gochecknoglobals
+var recordedRoutes = map[string]string{
+	"POST /api/v1.1/project/github/org/test1/build": "build_project.json",
+	"GET /api/v1.1/project/github/org/test1":        "build_summaries.json",
+	"GET /api/v1.1/project/github/org/test1/42":     "build.json",
+	"GET /api/v1.1/me":                              "me.json",
+}
+
+// newRecordedAPI ... returns a server replaying the recorded responses, the
+// placeholder {{now}} is replaced with the current time, so that recorded builds
+// appear to have been queued by the request that triggered them
+func newRecordedAPI(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("circle-token") != testToken {
+			http.Error(w, `{"message": "You must log in first."}`, http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Accept") != "application/json" {
+			http.Error(w, `{"message": "unsupported Accept header"}`, http.StatusNotAcceptable)
+			return
+		}
+		fixture, ok := recordedRoutes[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, `{"message": "Not found"}`, http.StatusNotFound)
+			return
+		}
+		body, err := ioutil.ReadFile(filepath.Join("test_data", fixture))
+		if err != nil {
+			t.Errorf("failed to read fixture %s: %v", fixture, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		_, err = w.Write([]byte(strings.Replace(string(body), "{{now}}", now, -1)))
+		if err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+}
+
+// newRecordedClient ... returns a *Client using the real request func
+// against the recorded server
+func newRecordedClient(t *testing.T, srv *httptest.Server) *Client {
+	u, err := url.Parse(srv.URL + "/api/v1.1/")
+	assert.NilError(t, err)
+	c := NewClient(nil, testToken, WithRetry(1, 0))
+	c.baseURL = u
+	return c
+}
+
+func TestRecordedAPI(t *testing.T) {
+	srv := newRecordedAPI(t)
+	defer srv.Close()
+	c := newRecordedClient(t, srv)
+	project, err := ProjectFromURL("https://github.com/org/test1")
+	assert.NilError(t, err)
+
+	t.Run("BuildProject", func(t *testing.T) {
+		summary, err := c.BuildProject(project, os.Stdout, &BuildProjectInput{Branch: "master"}, 10*time.Second)
+		assert.NilError(t, err)
+		assert.Equal(t, 42, summary.BuildNum)
+		assert.Equal(t, "7fd6c5a2-6c55-4e2e-a5b1-93b0e3c0f2f1", summary.Workflow.WorkflowID)
+	})
+	t.Run("GetBuild", func(t *testing.T) {
+		build, err := c.GetBuild(project, os.Stdout, 42)
+		assert.NilError(t, err)
+		assert.Equal(t, lifecycleFinished, build.Lifecycle)
+		assert.Equal(t, false, *build.Failed)
+		assert.Equal(t, "org", build.User.Username)
+		assert.Equal(t, "2.0", build.Platform)
+		assert.Equal(t, "docker", build.Executor())
+		assert.Equal(t, "medium", build.ResourceClass())
+		assert.Equal(t, 4096, build.Picard.ResourceClass.RAM)
+	})
+	t.Run("GetBuildSummary", func(t *testing.T) {
+		summary, err := c.GetBuildSummary(project, os.Stdout, 42)
+		assert.NilError(t, err)
+		assert.Equal(t, 42, summary.BuildNum)
+		assert.Equal(t, lifecycleFinished, summary.Lifecycle)
+		assert.Equal(t, "org", summary.User.Username)
+	})
+	t.Run("BuildSummary", func(t *testing.T) {
+		summaries, err := c.BuildSummary(project, os.Stdout, &BuildSummaryInput{Limit: 2, Filter: "completed"})
+		assert.NilError(t, err)
+		assert.Equal(t, 2, len(summaries))
+		assert.Equal(t, "success", summaries[1].Outcome)
+		assert.Equal(t, "John Doe", summaries[1].AuthorName)
+	})
+	t.Run("unknown build", func(t *testing.T) {
+		_, err := c.GetBuild(project, os.Stdout, 43)
+		_, ok := err.(*ProjectNotFollowedError)
+		assert.Assert(t, ok, "expected *ProjectNotFollowedError, got %T", err)
+		assert.ErrorContains(t, err, "non-success status code returned 404 Not Found: Not found")
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		c := newRecordedClient(t, srv)
+		c.Token = "invalid"
+		_, err := c.Me(os.Stdout)
+		assert.Error(t, err, "non-success status code returned 401 Unauthorized: You must log in first.")
+	})
+}
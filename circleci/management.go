@@ -0,0 +1,130 @@
+package circleci
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// EnvVar ... represents an environment variable object returned by calling
+// /project/:vcs-type/:username/:project/envvar on the CircleCI API v1.1,
+// values are masked by CircleCI
+// https://circleci.com/docs/api/v1-reference/#list-environment-variables
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ListEnvVars ... returns the environment variables of the project
+// https://circleci.com/docs/api/v1-reference/#list-environment-variables
+func (c *Client) ListEnvVars(project *Project, logger io.Writer) ([]*EnvVar, error) {
+	var envVars []*EnvVar
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/envvar", project.v1Path())
+		err := c.requester(c, "GET", url, nil, nil, &envVars)
+		if err != nil {
+			logf(logger, "ListEnvVars failed, GET /%s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return envVars, nil
+}
+
+// DeleteEnvVar ... deletes the environment variable matching name from the project
+// https://circleci.com/docs/api/v1-reference/#delete-environment-variable
+func (c *Client) DeleteEnvVar(project *Project, logger io.Writer, name string) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/envvar/%s", project.v1Path(), name)
+		err := c.requester(c, "DELETE", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "DeleteEnvVar failed, DELETE /%s -> %v", url, err)
+		}
+		return err
+	})
+}
+
+// CheckoutKey ... represents a checkout key object returned by calling
+// /project/:vcs-type/:username/:project/checkout-key on the CircleCI API v1.1
+// https://circleci.com/docs/api/v1-reference/#list-checkout-keys
+type CheckoutKey struct {
+	PublicKey string `json:"public_key"`
+	// deploy-key or github-user-key
+	Type        string     `json:"type"`
+	Fingerprint string     `json:"fingerprint"`
+	Preferred   bool       `json:"preferred"`
+	CreatedAt   *time.Time `json:"time"`
+}
+
+// ListCheckoutKeys ... returns the checkout keys of the project
+// https://circleci.com/docs/api/v1-reference/#list-checkout-keys
+func (c *Client) ListCheckoutKeys(project *Project, logger io.Writer) ([]*CheckoutKey, error) {
+	var keys []*CheckoutKey
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/checkout-key", project.v1Path())
+		err := c.requester(c, "GET", url, nil, nil, &keys)
+		if err != nil {
+			logf(logger, "ListCheckoutKeys failed, GET /%s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteCheckoutKey ... deletes the checkout key matching fingerprint from the project
+// https://circleci.com/docs/api/v1-reference/#delete-checkout-key
+func (c *Client) DeleteCheckoutKey(project *Project, logger io.Writer, fingerprint string) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/checkout-key/%s", project.v1Path(), fingerprint)
+		err := c.requester(c, "DELETE", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "DeleteCheckoutKey failed, DELETE /%s -> %v", url, err)
+		}
+		return err
+	})
+}
+
+// isNotFound ... used internally to check if err is a RequestError for a 404 response
+func isNotFound(err error) bool {
+	reqErr, ok := err.(RequestError)
+	return ok && reqErr.Code == http.StatusNotFound
+}
+
+// TeardownProject ... deletes all environment variables and checkout keys of the
+// project, then unfollows it, resources that are already absent are ignored, so
+// calling TeardownProject repeatedly is safe
+func (c *Client) TeardownProject(project *Project, logger io.Writer) error {
+	envVars, err := c.ListEnvVars(project, logger)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to list environment variables of %s -> %v", project.VcsURL, err)
+	}
+	for _, v := range envVars {
+		err = c.DeleteEnvVar(project, logger, v.Name)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete environment variable %s of %s -> %v", v.Name, project.VcsURL, err)
+		}
+	}
+	keys, err := c.ListCheckoutKeys(project, logger)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to list checkout keys of %s -> %v", project.VcsURL, err)
+	}
+	for _, k := range keys {
+		err = c.DeleteCheckoutKey(project, logger, k.Fingerprint)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete checkout key %s of %s -> %v", k.Fingerprint, project.VcsURL, err)
+		}
+	}
+	err = c.UnfollowProject(project, logger)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// nolint: funlen
+func TestTeardownProject(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	notFound := RequestError{Code: http.StatusNotFound, Message: "non-success status code returned 404 Not Found: Not found"}
+	tt := map[string]struct {
+		responses   map[string]string
+		errors      map[string]error
+		expected    []string
+		expectedErr string
+	}{
+		"delete everything": {
+			responses: map[string]string{
+				"GET project/github/org/test1/envvar":       `[{"name": "FOO", "value": "xxxxo"}, {"name": "BAR", "value": "xxxxr"}]`,
+				"GET project/github/org/test1/checkout-key": `[{"fingerprint": "c9:0b", "type": "deploy-key"}]`,
+			},
+			expected: []string{
+				"GET project/github/org/test1/envvar",
+				"DELETE project/github/org/test1/envvar/FOO",
+				"DELETE project/github/org/test1/envvar/BAR",
+				"GET project/github/org/test1/checkout-key",
+				"DELETE project/github/org/test1/checkout-key/c9:0b",
+				"POST project/github/org/test1/unfollow",
+			},
+		},
+		"already torn down": {
+			errors: map[string]error{
+				"GET project/github/org/test1/envvar":       notFound,
+				"GET project/github/org/test1/checkout-key": notFound,
+				"POST project/github/org/test1/unfollow":    notFound,
+			},
+			expected: []string{
+				"GET project/github/org/test1/envvar",
+				"GET project/github/org/test1/checkout-key",
+				"POST project/github/org/test1/unfollow",
+			},
+		},
+		"env var deleted concurrently": {
+			responses: map[string]string{
+				"GET project/github/org/test1/envvar": `[{"name": "FOO", "value": "xxxxo"}]`,
+			},
+			errors: map[string]error{
+				"DELETE project/github/org/test1/envvar/FOO": notFound,
+			},
+			expected: []string{
+				"GET project/github/org/test1/envvar",
+				"DELETE project/github/org/test1/envvar/FOO",
+				"GET project/github/org/test1/checkout-key",
+				"POST project/github/org/test1/unfollow",
+			},
+		},
+		"delete failed": {
+			responses: map[string]string{
+				"GET project/github/org/test1/checkout-key": `[{"fingerprint": "c9:0b", "type": "deploy-key"}]`,
+			},
+			errors: map[string]error{
+				"DELETE project/github/org/test1/checkout-key/c9:0b": fmt.Errorf("test error"),
+			},
+			expected: []string{
+				"GET project/github/org/test1/envvar",
+				"GET project/github/org/test1/checkout-key",
+				"DELETE project/github/org/test1/checkout-key/c9:0b",
+			},
+			expectedErr: "failed to delete checkout key c9:0b of https://github.com/org/test1 -> test error",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var actual []string
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				route := method + " " + path
+				actual = append(actual, route)
+				if err, ok := tc.errors[route]; ok {
+					return err
+				}
+				if resp, ok := tc.responses[route]; ok {
+					return json.Unmarshal([]byte(resp), output)
+				}
+				return nil
+			})
+			err := client.TeardownProject(&project, os.Stdout)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
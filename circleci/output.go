@@ -0,0 +1,212 @@
+package circleci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// BuildStep ... represents a step object returned in
+// the steps property of a build
+// https://circleci.com/docs/api/v1-reference/#build
+type BuildStep struct {
+	Name    string         `json:"name"`
+	Actions []*BuildAction `json:"actions"`
+}
+
+// BuildAction ... represents an action object returned in the actions
+// property of a build step, steps have one action per parallel container
+type BuildAction struct {
+	Name   string `json:"name"`
+	Index  int    `json:"index"`
+	Step   int    `json:"step"`
+	Status string `json:"status"`
+	Failed *bool  `json:"failed"`
+	//nil if the action has not finished
+	ExitCode  *int   `json:"exit_code"`
+	HasOutput bool   `json:"has_output"`
+	OutputURL string `json:"output_url"`
+}
+
+// failed ... returns true if the action failed or exited with a non-zero exit code
+func (a *BuildAction) failed() bool {
+	return (a.Failed != nil && *a.Failed) || a.Status == "failed" || (a.ExitCode != nil && *a.ExitCode != 0)
+}
+
+// FailedStepNotFoundError ... a failed step was not found when calling GetFailedStepOutput
+type FailedStepNotFoundError struct {
+	Message string
+}
+
+func (e *FailedStepNotFoundError) Error() string {
+	return e.Message
+}
+
+// AllNodes ... can be provided as the nodeIndex of the output-fetching
+// methods to return the output of every parallel container
+const AllNodes = -1
+
+// GetFailedStepOutput ... returns the output of the first failed step
+// of the build matching buildNum
+func (c *Client) GetFailedStepOutput(project *Project, logger io.Writer, buildNum int) (string, error) {
+	return c.GetFailedStepOutputForNode(project, logger, buildNum, AllNodes)
+}
+
+// GetFailedStepOutputForNode ... returns the output of the first failed step of
+// the build matching buildNum on the parallel container matching nodeIndex, or
+// of the first failed step on any container if nodeIndex is AllNodes
+func (c *Client) GetFailedStepOutputForNode(project *Project, logger io.Writer, buildNum int, nodeIndex int) (string, error) {
+	build, err := c.GetBuild(project, logger, buildNum)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range build.Steps {
+		for _, a := range s.Actions {
+			if !a.failed() || !a.onNode(nodeIndex) {
+				continue
+			}
+			if !a.HasOutput || len(a.OutputURL) == 0 {
+				return "", nil
+			}
+			return c.downloadOutput(a.OutputURL)
+		}
+	}
+	return "", &FailedStepNotFoundError{Message: fmt.Sprintf("failed to locate a failed step in build %s [%d]", project.Reponame, buildNum)}
+}
+
+// StepNotFoundError ... a step was not found when calling GetStepOutput
+type StepNotFoundError struct {
+	Message string
+}
+
+func (e *StepNotFoundError) Error() string {
+	return e.Message
+}
+
+// GetStepOutput ... returns the output of the step matching stepName of the build
+// matching buildNum on the parallel container matching nodeIndex, if nodeIndex is
+// AllNodes the output of every container is returned in order of the container
+// index, each preceded by a header line when the step ran on multiple containers
+func (c *Client) GetStepOutput(project *Project, logger io.Writer, buildNum int, stepName string, nodeIndex int) (string, error) {
+	build, err := c.GetBuild(project, logger, buildNum)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range build.Steps {
+		if s.Name != stepName {
+			continue
+		}
+		var actions []*BuildAction
+		for _, a := range s.Actions {
+			if a.onNode(nodeIndex) {
+				actions = append(actions, a)
+			}
+		}
+		if len(actions) == 0 {
+			break
+		}
+		sort.SliceStable(actions, func(i, j int) bool {
+			return actions[i].Index < actions[j].Index
+		})
+		var sb strings.Builder
+		for _, a := range actions {
+			if len(actions) > 1 {
+				fmt.Fprintf(&sb, "==> node %d <==\n", a.Index)
+			}
+			if !a.HasOutput || len(a.OutputURL) == 0 {
+				continue
+			}
+			output, err := c.downloadOutput(a.OutputURL)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(output)
+		}
+		return sb.String(), nil
+	}
+	return "", &StepNotFoundError{Message: fmt.Sprintf("failed to locate step %q on node %d in build %s [%d]", stepName, nodeIndex, project.Reponame, buildNum)}
+}
+
+// onNode ... returns true if the action ran on the parallel container
+// matching nodeIndex, or nodeIndex is AllNodes
+func (a *BuildAction) onNode(nodeIndex int) bool {
+	return nodeIndex == AllNodes || a.Index == nodeIndex
+}
+
+// outputMessage ... represents a single message in the output of an action
+type outputMessage struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// downloadOutput ... used internally to download the output of an action, output
+// URLs are pre-signed and not hosted by CircleCI, so the access key is never sent
+func (c *Client) downloadOutput(outputURL string) (string, error) {
+	req, err := http.NewRequest("GET", outputURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		err = resp.Body.Close()
+		if err != nil {
+			log.Printf("failed to close response body -> %v\n", err)
+		}
+	}()
+	if resp.StatusCode >= http.StatusMultipleChoices || resp.StatusCode < http.StatusOK {
+		return "", newRequestError(resp)
+	}
+	var messages []*outputMessage
+	err = json.NewDecoder(resp.Body).Decode(&messages)
+	if err != nil {
+		return "", &decodeError{err: err}
+	}
+	var sb strings.Builder
+	for _, m := range messages {
+		sb.WriteString(m.Message)
+	}
+	return sb.String(), nil
+}
+
+// failedOutputLines ... the number of lines of failed step output
+// included in the error returned when a build fails
+const failedOutputLines = 20
+
+// buildFailedError ... used internally to create the error returned when a build
+// fails, includes the messages of the build, such as configuration errors, and
+// the tail of the failed step output if it is available
+func (c *Client) buildFailedError(project *Project, logger io.Writer, build *Build) error {
+	msg := fmt.Sprintf("build %s [%d] failed", project.Reponame, build.BuildNum)
+	var messages []string
+	for _, m := range build.Messages {
+		if len(m.Message) > 0 {
+			messages = append(messages, m.Message)
+		}
+	}
+	if len(messages) > 0 {
+		msg += ": " + strings.Join(messages, "; ")
+	}
+	output, err := c.GetFailedStepOutput(project, logger, build.BuildNum)
+	if err != nil || len(output) == 0 {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%s, output of failed step:\n%s", msg, tailLines(output, failedOutputLines))
+}
+
+// tailLines ... used internally to return the last n lines of s
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,202 @@
+package circleci
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// nolint: funlen
+func TestGetFailedStepOutput(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "", r.URL.Query().Get("circle-token"))
+		switch r.URL.Path {
+		case "/failed":
+			_, _ = w.Write([]byte(`[{"message": "running tests\n", "type": "out"}, {"message": "FAIL: TestBuild\n", "type": "out"}]`))
+		default:
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	exitCode := func(i int) *int { return &i }
+	tt := map[string]struct {
+		steps       []*BuildStep
+		expected    string
+		expectedErr string
+	}{
+		"failed step": {
+			steps: []*BuildStep{
+				{Name: "checkout", Actions: []*BuildAction{{Status: "success", ExitCode: exitCode(0), HasOutput: true, OutputURL: srv.URL + "/passed"}}},
+				{Name: "test", Actions: []*BuildAction{{Status: "failed", ExitCode: exitCode(1), HasOutput: true, OutputURL: srv.URL + "/failed"}}},
+			},
+			expected: "running tests\nFAIL: TestBuild\n",
+		},
+		"failed step without output": {
+			steps: []*BuildStep{
+				{Name: "test", Actions: []*BuildAction{{Status: "failed", Failed: boolPtr(true)}}},
+			},
+		},
+		"expired output url": {
+			steps: []*BuildStep{
+				{Name: "test", Actions: []*BuildAction{{Status: "failed", HasOutput: true, OutputURL: srv.URL + "/expired"}}},
+			},
+			expectedErr: "non-success status code returned 403 Forbidden: AccessDenied",
+		},
+		"no failed step": {
+			steps: []*BuildStep{
+				{Name: "test", Actions: []*BuildAction{{Status: "success", ExitCode: exitCode(0)}}},
+			},
+			expectedErr: "failed to locate a failed step in build test1 [42]",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				assert.Equal(t, "project/github/org/test1/42", path)
+				build, ok := output.(*Build)
+				if !ok {
+					return fmt.Errorf("unknown output type: %T", output)
+				}
+				build.Steps = tc.steps
+				return nil
+			})
+			actual, err := client.GetFailedStepOutput(&project, os.Stdout, 42)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestGetStepOutput(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(`[{"message": "output of %s\n", "type": "out"}]`, strings.TrimPrefix(r.URL.Path, "/"))))
+	}))
+	defer srv.Close()
+	steps := []*BuildStep{
+		{Name: "checkout", Actions: []*BuildAction{{Index: 0, Status: "success", HasOutput: true, OutputURL: srv.URL + "/checkout"}}},
+		{Name: "test", Actions: []*BuildAction{
+			{Index: 1, Status: "failed", HasOutput: true, OutputURL: srv.URL + "/node1"},
+			{Index: 0, Status: "success", HasOutput: true, OutputURL: srv.URL + "/node0"},
+		}},
+	}
+	tt := map[string]struct {
+		stepName    string
+		nodeIndex   int
+		expected    string
+		expectedErr string
+	}{
+		"single node": {
+			stepName:  "test",
+			nodeIndex: 1,
+			expected:  "output of node1\n",
+		},
+		"all nodes": {
+			stepName:  "test",
+			nodeIndex: AllNodes,
+			expected:  "==> node 0 <==\noutput of node0\n==> node 1 <==\noutput of node1\n",
+		},
+		"all nodes of single node step": {
+			stepName:  "checkout",
+			nodeIndex: AllNodes,
+			expected:  "output of checkout\n",
+		},
+		"unknown node": {
+			stepName:    "checkout",
+			nodeIndex:   1,
+			expectedErr: `failed to locate step "checkout" on node 1 in build test1 [42]`,
+		},
+		"unknown step": {
+			stepName:    "deploy",
+			nodeIndex:   AllNodes,
+			expectedErr: `failed to locate step "deploy" on node -1 in build test1 [42]`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				build, ok := output.(*Build)
+				if !ok {
+					return fmt.Errorf("unknown output type: %T", output)
+				}
+				build.Steps = steps
+				return nil
+			})
+			actual, err := client.GetStepOutput(&project, os.Stdout, 42, tc.stepName, tc.nodeIndex)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+	t.Run("failed step on node", func(t *testing.T) {
+		client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+			output.(*Build).Steps = steps
+			return nil
+		})
+		actual, err := client.GetFailedStepOutputForNode(&project, os.Stdout, 42, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, "output of node1\n", actual)
+		_, err = client.GetFailedStepOutputForNode(&project, os.Stdout, 42, 0)
+		assert.Error(t, err, "failed to locate a failed step in build test1 [42]")
+	})
+}
+
+func TestTailLines(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	assert.Equal(t, "line 29\nline 30", tailLines(strings.Join(lines, "\n")+"\n", 2))
+	assert.Equal(t, "line 1", tailLines("line 1\n", 20))
+}
+
+func TestBuildFailedError(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		// no failed step output is available
+		return nil
+	})
+	tt := map[string]struct {
+		messages []*BuildMessage
+		expected string
+	}{
+		"no messages": {expected: "build test1 [42] failed"},
+		"config error": {
+			messages: []*BuildMessage{{Type: "error", Message: "config.yml is invalid: jobs.test.steps is required"}, {Type: "warning"}},
+			expected: "build test1 [42] failed: config.yml is invalid: jobs.test.steps is required",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			err := client.buildFailedError(&project, os.Stdout, &Build{BuildNum: 42, Messages: tc.messages})
+			assert.Error(t, err, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// apiV2Path ... used internally to represent the base path for CircleCI API v2,
+// it is resolved against the host of the client's baseURL
+const apiV2Path = "/api/v2/"
+
+// pageV2 ... used internally to represent a single page of results
+// returned by the list endpoints of the CircleCI API v2
+type pageV2 struct {
+	Items         json.RawMessage `json:"items"`
+	NextPageToken string          `json:"next_page_token"`
+}
+
+// getAllPagesV2 ... used internally to request every page of results from a
+// list endpoint of the CircleCI API v2, following next_page_token until the last
+// page is reached, collect is called with the items of each page in order and
+// may return errStopPaging to stop before the last page
+func (c *Client) getAllPagesV2(path string, logger io.Writer, params url.Values, collect func(json.RawMessage) error) error {
+	var pageToken string
+	for {
+		pageParams := url.Values{}
+		for k, v := range params {
+			pageParams[k] = v
+		}
+		if len(pageToken) > 0 {
+			pageParams.Set("page-token", pageToken)
+		}
+		var page pageV2
+		err := c.retry(logger, func() error {
+			return c.requester(c, "GET", path, pageParams, nil, &page)
+		})
+		if err != nil {
+			return err
+		}
+		if len(page.Items) > 0 {
+			err = collect(page.Items)
+			if err == errStopPaging {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if len(page.NextPageToken) == 0 {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Slug ... returns the CircleCI API v2 project slug for the project
+// in the format vcs-slug/org-name/repo-name
+// https://circleci.com/docs/api/v2/#section/Project-Slugs
+func (p *Project) Slug() string {
+	return fmt.Sprintf("%s/%s/%s", p.VCS().V2(), p.Username, p.Reponame)
+}
+
+// TriggerPipelineInput ... contains data necessary to trigger a new pipeline
+// https://circleci.com/docs/api/v2/#trigger-a-new-pipeline
+type TriggerPipelineInput struct {
+	//The branch to build. Cannot be used with tag parameter.
+	Branch string `json:"branch,omitempty"`
+	//The git tag to build. Cannot be used with branch parameter.
+	Tag string `json:"tag,omitempty"`
+	//Pipeline parameters, values may be strings, booleans or integers
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Pipeline ... represents the object returned when triggering
+// or requesting a pipeline from the CircleCI API v2
+// https://circleci.com/docs/api/v2/#get-a-pipeline
+type Pipeline struct {
+	ID        string           `json:"id"`
+	State     string           `json:"state"`
+	Number    int              `json:"number"`
+	CreatedAt *time.Time       `json:"created_at"`
+	Vcs       *PipelineVcs     `json:"vcs"`
+	Trigger   *PipelineTrigger `json:"trigger"`
+	Errors    []*PipelineError `json:"errors"`
+}
+
+// PipelineError ... represents an error in the errors property of a Pipeline
+type PipelineError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// PipelineVcs ... represents the vcs property of a Pipeline
+type PipelineVcs struct {
+	Branch   string `json:"branch"`
+	Revision string `json:"revision"`
+	Tag      string `json:"tag"`
+}
+
+// PipelineTrigger ... represents the trigger property of a Pipeline
+type PipelineTrigger struct {
+	// api, explicit, schedule or webhook
+	Type  string `json:"type"`
+	Actor *User  `json:"actor"`
+}
+
+// TriggerPipeline ... attempts to trigger a new pipeline for the project
+// using the CircleCI API v2, returns the *Pipeline that was created
+// https://circleci.com/docs/api/v2/#trigger-a-new-pipeline
+func (c *Client) TriggerPipeline(project *Project, logger io.Writer, input *TriggerPipelineInput) (*Pipeline, error) {
+	var pipeline Pipeline
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%sproject/%s/pipeline", apiV2Path, project.Slug())
+		err := c.requester(c, "POST", url, nil, input, &pipeline)
+		if err != nil {
+			logf(logger, "TriggerPipeline failed, POST %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+// TriggerOnly ... triggers a new pipeline for the project using the CircleCI API v2
+// and returns immediately without waiting for any builds to start, the revision
+// parameter is not supported by the CircleCI API v2
+func (c *Client) TriggerOnly(project *Project, logger io.Writer, input *BuildProjectInput) (*Pipeline, error) {
+	if len(input.Revision) > 0 {
+		return nil, fmt.Errorf("revision cannot be used when triggering a pipeline: %s", input)
+	}
+	input, err := input.request()
+	if err != nil {
+		return nil, err
+	}
+	return c.TriggerPipeline(project, logger, &TriggerPipelineInput{
+		Branch:     input.Branch,
+		Tag:        input.Tag,
+		Parameters: input.Parameters,
+	})
+}
+
+// PipelineConfig ... represents the object returned by calling
+// /pipeline/:id/config on the CircleCI API v2
+// https://circleci.com/docs/api/v2/#get-a-pipeline-39-s-configuration
+type PipelineConfig struct {
+	Source   string `json:"source"`
+	Compiled string `json:"compiled"`
+	//only populated for pipelines using dynamic configuration
+	SetupConfig         string `json:"setup-config"`
+	CompiledSetupConfig string `json:"compiled-setup-config"`
+}
+
+// GetPipelineConfig ... returns the *PipelineConfig for the given pipelineID
+// https://circleci.com/docs/api/v2/#get-a-pipeline-39-s-configuration
+func (c *Client) GetPipelineConfig(pipelineID string, logger io.Writer) (*PipelineConfig, error) {
+	var config PipelineConfig
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%spipeline/%s/config", apiV2Path, pipelineID)
+		err := c.requester(c, "GET", url, nil, nil, &config)
+		if err != nil {
+			logf(logger, "GetPipelineConfig failed, GET %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// PipelineWorkflows ... returns all workflows within the pipeline matching the given pipelineID
+// https://circleci.com/docs/api/v2/#get-a-pipeline-39-s-workflows
+func (c *Client) PipelineWorkflows(pipelineID string, logger io.Writer) ([]*Workflow, error) {
+	var workflows []*Workflow
+	url := fmt.Sprintf("%spipeline/%s/workflow", apiV2Path, pipelineID)
+	err := c.getAllPagesV2(url, logger, nil, func(items json.RawMessage) error {
+		var page []*Workflow
+		err := json.Unmarshal(items, &page)
+		workflows = append(workflows, page...)
+		return err
+	})
+	if err != nil {
+		logf(logger, "PipelineWorkflows failed, GET %s -> %v", url, err)
+		return nil, err
+	}
+	return workflows, nil
+}
+
+// GetPipelineByNumber ... returns the *Pipeline matching the pipelineNumber within the project
+// https://circleci.com/docs/api/v2/#get-a-pipeline
+func (c *Client) GetPipelineByNumber(project *Project, logger io.Writer, pipelineNumber int) (*Pipeline, error) {
+	var pipeline Pipeline
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%sproject/%s/pipeline/%d", apiV2Path, project.Slug(), pipelineNumber)
+		err := c.requester(c, "GET", url, nil, nil, &pipeline)
+		if err != nil {
+			logf(logger, "GetPipelineByNumber failed, GET %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+// GetPipelineBuilds ... returns a *BuildSummaryOutput for every job of every workflow
+// within the pipeline matching pipelineNumber, approval jobs are excluded and jobs
+// that have not started have a BuildNum of zero, the summaries are mapped from the
+// CircleCI API v2 job objects and only contain the properties available from the
+// CircleCI API v2, including the ref and the actor of the pipeline
+func (c *Client) GetPipelineBuilds(project *Project, logger io.Writer, pipelineNumber int) ([]*BuildSummaryOutput, error) {
+	pipeline, err := c.GetPipelineByNumber(project, logger, pipelineNumber)
+	if err != nil {
+		return nil, err
+	}
+	return c.pipelineBuilds(project, logger, pipeline)
+}
+
+// pipelineBuilds ... used internally to return a *BuildSummaryOutput for every
+// job of the given pipeline, including the ref and actor of the pipeline, jobs
+// that have not started have no job number, so their BuildNum is zero
+func (c *Client) pipelineBuilds(project *Project, logger io.Writer, pipeline *Pipeline) ([]*BuildSummaryOutput, error) {
+	workflows, err := c.PipelineWorkflows(pipeline.ID, logger)
+	if err != nil {
+		return nil, err
+	}
+	var summaries []*BuildSummaryOutput
+	for _, w := range workflows {
+		jobs, err := c.WorkflowJobs(w.ID, logger)
+		if err != nil {
+			return nil, err
+		}
+		for _, j := range jobs {
+			if j.Type == "approval" {
+				continue
+			}
+			s := jobSummary(project, w, j)
+			if s.QueuedAt == nil {
+				s.QueuedAt = pipeline.CreatedAt
+			}
+			if pipeline.Vcs != nil {
+				s.Branch, s.Revision, s.VcsTag = pipeline.Vcs.Branch, pipeline.Vcs.Revision, pipeline.Vcs.Tag
+			}
+			if pipeline.Trigger != nil {
+				s.User = pipeline.Trigger.Actor
+			}
+			summaries = append(summaries, s)
+		}
+	}
+	return summaries, nil
+}
+
+// jobSummary ... used internally to map a job of a workflow from the
+// CircleCI API v2 to the *BuildSummaryOutput of the CircleCI API v1.1
+func jobSummary(project *Project, w *Workflow, j *WorkflowJob) *BuildSummaryOutput {
+	summary := &BuildSummaryOutput{
+		BuildNum:  j.JobNumber,
+		Username:  project.Username,
+		Reponame:  project.Reponame,
+		Vcs:       project.VCS().V1(),
+		Status:    j.Status,
+		QueuedAt:  j.QueuedAt,
+		StartTime: j.StartedAt,
+		StoppedAt: j.StoppedAt,
+		Workflow: &BuildWorkflow{
+			JobName:      j.Name,
+			JobID:        j.ID,
+			WorkflowName: w.Name,
+			WorkflowID:   w.ID,
+		},
+	}
+	// jobs listed by workflow may not report when they were queued
+	if summary.QueuedAt == nil {
+		summary.QueuedAt = j.CreatedAt
+	}
+	if summary.QueuedAt == nil {
+		summary.QueuedAt = w.CreatedAt
+	}
+	summary.Lifecycle, summary.Outcome = jobLifecycle(j.Status)
+	return summary
+}
+
+// recentBuildSummaries ... used internally while waiting for a triggered build
+// to appear, returns the most recent build summaries of the project, using the
+// CircleCI API v2 only the jobs of the newest pipeline of the branch of input
+// are returned, rather than the jobs of every recent pipeline on each poll
+func (c *Client) recentBuildSummaries(project *Project, logger io.Writer, input *BuildProjectInput) ([]*BuildSummaryOutput, error) {
+	if c.APIVersion != APIv2 {
+		return c.BuildSummary(project, logger, nil)
+	}
+	params := url.Values{}
+	if len(input.Branch) > 0 {
+		params.Set("branch", input.Branch)
+	}
+	var newest *Pipeline
+	path := fmt.Sprintf("%sproject/%s/pipeline", apiV2Path, project.Slug())
+	err := c.getAllPagesV2(path, logger, params, func(items json.RawMessage) error {
+		var page []*Pipeline
+		err := json.Unmarshal(items, &page)
+		if err != nil {
+			return err
+		}
+		if len(page) > 0 {
+			newest = page[0]
+		}
+		return errStopPaging
+	})
+	if err != nil {
+		logf(logger, "BuildSummary failed, GET %s -> %v", path, err)
+		return nil, err
+	}
+	if newest == nil {
+		return nil, nil
+	}
+	return c.pipelineBuilds(project, logger, newest)
+}
+
+// jobLifecycle ... used internally to map the status of a job from the CircleCI
+// API v2 to the lifecycle and outcome of a build of the CircleCI API v1.1, the
+// outcome is empty until the job has finished
+func jobLifecycle(status string) (lifecycle string, outcome string) {
+	switch status {
+	case "running", "queued", "not_running", "not_run", "on_hold", "blocked":
+		return status, ""
+	}
+	return lifecycleFinished, status
+}
+
+// PipelineResult ... the aggregate result of every workflow of
+// a pipeline, returned by WaitForPipeline
+type PipelineResult struct {
+	PipelineID string
+	Workflows  []*Workflow
+	//workflows that did not succeed, including workflows that are on hold
+	Failed []*Workflow
+	//true if every workflow of the pipeline succeeded
+	Success bool
+}
+
+// WaitForPipeline ... polls every workflow of the pipeline matching pipelineID until
+// each has finished or is on hold, returns the aggregate *PipelineResult, unlike
+// WaitForProjectBuild every workflow of a pipeline with multiple workflows is waited
+// on, the result is successful if every workflow succeeded, using the WorkflowSucceeded
+// predicate of the client if it is set, a *JobTimeoutError is
+// returned if the workflows have not finished within timeout
+func (c *Client) WaitForPipeline(project *Project, logger io.Writer, pipelineID string, timeout time.Duration) (*PipelineResult, error) {
+	result := &PipelineResult{PipelineID: pipelineID}
+	err := waiter(c.context(), c.pollInterval(), time.Now().Add(timeout), func(count int) (bool, error) {
+		if count%10 == 0 {
+			infof(logger, "waiting for the workflows of pipeline %s [%s] to finish\n", project.Reponame, pipelineID)
+		}
+		workflows, err := c.PipelineWorkflows(pipelineID, logger)
+		if err != nil {
+			// the request was already retried, keep polling so a
+			// transient failure does not abandon the pipeline
+			logf(logger, "failed to get workflows of pipeline %s [%s] -> %v\n", project.Reponame, pipelineID, err)
+			return false, nil
+		}
+		// workflows are created shortly after the pipeline
+		if len(workflows) == 0 {
+			return false, nil
+		}
+		for _, w := range workflows {
+			if !w.Finished() && w.Status != workflowStatusOnHold {
+				return false, nil
+			}
+		}
+		result.Workflows = workflows
+		return true, nil
+	})
+	if err != nil {
+		if _, ok := err.(*timeoutExceededError); ok {
+			return nil, &JobTimeoutError{Message: fmt.Sprintf("timeout exceeded while waiting for the workflows of pipeline %s [%s] to finish", project.Reponame, pipelineID)}
+		}
+		return nil, err
+	}
+	for _, w := range result.Workflows {
+		if !c.workflowSucceeded(w) {
+			result.Failed = append(result.Failed, w)
+		}
+	}
+	result.Success = len(result.Failed) == 0
+	return result, nil
+}
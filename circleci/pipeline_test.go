@@ -0,0 +1,341 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestSlug(t *testing.T) {
+	tt := map[string]struct {
+		project  Project
+		expected string
+	}{
+		"github":    {project: Project{Vcs: "github", Username: "org", Reponame: "test1"}, expected: "gh/org/test1"},
+		"bitbucket": {project: Project{Vcs: "bitbucket", Username: "org", Reponame: "test1"}, expected: "bb/org/test1"},
+		"short":     {project: Project{Vcs: "gh", Username: "org", Reponame: "test1"}, expected: "gh/org/test1"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.project.Slug())
+		})
+	}
+}
+
+func TestTriggerPipelineInputParameters(t *testing.T) {
+	t.Run("integer parameters serialize as integers", func(t *testing.T) {
+		in := &TriggerPipelineInput{
+			Branch:     "master",
+			Parameters: map[string]interface{}{"parallelism": 4},
+		}
+		b, err := json.Marshal(in)
+		assert.NilError(t, err)
+		assert.Equal(t, `{"branch":"master","parameters":{"parallelism":4}}`, string(b))
+	})
+	t.Run("decoded parameters serialize as integers", func(t *testing.T) {
+		// parameters decoded from a Buildfile pass through interface{}
+		// and become float64, these must not be encoded as 4.0
+		var params map[string]interface{}
+		err := json.Unmarshal([]byte(`{"parallelism": 4, "deploy": true, "env": "prod"}`), &params)
+		assert.NilError(t, err)
+		b, err := json.Marshal(&TriggerPipelineInput{Parameters: params})
+		assert.NilError(t, err)
+		assert.Equal(t, `{"parameters":{"deploy":true,"env":"prod","parallelism":4}}`, string(b))
+	})
+}
+
+func TestTriggerPipeline(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		resp        string
+		err         error
+		expectedErr string
+		expected    *Pipeline
+		slow        bool
+	}{
+		"happy path": {
+			resp:     `{"id": "5034460f-c7c4-4c43-9457-de07e2029e7b", "state": "created", "number": 25}`,
+			expected: &Pipeline{ID: "5034460f-c7c4-4c43-9457-de07e2029e7b", State: "created", Number: 25},
+		},
+		"requester error": {
+			resp:        `{}`,
+			err:         fmt.Errorf("%s", "test error"),
+			expectedErr: "test error",
+			slow:        true,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			if tc.slow && testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				assert.Equal(t, "POST", method)
+				assert.Equal(t, "/api/v2/project/gh/org/test1/pipeline", path)
+				err := json.Unmarshal([]byte(tc.resp), output)
+				if err != nil {
+					return fmt.Errorf("failed to decode response: %v", err)
+				}
+				return tc.err
+			})
+			actual, err := client.TriggerPipeline(&project, os.Stdout, &TriggerPipelineInput{Branch: "master"})
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+				assert.DeepEqual(t, tc.expected, actual)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestTriggerOnly(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		in          BuildProjectInput
+		expectedErr string
+		expected    *Pipeline
+	}{
+		"branch": {
+			in:       BuildProjectInput{Branch: "master", Parameters: map[string]interface{}{"deploy": true}},
+			expected: &Pipeline{ID: "test", Number: 25},
+		},
+		"revision": {
+			in:          BuildProjectInput{Branch: "master", Revision: "abc123"},
+			expectedErr: `revision cannot be used when triggering a pipeline: [Branch: "master", Revision: "abc123", Tag: ""]`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				in := input.(*TriggerPipelineInput)
+				assert.Equal(t, tc.in.Branch, in.Branch)
+				assert.DeepEqual(t, tc.in.Parameters, in.Parameters)
+				return json.Unmarshal([]byte(`{"id": "test", "number": 25}`), output)
+			})
+			actual, err := client.TriggerOnly(&project, os.Stdout, &tc.in)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestGetAllPagesV2(t *testing.T) {
+	pages := map[string]string{
+		"":      `{"items": [1, 2], "next_page_token": "page2"}`,
+		"page2": `{"items": [3], "next_page_token": "page3"}`,
+		"page3": `{"items": [], "next_page_token": null}`,
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "/api/v2/test", path)
+		assert.Equal(t, "value", params.Get("param"))
+		return json.Unmarshal([]byte(pages[params.Get("page-token")]), output)
+	})
+	var actual []int
+	err := client.getAllPagesV2("/api/v2/test", os.Stdout, url.Values{"param": {"value"}}, func(items json.RawMessage) error {
+		var page []int
+		err := json.Unmarshal(items, &page)
+		actual = append(actual, page...)
+		return err
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []int{1, 2, 3}, actual)
+}
+
+func TestGetPipelineBuilds(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		switch path {
+		case "/api/v2/project/gh/org/test1/pipeline/25":
+			return json.Unmarshal([]byte(`{"id": "p1", "number": 25}`), output)
+		case "/api/v2/pipeline/p1/workflow":
+			return json.Unmarshal([]byte(`{"items": [{"id": "wf1", "name": "build"}, {"id": "wf2", "name": "deploy"}]}`), output)
+		case "/api/v2/workflow/wf1/job":
+			return json.Unmarshal([]byte(`{"items": [{"id": "j1", "name": "test", "job_number": 10, "type": "build", "status": "success"}]}`), output)
+		case "/api/v2/workflow/wf2/job":
+			return json.Unmarshal([]byte(`{"items": [{"id": "j2", "name": "hold", "type": "approval", "status": "on_hold"},
+					{"id": "j3", "name": "push", "job_number": 11, "type": "build", "status": "running"},
+					{"id": "j4", "name": "notify", "type": "build", "status": "blocked"}]}`), output)
+		}
+		t.Fatalf("unexpected request: %s %s", method, path)
+		return nil
+	})
+
+	summaries, err := client.GetPipelineBuilds(project, os.Stdout, 25)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(summaries))
+
+	assert.Equal(t, 10, summaries[0].BuildNum)
+	assert.Equal(t, "finished", summaries[0].Lifecycle)
+	assert.Equal(t, "success", summaries[0].Outcome)
+	assert.Equal(t, "test", summaries[0].Workflow.JobName)
+	assert.Equal(t, "build", summaries[0].Workflow.WorkflowName)
+
+	assert.Equal(t, 11, summaries[1].BuildNum)
+	assert.Equal(t, "running", summaries[1].Lifecycle)
+	assert.Equal(t, "", summaries[1].Outcome)
+	assert.Equal(t, "wf2", summaries[1].Workflow.WorkflowID)
+	assert.Equal(t, "test1", summaries[1].Reponame)
+
+	// jobs that have not started are included without a build number
+	assert.Equal(t, 0, summaries[2].BuildNum)
+	assert.Equal(t, "blocked", summaries[2].Lifecycle)
+	assert.Equal(t, "notify", summaries[2].Workflow.JobName)
+}
+
+func TestWaitForPipeline(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		polls       []string
+		expected    []string
+		expectedErr string
+	}{
+		"all succeeded": {
+			polls: []string{
+				`{"items": []}`,
+				`{"items": [{"id": "wf1", "name": "build", "status": "running"}, {"id": "wf2", "name": "lint", "status": "success"}]}`,
+				`{"items": [{"id": "wf1", "name": "build", "status": "success"}, {"id": "wf2", "name": "lint", "status": "success"}]}`,
+			},
+		},
+		"one failed": {
+			polls: []string{
+				`{"items": [{"id": "wf1", "name": "build", "status": "failed"}, {"id": "wf2", "name": "lint", "status": "running"}]}`,
+				`{"items": [{"id": "wf1", "name": "build", "status": "failed"}, {"id": "wf2", "name": "lint", "status": "success"}]}`,
+			},
+			expected: []string{"build"},
+		},
+		"on hold": {
+			polls: []string{
+				`{"items": [{"id": "wf1", "name": "deploy", "status": "on_hold"}, {"id": "wf2", "name": "lint", "status": "success"}]}`,
+			},
+			expected: []string{"deploy"},
+		},
+		"timeout": {
+			polls: []string{
+				`{"items": [{"id": "wf1", "name": "build", "status": "running"}]}`,
+			},
+			expectedErr: "timeout exceeded while waiting for the workflows of pipeline test1 [p1] to finish",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var count int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				assert.Equal(t, "/api/v2/pipeline/p1/workflow", path)
+				poll := tc.polls[len(tc.polls)-1]
+				if count < len(tc.polls) {
+					poll = tc.polls[count]
+				}
+				count++
+				return json.Unmarshal([]byte(poll), output)
+			})
+			client.PollInterval = time.Millisecond
+			result, err := client.WaitForPipeline(project, os.Stdout, "p1", 50*time.Millisecond)
+			if len(tc.expectedErr) > 0 {
+				assert.Error(t, err, tc.expectedErr)
+				_, ok := err.(*JobTimeoutError)
+				assert.Assert(t, ok, "expected *JobTimeoutError, got %T", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, 2, len(result.Workflows))
+			var failed []string
+			for _, w := range result.Failed {
+				failed = append(failed, w.Name)
+			}
+			assert.DeepEqual(t, tc.expected, failed)
+			assert.Equal(t, len(tc.expected) == 0, result.Success)
+		})
+	}
+}
+
+func TestBuildProjectNoWorkflows(t *testing.T) {
+	defer func(d time.Duration) { pipelineGracePeriod = d }(pipelineGracePeriod)
+	pipelineGracePeriod = 0
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		pipeline     string
+		workflows    string
+		expectedErr  string
+		expectedType string
+	}{
+		"no workflows": {
+			pipeline:     `{"id": "p5", "number": 5, "state": "created"}`,
+			workflows:    `{"items": []}`,
+			expectedErr:  "no builds were triggered for project test1 matching [Branch: \"master\", Revision: \"\", Tag: \"\"], pipeline 5 has no workflows (possibly filtered by config)",
+			expectedType: "*circleci.NoBuildsTriggeredError",
+		},
+		"workflows without builds": {
+			pipeline:     `{"id": "p5", "number": 5, "state": "created"}`,
+			workflows:    `{"items": [{"id": "wf1", "name": "build", "status": "running"}]}`,
+			expectedErr:  "time expired while running the checker",
+			expectedType: "*circleci.timeoutExceededError",
+		},
+		"pipeline still pending": {
+			pipeline:     `{"id": "p5", "number": 5, "state": "pending"}`,
+			expectedErr:  "time expired while running the checker",
+			expectedType: "*circleci.timeoutExceededError",
+		},
+		"pipeline errored": {
+			pipeline:     `{"id": "p5", "number": 5, "state": "errored", "errors": [{"type": "config", "message": "config is invalid"}]}`,
+			expectedErr:  "pipeline 5 of project test1 matching [Branch: \"master\", Revision: \"\", Tag: \"\"] errored: config is invalid",
+			expectedType: "*circleci.PipelineErroredError",
+		},
+		"workflows request failed": {
+			pipeline:     `{"id": "p5", "number": 5, "state": "created"}`,
+			expectedErr:  "failed to get the workflows of pipeline 5 of project test1 -> test error",
+			expectedType: "*errors.errorString",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch path {
+				case "/api/v2/project/gh/org/test1/pipeline":
+					return json.Unmarshal([]byte(`{"id": "p5", "number": 5, "state": "pending"}`), output)
+				case "/api/v2/project/gh/org/test1/pipeline/5":
+					return json.Unmarshal([]byte(tc.pipeline), output)
+				case "/api/v2/pipeline/p5/workflow":
+					if len(tc.workflows) == 0 {
+						return fmt.Errorf("test error")
+					}
+					return json.Unmarshal([]byte(tc.workflows), output)
+				case "me":
+					return json.Unmarshal([]byte(`{"login": "org"}`), output)
+				case "project/github/org/test1":
+					return json.Unmarshal([]byte(`[]`), output)
+				}
+				t.Fatalf("unexpected request: %s %s", method, path)
+				return nil
+			})
+			input := &BuildProjectInput{Branch: "master", Parameters: map[string]interface{}{"deploy": true}}
+			_, err := client.BuildProject(project, os.Stdout, input, time.Second)
+			assert.Error(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedType, fmt.Sprintf("%T", err))
+		})
+	}
+}
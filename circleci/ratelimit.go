@@ -0,0 +1,96 @@
+package circleci
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rate limit headers returned by CircleCI
+const (
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+)
+
+// rateLimitThreshold ... the number of remaining requests below which
+// requests are throttled until the rate limit resets
+const rateLimitThreshold = 10
+
+// minResetEpoch ... reset values at least this large are Unix timestamps,
+// smaller values are the number of seconds until the rate limit resets
+const minResetEpoch = 1000000000
+
+// rateLimiter ... used internally to throttle requests when the rate limit
+// headers returned by CircleCI show few requests remain, the zero value does
+// not throttle until a response containing rate limit headers is observed
+type rateLimiter struct {
+	mu        sync.Mutex
+	remaining int
+	reset     time.Time
+	//replaced in tests, defaults to sleepContext
+	sleep func(context.Context, time.Duration) error
+}
+
+// delay ... returns the duration to wait before the next request, the
+// remaining requests are spread evenly until the rate limit resets
+func (l *rateLimiter) delay(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.reset.IsZero() || !now.Before(l.reset) || l.remaining >= rateLimitThreshold {
+		return 0
+	}
+	untilReset := l.reset.Sub(now)
+	if l.remaining <= 0 {
+		return untilReset
+	}
+	// claim one of the remaining requests, so that concurrent
+	// requests do not all wait the same duration
+	d := untilReset / time.Duration(l.remaining+1)
+	l.remaining--
+	return d
+}
+
+// wait ... blocks until the next request may be sent, returns
+// the error of ctx if ctx is done first
+func (l *rateLimiter) wait(ctx context.Context) error {
+	d := l.delay(time.Now())
+	if d <= 0 {
+		return nil
+	}
+	sleep := l.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+	return sleep(ctx, d)
+}
+
+// observe ... records the rate limit headers of a response, a response with
+// status 429 Too Many Requests blocks requests until the Retry-After has passed
+func (l *rateLimiter) observe(now time.Time, statusCode int, header http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if statusCode == http.StatusTooManyRequests {
+		l.remaining = 0
+		if secs, err := strconv.Atoi(header.Get(headerRetryAfter)); err == nil {
+			l.reset = now.Add(time.Duration(secs) * time.Second)
+			return
+		}
+	}
+	remaining, err := strconv.Atoi(header.Get(headerRateLimitRemaining))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get(headerRateLimitReset), 10, 64)
+	if err != nil {
+		return
+	}
+	l.remaining = remaining
+	if reset >= minResetEpoch {
+		l.reset = time.Unix(reset, 0)
+	} else {
+		l.reset = now.Add(time.Duration(reset) * time.Second)
+	}
+}
@@ -0,0 +1,108 @@
+package circleci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestRateLimiterDelay(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := map[string]struct {
+		status    int
+		header    map[string]string
+		expected  time.Duration
+		remaining int
+	}{
+		"no headers": {status: http.StatusOK, header: map[string]string{}},
+		"plenty remaining": {
+			status:    http.StatusOK,
+			header:    map[string]string{headerRateLimitRemaining: "100", headerRateLimitReset: "60"},
+			remaining: 100,
+		},
+		"few remaining": {
+			status:    http.StatusOK,
+			header:    map[string]string{headerRateLimitRemaining: "5", headerRateLimitReset: "60"},
+			expected:  10 * time.Second,
+			remaining: 4,
+		},
+		"none remaining": {
+			status:   http.StatusOK,
+			header:   map[string]string{headerRateLimitRemaining: "0", headerRateLimitReset: "60"},
+			expected: time.Minute,
+		},
+		"reset timestamp": {
+			status:   http.StatusOK,
+			header:   map[string]string{headerRateLimitRemaining: "0", headerRateLimitReset: strconv.FormatInt(now.Add(30*time.Second).Unix(), 10)},
+			expected: 30 * time.Second,
+		},
+		"too many requests": {
+			status:   http.StatusTooManyRequests,
+			header:   map[string]string{headerRetryAfter: "15"},
+			expected: 15 * time.Second,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tc.header {
+				header.Set(k, v)
+			}
+			var l rateLimiter
+			l.observe(now, tc.status, header)
+			assert.Equal(t, tc.expected, l.delay(now))
+			assert.Equal(t, tc.remaining, l.remaining)
+			// the rate limit has reset
+			assert.Equal(t, time.Duration(0), l.delay(now.Add(time.Hour)))
+		})
+	}
+}
+
+func TestRequestRateLimit(t *testing.T) {
+	remaining := rateLimitThreshold + 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining--
+		w.Header().Set(headerRateLimitRemaining, strconv.Itoa(remaining))
+		w.Header().Set(headerRateLimitReset, "60")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	c := NewClient(nil, "")
+	c.baseURL = u
+	var slept []time.Duration
+	c.shared().limiter.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	for i := 0; i < 4; i++ {
+		var output User
+		err = request(c, "GET", "me", nil, nil, &output)
+		assert.NilError(t, err)
+	}
+	// the first two requests are sent before fewer than the threshold remain
+	assert.Equal(t, 2, len(slept))
+	for _, d := range slept {
+		assert.Assert(t, d > 0 && d <= 7*time.Second, "unexpected delay: %s", d)
+	}
+	assert.Assert(t, slept[1] >= slept[0], "expected delays to grow as fewer requests remain: %v", slept)
+}
+
+func TestRateLimiterWaitContext(t *testing.T) {
+	l := &rateLimiter{}
+	l.observe(time.Now(), http.StatusTooManyRequests, http.Header{headerRetryAfter: []string{"3600"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := l.wait(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Assert(t, time.Since(start) < time.Second, "expected wait to stop once the context is done, waited %s", time.Since(start))
+}
@@ -0,0 +1,169 @@
+package circleci
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// RunBuildInput ... contains the data necessary to trigger a build
+// of a project and wait for it to complete using RunBuild
+type RunBuildInput struct {
+	//project to build, it must already be followed
+	Project *Project
+	//branch, tag or revision to build
+	Build *BuildProjectInput
+	//duration a build job can take before timing out
+	JobTimeout time.Duration
+	//failed jobs and workflows do not return an error
+	ContinueOnFail bool
+	//if set, progress is logged to Logger, otherwise nothing is logged
+	Logger io.Writer
+}
+
+// BuildResult ... contains the outcome of a build triggered by RunBuild
+type BuildResult struct {
+	BuildNum   int
+	WorkflowID string
+	//success, failed, canceled or timedout
+	Status   string
+	Duration time.Duration
+	URL      string
+	//nil if the test results could not be requested
+	Tests *TestSummary
+}
+
+// Build result statuses returned by RunBuild
+const (
+	ResultSuccess  = "success"
+	ResultFailed   = "failed"
+	ResultCanceled = "canceled"
+	ResultTimedOut = "timedout"
+)
+
+// TestSummary ... contains the number of tests by result
+// across all jobs of the workflow of a build
+type TestSummary struct {
+	Total   int
+	Passed  int
+	Failed  int
+	Skipped int
+}
+
+// TestResult ... represents a test object returned by calling
+// /project/:vcs-type/:username/:project/:build_num/tests on the CircleCI API v1.1
+// https://circleci.com/docs/api/v1-reference/#test-metadata
+type TestResult struct {
+	Classname string `json:"classname"`
+	File      string `json:"file"`
+	Name      string `json:"name"`
+	// success, failure, skipped
+	Result  string  `json:"result"`
+	RunTime float64 `json:"run_time"`
+	Message string  `json:"message"`
+	Source  string  `json:"source"`
+}
+
+// testsResponse ... used internally to represent the object returned
+// by calling /project/:vcs-type/:username/:project/:build_num/tests
+type testsResponse struct {
+	Tests []*TestResult `json:"tests"`
+}
+
+// BuildTests ... returns the test results collected by the build matching buildNum,
+// a *ProjectNotFollowedError is returned if CircleCI cannot find the build
+// https://circleci.com/docs/api/v1-reference/#test-metadata
+func (c *Client) BuildTests(project *Project, logger io.Writer, buildNum int) ([]*TestResult, error) {
+	var resp testsResponse
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%s/%d/tests", project.v1Path(), buildNum)
+		err := c.requester(c, "GET", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "BuildTests failed, GET /%s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, notFollowed(project, err)
+	}
+	return resp.Tests, nil
+}
+
+// RunBuild ... triggers a build of the project and waits for it to complete,
+// returning the outcome of the build as a *BuildResult, nothing is logged unless
+// input.Logger is set, if the build was triggered the *BuildResult is returned
+// even when an error is returned
+func (c *Client) RunBuild(input *RunBuildInput) (*BuildResult, error) {
+	logger := input.Logger
+	if logger == nil {
+		logger = ioutil.Discard
+	}
+	buildInput := input.Build
+	if buildInput == nil {
+		buildInput = &BuildProjectInput{}
+	}
+	start := time.Now()
+	summary, err := c.BuildProject(input.Project, logger, buildInput, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	result := &BuildResult{BuildNum: summary.BuildNum, URL: summary.BuildURL}
+	err = c.WaitForProjectBuild(input.Project, logger, buildInput, summary, input.JobTimeout, time.Minute, input.ContinueOnFail)
+	result.Duration = time.Since(start)
+	result.Status = resultStatus(err)
+
+	workflowID, werr := c.workflowID(input.Project, logger, summary)
+	if werr == nil {
+		result.WorkflowID = workflowID
+		result.Tests = c.testSummary(input.Project, logger, workflowID)
+	}
+	return result, err
+}
+
+// resultStatus ... used internally to return the BuildResult status
+// matching the error returned by WaitForProjectBuild
+func resultStatus(err error) string {
+	switch err.(type) {
+	case nil:
+		return ResultSuccess
+	case *BuildCanceledError:
+		return ResultCanceled
+	case *JobTimeoutError:
+		return ResultTimedOut
+	}
+	return ResultFailed
+}
+
+// testSummary ... used internally to count the test results of every
+// job within the workflow matching workflowID, returns nil if the
+// jobs or their test results could not be requested
+func (c *Client) testSummary(project *Project, logger io.Writer, workflowID string) *TestSummary {
+	jobs, err := c.WorkflowJobs(workflowID, logger)
+	if err != nil {
+		return nil
+	}
+	var summary TestSummary
+	for _, job := range jobs {
+		// approval jobs have no job number
+		if job.JobNumber == 0 {
+			continue
+		}
+		tests, err := c.BuildTests(project, logger, job.JobNumber)
+		if err != nil {
+			return nil
+		}
+		for _, t := range tests {
+			summary.Total++
+			switch t.Result {
+			case "success":
+				summary.Passed++
+			case "failure":
+				summary.Failed++
+			case "skipped":
+				summary.Skipped++
+			}
+		}
+	}
+	return &summary
+}
@@ -0,0 +1,48 @@
+package circleci
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestResultStatus(t *testing.T) {
+	tt := map[string]struct {
+		err      error
+		expected string
+	}{
+		"success":  {expected: ResultSuccess},
+		"failed":   {err: errors.New("test error"), expected: ResultFailed},
+		"canceled": {err: &BuildCanceledError{}, expected: ResultCanceled},
+		"timedout": {err: &JobTimeoutError{}, expected: ResultTimedOut},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, resultStatus(tc.err))
+		})
+	}
+}
+
+func TestTestSummary(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	responses := map[string]string{
+		"/api/v2/workflow/test/job":        `{"items": [{"job_number": 1}, {"type": "approval"}, {"job_number": 2}]}`,
+		"project/github/org/test1/1/tests": `{"tests": [{"result": "success"}, {"result": "failure"}]}`,
+		"project/github/org/test1/2/tests": `{"tests": [{"result": "success"}, {"result": "skipped"}, {"result": "success"}]}`,
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		return json.Unmarshal([]byte(responses[path]), output)
+	})
+	actual := client.testSummary(&project, os.Stdout, "test")
+	assert.DeepEqual(t, &TestSummary{Total: 5, Passed: 3, Failed: 1, Skipped: 1}, actual)
+}
@@ -0,0 +1,76 @@
+package circleci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VCS ... a version control system type supported by CircleCI, the value is the
+// name used by the CircleCI API v1.1, V2 returns the name used in project slugs
+type VCS string
+
+// Version control system types supported by CircleCI
+const (
+	VCSGitHub    VCS = "github"
+	VCSBitbucket VCS = "bitbucket"
+)
+
+// ParseVCS ... returns the VCS matching name, the long form used by the
+// CircleCI API v1.1 (github, bitbucket) and the short form used by the
+// CircleCI API v2 (gh, bb) are accepted, regardless of case
+func ParseVCS(name string) (VCS, error) {
+	switch strings.ToLower(name) {
+	case "github", "gh":
+		return VCSGitHub, nil
+	case "bitbucket", "bb":
+		return VCSBitbucket, nil
+	}
+	return "", fmt.Errorf("unsupported version control system: %q, must be one of github (gh) or bitbucket (bb)", name)
+}
+
+// V1 ... returns the name of the version control system used in
+// paths of the CircleCI API v1.1
+func (v VCS) V1() string {
+	return string(v)
+}
+
+// V2 ... returns the short name of the version control system used
+// in project slugs of the CircleCI API v2
+func (v VCS) V2() string {
+	switch v {
+	case VCSGitHub:
+		return "gh"
+	case VCSBitbucket:
+		return "bb"
+	}
+	return string(v)
+}
+
+// host ... used internally to return the host of repositories
+// of the version control system, used to synthesize their URLs
+func (v VCS) host() string {
+	switch v {
+	case VCSGitHub:
+		return "github.com"
+	case VCSBitbucket:
+		return "bitbucket.org"
+	}
+	return ""
+}
+
+// VCS ... returns the version control system of the project, if the Vcs of
+// the project is not supported by CircleCI it is returned unchanged, so that
+// CircleCI reports the error
+func (p *Project) VCS() VCS {
+	vcs, err := ParseVCS(p.Vcs)
+	if err != nil {
+		return VCS(p.Vcs)
+	}
+	return vcs
+}
+
+// v1Path ... used internally to return the path of the project
+// in the CircleCI API v1.1, in the format project/vcs/org/repo
+func (p *Project) v1Path() string {
+	return fmt.Sprintf("project/%s/%s/%s", p.VCS().V1(), p.Username, p.Reponame)
+}
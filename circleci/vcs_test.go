@@ -0,0 +1,59 @@
+package circleci
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestParseVCS(t *testing.T) {
+	tt := map[string]struct {
+		name        string
+		expected    VCS
+		v1          string
+		v2          string
+		expectedErr string
+	}{
+		"github":    {name: "github", expected: VCSGitHub, v1: "github", v2: "gh"},
+		"gh":        {name: "gh", expected: VCSGitHub, v1: "github", v2: "gh"},
+		"GitHub":    {name: "GitHub", expected: VCSGitHub, v1: "github", v2: "gh"},
+		"bitbucket": {name: "bitbucket", expected: VCSBitbucket, v1: "bitbucket", v2: "bb"},
+		"bb":        {name: "bb", expected: VCSBitbucket, v1: "bitbucket", v2: "bb"},
+		"gitlab": {
+			name:        "gitlab",
+			expectedErr: `unsupported version control system: "gitlab", must be one of github (gh) or bitbucket (bb)`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseVCS(tc.name)
+			if tc.expectedErr != "" {
+				assert.Error(t, err, tc.expectedErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.v1, actual.V1())
+			assert.Equal(t, tc.v2, actual.V2())
+		})
+	}
+}
+
+func TestProjectV1Path(t *testing.T) {
+	tt := map[string]struct {
+		project  Project
+		expected string
+	}{
+		"github":      {project: Project{Vcs: "github", Username: "org", Reponame: "test1"}, expected: "project/github/org/test1"},
+		"short":       {project: Project{Vcs: "gh", Username: "org", Reponame: "test1"}, expected: "project/github/org/test1"},
+		"bitbucket":   {project: Project{Vcs: "bb", Username: "org", Reponame: "test1"}, expected: "project/bitbucket/org/test1"},
+		"unsupported": {project: Project{Vcs: "gitlab", Username: "org", Reponame: "test1"}, expected: "project/gitlab/org/test1"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.project.v1Path())
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package circleci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// APIVersion ... determines which version of the CircleCI API is used by the
+// client methods implemented using both versions, such as BuildSummary and
+// GetBuild, methods available in only one version always use that version
+type APIVersion int
+
+const (
+	// APIv1 ... uses the CircleCI API v1.1, the default
+	APIv1 APIVersion = iota
+	// APIv2 ... uses the CircleCI API v2, build summaries are mapped from the
+	// jobs of each pipeline and only contain the properties available from v2
+	APIv2
+)
+
+// String ... returns the name of the APIVersion
+func (v APIVersion) String() string {
+	switch v {
+	case APIv1:
+		return "v1.1"
+	case APIv2:
+		return "v2"
+	}
+	return fmt.Sprintf("APIVersion(%d)", int(v))
+}
+
+// ParseAPIVersion ... returns the APIVersion matching name, either v1.1 or v2
+func ParseAPIVersion(name string) (APIVersion, error) {
+	for _, v := range []APIVersion{APIv1, APIv2} {
+		if v.String() == name {
+			return v, nil
+		}
+	}
+	return APIv1, fmt.Errorf("unknown api version: %q, must be one of v1.1 or v2", name)
+}
+
+// errStopPaging ... returned by the collect func of getAllPagesV2
+// to stop requesting pages once enough items were collected
+var errStopPaging = errors.New("stop paging")
+
+// defaultBuildSummaryLimit ... the number of build summaries returned
+// by BuildSummary when no limit is provided, matching the CircleCI API v1.1
+const defaultBuildSummaryLimit = 30
+
+// buildSummaryV2 ... used internally to implement BuildSummary using the CircleCI
+// API v2, the jobs of the most recent pipelines of the project are mapped to build
+// summaries, approval jobs are excluded and jobs that have not started
+// have a BuildNum of zero
+func (c *Client) buildSummaryV2(project *Project, logger io.Writer, input *BuildSummaryInput) ([]*BuildSummaryOutput, error) {
+	limit := defaultBuildSummaryLimit
+	var (
+		offset int
+		branch string
+	)
+	if input != nil {
+		if input.Limit > 0 {
+			limit = input.Limit
+		}
+		offset, branch = input.Offset, input.Branch
+	}
+	var summaries []*BuildSummaryOutput
+	err := c.walkBuildSummariesV2(project, logger, branch, func(s *BuildSummaryOutput) error {
+		if input != nil && !matchFilter(s, input.Filter) {
+			return nil
+		}
+		if offset > 0 {
+			offset--
+			return nil
+		}
+		summaries = append(summaries, s)
+		if len(summaries) == limit {
+			return errStopPaging
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// walkBuildSummariesV2 ... used internally to call visit with the build summary
+// of every job of the pipelines of the project on branch, newest first, each page
+// of pipelines is requested once, visit may return errStopPaging to stop walking
+func (c *Client) walkBuildSummariesV2(project *Project, logger io.Writer, branch string, visit func(*BuildSummaryOutput) error) error {
+	params := url.Values{}
+	if len(branch) > 0 {
+		params.Set("branch", branch)
+	}
+	path := fmt.Sprintf("%sproject/%s/pipeline", apiV2Path, project.Slug())
+	err := c.getAllPagesV2(path, logger, params, func(items json.RawMessage) error {
+		var page []*Pipeline
+		err := json.Unmarshal(items, &page)
+		if err != nil {
+			return err
+		}
+		for _, p := range page {
+			builds, err := c.pipelineBuilds(project, logger, p)
+			if err != nil {
+				return err
+			}
+			for _, s := range builds {
+				err = visit(s)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logf(logger, "BuildSummary failed, GET %s -> %v", path, err)
+		return notFollowed(project, err)
+	}
+	return nil
+}
+
+// matchFilter ... used internally to apply the Filter of a BuildSummaryInput to a
+// build summary mapped from the CircleCI API v2, as CircleCI does for the v1.1
+func matchFilter(s *BuildSummaryOutput, filter string) bool {
+	switch filter {
+	case "completed":
+		return s.Lifecycle == lifecycleFinished
+	case "successful":
+		return s.Outcome == "success"
+	case "failed":
+		return s.Lifecycle == lifecycleFinished && s.Outcome != "success"
+	case "running":
+		return s.Lifecycle == lifecycleRunning
+	}
+	return true
+}
+
+// getBuildV2 ... used internally to implement GetBuild using the CircleCI API v2,
+// the build is mapped from the job matching buildNum and has no steps
+func (c *Client) getBuildV2(project *Project, logger io.Writer, buildNum int) (*Build, error) {
+	job, err := c.GetJob(project, logger, buildNum)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle, outcome := jobLifecycle(job.Status)
+	failed := lifecycle == lifecycleFinished && outcome != "success"
+	build := &Build{
+		BuildNum:  job.Number,
+		Username:  project.Username,
+		Reponame:  project.Reponame,
+		Vcs:       project.VCS().V1(),
+		Lifecycle: lifecycle,
+		Outcome:   outcome,
+		Status:    job.Status,
+		Failed:    &failed,
+		QueuedAt:  job.QueuedAt,
+		StoppedAt: job.StoppedAt,
+	}
+	if job.Executor != nil {
+		build.Picard = &BuildPicard{
+			Executor:      job.Executor.Type,
+			ResourceClass: &BuildResourceClass{Class: job.Executor.ResourceClass},
+		}
+	}
+	if job.LatestWorkflow != nil {
+		build.Workflow = &BuildWorkflow{
+			JobName:      job.Name,
+			WorkflowName: job.LatestWorkflow.Name,
+			WorkflowID:   job.LatestWorkflow.ID,
+		}
+	}
+	return build, nil
+}
+
+// getBuildSummaryV2 ... used internally to implement GetBuildSummary using
+// the CircleCI API v2, the summary is mapped from the job matching buildNum
+func (c *Client) getBuildSummaryV2(project *Project, logger io.Writer, buildNum int) (*BuildSummaryOutput, error) {
+	job, err := c.GetJob(project, logger, buildNum)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle, outcome := jobLifecycle(job.Status)
+	summary := &BuildSummaryOutput{
+		BuildNum:  job.Number,
+		Username:  project.Username,
+		Reponame:  project.Reponame,
+		Vcs:       project.VCS().V1(),
+		Lifecycle: lifecycle,
+		Outcome:   outcome,
+		Status:    job.Status,
+		QueuedAt:  job.QueuedAt,
+		StartTime: job.StartedAt,
+		StoppedAt: job.StoppedAt,
+		BuildURL:  job.WebURL,
+	}
+	if job.LatestWorkflow != nil {
+		summary.Workflow = &BuildWorkflow{
+			JobName:      job.Name,
+			WorkflowName: job.LatestWorkflow.Name,
+			WorkflowID:   job.LatestWorkflow.ID,
+		}
+	}
+	return summary, nil
+}
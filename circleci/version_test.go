@@ -0,0 +1,211 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestParseAPIVersion(t *testing.T) {
+	for _, v := range []APIVersion{APIv1, APIv2} {
+		actual, err := ParseAPIVersion(v.String())
+		assert.NilError(t, err)
+		assert.Equal(t, v, actual)
+	}
+	_, err := ParseAPIVersion("v3")
+	assert.Error(t, err, `unknown api version: "v3", must be one of v1.1 or v2`)
+}
+
+// apiV2Requester ... returns a requestFunc serving a project with two pipelines
+// on the master branch, the second page of pipelines fails the test if requested
+func apiV2Requester(t *testing.T) requestFunc {
+	return func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		switch path {
+		case "/api/v2/project/gh/org/test1/pipeline":
+			assert.Equal(t, "master", params.Get("branch"))
+			if len(params.Get("page-token")) > 0 {
+				t.Fatalf("unexpected request of the next page of pipelines")
+			}
+			return json.Unmarshal([]byte(`{"items": [
+				{"id": "p2", "number": 2, "vcs": {"branch": "master", "revision": "bbb"}, "trigger": {"type": "api", "actor": {"login": "org"}}},
+				{"id": "p1", "number": 1, "vcs": {"branch": "master", "revision": "aaa"}, "trigger": {"type": "api", "actor": {"login": "org"}}}
+			], "next_page_token": "next"}`), output)
+		case "/api/v2/pipeline/p2/workflow":
+			return json.Unmarshal([]byte(`{"items": [{"id": "wf2", "name": "build"}]}`), output)
+		case "/api/v2/pipeline/p1/workflow":
+			return json.Unmarshal([]byte(`{"items": [{"id": "wf1", "name": "build"}]}`), output)
+		case "/api/v2/workflow/wf2/job":
+			return json.Unmarshal([]byte(`{"items": [{"id": "j3", "name": "test", "job_number": 12, "type": "build", "status": "running"}]}`), output)
+		case "/api/v2/workflow/wf1/job":
+			return json.Unmarshal([]byte(`{"items": [{"id": "j2", "name": "deploy", "job_number": 11, "type": "build", "status": "failed"},
+				{"id": "j1", "name": "test", "job_number": 10, "type": "build", "status": "success"}]}`), output)
+		case "/api/v2/project/gh/org/test1/job/11":
+			return json.Unmarshal([]byte(`{"number": 11, "name": "deploy", "status": "failed", "web_url": "https://circleci.com/gh/org/test1/11",
+				"executor": {"type": "macos", "resource_class": "macos.x86.medium.gen2"}, "latest_workflow": {"id": "wf1", "name": "build"}}`), output)
+		}
+		t.Fatalf("unexpected request: %s %s", method, path)
+		return nil
+	}
+}
+
+func TestBuildSummaryV2(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		input    *BuildSummaryInput
+		expected []int
+	}{
+		"all":        {input: &BuildSummaryInput{Branch: "master"}, expected: []int{12, 11, 10}},
+		"limit":      {input: &BuildSummaryInput{Branch: "master"}, expected: []int{12, 11}},
+		"offset":     {input: &BuildSummaryInput{Branch: "master", Offset: 1}, expected: []int{11}},
+		"completed":  {input: &BuildSummaryInput{Branch: "master", Filter: "completed"}, expected: []int{11, 10}},
+		"successful": {input: &BuildSummaryInput{Branch: "master", Filter: "successful"}, expected: []int{10}},
+		"failed":     {input: &BuildSummaryInput{Branch: "master", Filter: "failed"}, expected: []int{11}},
+		"running":    {input: &BuildSummaryInput{Branch: "master", Filter: "running"}, expected: []int{12}},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, apiV2Requester(t))
+			client.APIVersion = APIv2
+			// the limit stops paging before the second page of pipelines is requested
+			tc.input.Limit = len(tc.expected)
+			summaries, err := client.BuildSummary(project, os.Stdout, tc.input)
+			assert.NilError(t, err)
+			var actual []int
+			for _, s := range summaries {
+				actual = append(actual, s.BuildNum)
+				assert.Equal(t, "master", s.Branch)
+				assert.Equal(t, "org", s.User.Username)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFindBuildSummariesV2(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	// two pages of 60 pipelines, each with a single job, more than
+	// the 100 build summaries requested at a time using the v1.1
+	pipelines := func(from int, to int) []*Pipeline {
+		var page []*Pipeline
+		for n := from; n < to; n++ {
+			page = append(page, &Pipeline{
+				ID:      fmt.Sprintf("p%d", n),
+				Number:  n,
+				Vcs:     &PipelineVcs{Branch: "master", Revision: "aaa"},
+				Trigger: &PipelineTrigger{Type: "api", Actor: &User{Username: "org"}},
+			})
+		}
+		return page
+	}
+	requests := make(map[string]int)
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		requests[path+"?"+params.Get("page-token")]++
+		var id int
+		switch {
+		case path == "me":
+			return json.Unmarshal([]byte(`{"login": "org"}`), output)
+		case path == "/api/v2/project/gh/org/test1/pipeline":
+			page := output.(*pageV2)
+			items := pipelines(0, 60)
+			page.NextPageToken = "next"
+			if params.Get("page-token") == "next" {
+				items, page.NextPageToken = pipelines(60, 120), ""
+			}
+			return marshalItems(page, items)
+		case scan(path, "/api/v2/pipeline/p%d/workflow", &id):
+			return marshalItems(output.(*pageV2), []*Workflow{{ID: fmt.Sprintf("wf%d", id), Name: "build"}})
+		case scan(path, "/api/v2/workflow/wf%d/job", &id):
+			return marshalItems(output.(*pageV2), []*WorkflowJob{{Name: "test", JobNumber: id + 1, Type: "build", Status: "success"}})
+		}
+		t.Fatalf("unexpected request: %s %s", method, path)
+		return nil
+	})
+	client.APIVersion = APIv2
+	summaries, err := client.FindBuildSummaries(project, os.Stdout, &BuildProjectInput{Branch: "master", Revision: "aaa"})
+	assert.NilError(t, err)
+	assert.Equal(t, 120, len(summaries))
+	// each page of pipelines and the workflows and jobs of each pipeline are requested once
+	assert.Equal(t, 1+2+120*2, len(requests))
+	for path, count := range requests {
+		assert.Equal(t, 1, count, "%s requested %d times", path, count)
+	}
+}
+
+// marshalItems ... sets the items of page to the JSON encoding of items
+func marshalItems(page *pageV2, items interface{}) error {
+	b, err := json.Marshal(items)
+	page.Items = b
+	return err
+}
+
+// scan ... returns true if path matches format, storing the number in id
+func scan(path string, format string, id *int) bool {
+	n, err := fmt.Sscanf(path, format, id)
+	return err == nil && n == 1 && fmt.Sprintf(format, *id) == path
+}
+
+func TestGetBuildV2(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	client := newTestClient(t, apiV2Requester(t))
+	client.APIVersion = APIv2
+
+	build, err := client.GetBuild(project, os.Stdout, 11)
+	assert.NilError(t, err)
+	assert.Equal(t, 11, build.BuildNum)
+	assert.Equal(t, lifecycleFinished, build.Lifecycle)
+	assert.Equal(t, "failed", build.Outcome)
+	assert.Equal(t, true, *build.Failed)
+	assert.Equal(t, "wf1", build.Workflow.WorkflowID)
+	assert.Equal(t, 0, len(build.Steps))
+	assert.Equal(t, "macos", build.Executor())
+	assert.Equal(t, "macos.x86.medium.gen2", build.ResourceClass())
+
+	summary, err := client.GetBuildSummary(project, os.Stdout, 11)
+	assert.NilError(t, err)
+	assert.Equal(t, 11, summary.BuildNum)
+	assert.Equal(t, "failed", summary.Outcome)
+	assert.Equal(t, "https://circleci.com/gh/org/test1/11", summary.BuildURL)
+	assert.Equal(t, "deploy", summary.Workflow.JobName)
+}
+
+func TestBuildProjectV2(t *testing.T) {
+	project := &Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	created := time.Now().UTC().Format(time.RFC3339Nano)
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		switch path {
+		case "project/github/org/test1/build":
+			return json.Unmarshal([]byte(`{"status": 200}`), output)
+		case "me":
+			return json.Unmarshal([]byte(`{"login": "org"}`), output)
+		case "/api/v2/project/gh/org/test1/pipeline":
+			assert.Equal(t, "master", params.Get("branch"))
+			if len(params.Get("page-token")) > 0 {
+				t.Fatalf("unexpected request of the next page of pipelines")
+			}
+			return json.Unmarshal([]byte(`{"items": [
+					{"id": "p2", "number": 2, "created_at": "`+created+`", "vcs": {"branch": "master"}, "trigger": {"type": "api", "actor": {"login": "org"}}},
+					{"id": "p1", "number": 1, "created_at": "2020-01-01T00:00:00Z", "vcs": {"branch": "master"}, "trigger": {"type": "api", "actor": {"login": "org"}}}
+				], "next_page_token": "next"}`), output)
+		case "/api/v2/pipeline/p2/workflow":
+			return json.Unmarshal([]byte(`{"items": [{"id": "wf2", "name": "build", "created_at": "`+created+`"}]}`), output)
+		case "/api/v2/workflow/wf2/job":
+			return json.Unmarshal([]byte(`{"items": [{"id": "j4", "name": "deploy", "type": "build", "status": "blocked"},
+					{"id": "j3", "name": "test", "job_number": 12, "type": "build", "status": "queued"}]}`), output)
+		}
+		t.Fatalf("unexpected request: %s %s", method, path)
+		return nil
+	})
+	client.APIVersion = APIv2
+
+	summary, err := client.BuildProject(project, os.Stdout, &BuildProjectInput{Branch: "master"}, 10*time.Second)
+	assert.NilError(t, err)
+	assert.Equal(t, 12, summary.BuildNum)
+	assert.Equal(t, "wf2", summary.Workflow.WorkflowID)
+	assert.Assert(t, summary.QueuedAt != nil)
+}
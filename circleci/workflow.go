@@ -0,0 +1,631 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const workflowStatusOnHold = "on_hold"
+
+// WaitStrategy ... determines how WaitForProjectBuild waits for a build to complete
+type WaitStrategy int
+
+const (
+	// BuildChain ... follows each build of the workflow to the next build
+	// using the build summaries of the CircleCI API v1.1, this is the default
+	BuildChain WaitStrategy = iota
+	// WorkflowStatus ... polls the status of the workflow using the CircleCI
+	// API v2, which handles workflows that fan-out and fan-in more reliably
+	WorkflowStatus
+)
+
+// String ... returns the name of the WaitStrategy
+func (s WaitStrategy) String() string {
+	switch s {
+	case BuildChain:
+		return "buildchain"
+	case WorkflowStatus:
+		return "workflow"
+	}
+	return fmt.Sprintf("WaitStrategy(%d)", int(s))
+}
+
+// ParseWaitStrategy ... returns the WaitStrategy matching name, either buildchain or workflow
+func ParseWaitStrategy(name string) (WaitStrategy, error) {
+	for _, s := range []WaitStrategy{BuildChain, WorkflowStatus} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return BuildChain, fmt.Errorf("unknown wait strategy: %q, must be one of buildchain or workflow", name)
+}
+
+// Workflow ... represents the object returned by calling
+// /workflow/:id on the CircleCI API v2
+// https://circleci.com/docs/api/v2/#get-a-workflow
+type Workflow struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	PipelineID     string `json:"pipeline_id"`
+	PipelineNumber int    `json:"pipeline_number"`
+	ProjectSlug    string `json:"project_slug"`
+	// success, running, not_run, failed, error, failing, on_hold, canceled, unauthorized
+	Status    string     `json:"status"`
+	CreatedAt *time.Time `json:"created_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+}
+
+// Finished ... returns true if the workflow has reached a terminal status
+func (w *Workflow) Finished() bool {
+	switch w.Status {
+	case "success", "not_run", "failed", "error", "canceled", "unauthorized":
+		return true
+	}
+	return false
+}
+
+// GetWorkflow ... returns a *Workflow for the given workflowID, or an
+// error if the request to CircleCI failed
+// https://circleci.com/docs/api/v2/#get-a-workflow
+func (c *Client) GetWorkflow(workflowID string, logger io.Writer) (*Workflow, error) {
+	var workflow Workflow
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%sworkflow/%s", apiV2Path, workflowID)
+		err := c.requester(c, "GET", url, nil, nil, &workflow)
+		if err != nil {
+			logf(logger, "GetWorkflow failed, GET %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// WaitForWorkflow ... waits for the workflow matching the given workflowID
+// to reach a terminal status or be on hold, as WaitForPipeline does, does not
+// validate that the workflow was successful, timeout is the duration to wait
+// before giving up, a *JobTimeoutError is returned once it has passed
+func (c *Client) WaitForWorkflow(project *Project, logger io.Writer, workflowID string, timeout time.Duration) (*Workflow, error) {
+	var workflow *Workflow
+	err := waiter(c.context(), c.pollInterval(), time.Now().Add(timeout), func(count int) (bool, error) {
+		if count%10 == 0 {
+			infof(logger, "waiting for workflow %s [%s] to finish\n", project.Reponame, workflowID)
+		}
+		w, err := c.GetWorkflow(workflowID, logger)
+		if err != nil {
+			//should we return this error? logging for now
+			logf(logger, "failed to get workflow %s [%s] -> %v\n", project.Reponame, workflowID, err)
+			return false, nil
+		}
+		workflow = w
+		return w.Finished() || w.Status == workflowStatusOnHold, nil
+	})
+	if err != nil {
+		if _, ok := err.(*timeoutExceededError); ok {
+			return nil, &JobTimeoutError{Message: fmt.Sprintf("timeout exceeded while waiting for workflow %s [%s] to finish", project.Reponame, workflowID)}
+		}
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// waitForWorkflowStatus ... used internally to implement the WorkflowStatus WaitStrategy,
+// polls the workflow of the given build summary until it reaches a terminal status,
+// the last workflow waited on is returned with any error, so that its status is
+// known even when continueOnFail hides the failure, it is nil if unavailable
+func (c *Client) waitForWorkflowStatus(
+	project *Project,
+	logger io.Writer,
+	input *BuildProjectInput,
+	summary *BuildSummaryOutput,
+	jobTimeout time.Duration,
+	continueOnFail bool) (*Workflow, error) {
+	workflowID, err := c.workflowID(project, logger, summary)
+	if err != nil {
+		return nil, err
+	}
+	var workflow *Workflow
+	for {
+		err = waiter(c.context(), c.pollInterval(), time.Now().Add(jobTimeout), func(count int) (bool, error) {
+			if count%10 == 0 {
+				infof(logger, "waiting for workflow %s [%s] to finish\n", project.Reponame, workflowID)
+			}
+			w, err := c.GetWorkflow(workflowID, logger)
+			if err != nil {
+				//should we return this error? logging for now
+				logf(logger, "failed to get workflow %s [%s] -> %v\n", project.Reponame, workflowID, err)
+				return false, nil
+			}
+			workflow = w
+			return w.Finished() || w.Status == workflowStatusOnHold, nil
+		})
+		if err != nil {
+			if _, ok := err.(*timeoutExceededError); ok {
+				return nil, &JobTimeoutError{Message: fmt.Sprintf("timeout exceeded while waiting for workflow %s [%s] to finish", project.Reponame, workflowID)}
+			}
+			return nil, err
+		}
+		if workflow.Status != workflowStatusOnHold {
+			break
+		}
+		approved, err := c.waitForApproval(project, logger, workflowID, input.AutoApproveJobs)
+		if err != nil {
+			return workflow, err
+		}
+		if !approved {
+			// matches the BuildChain strategy, which stops
+			// waiting once no more builds are started
+			infof(logger, "workflow %s [%s] is on hold, not waiting for approval\n", project.Reponame, workflow.Name)
+			return workflow, nil
+		}
+	}
+	if workflow.Status != statusCanceled && !c.workflowSucceeded(workflow) && input.RerunFailedJobs > 0 {
+		rerun, err := c.rerunFailedJobs(project, logger, input, workflow, jobTimeout)
+		if err != nil {
+			return workflow, err
+		}
+		workflow = rerun
+	}
+	if workflow.Status == statusCanceled || !c.workflowSucceeded(workflow) {
+		return workflow, c.workflowResult(project, logger, workflow, continueOnFail)
+	}
+	return workflow, c.waitForGeneratedWorkflows(project, logger, workflow, jobTimeout, continueOnFail)
+}
+
+// workflowSucceeded ... used internally to return true if the finished workflow
+// succeeded, using the WorkflowSucceeded predicate of the client if it is set
+func (c *Client) workflowSucceeded(workflow *Workflow) bool {
+	if c.WorkflowSucceeded != nil {
+		return c.WorkflowSucceeded(workflow)
+	}
+	return workflow.Status == "success"
+}
+
+// workflowID ... used internally to return the ID of the workflow of the given
+// build summary, the build is requested if the summary has no workflow details
+func (c *Client) workflowID(project *Project, logger io.Writer, summary *BuildSummaryOutput) (string, error) {
+	if summary.Workflow != nil && len(summary.Workflow.WorkflowID) > 0 {
+		return summary.Workflow.WorkflowID, nil
+	}
+	build, err := c.GetBuild(project, logger, summary.BuildNum)
+	if err != nil {
+		return "", err
+	}
+	if build.Workflow == nil {
+		return "", fmt.Errorf("could not obtain workflow details from build %d", summary.BuildNum)
+	}
+	return build.Workflow.WorkflowID, nil
+}
+
+// WorkflowJob ... represents a job object returned by calling
+// /workflow/:id/job on the CircleCI API v2
+// https://circleci.com/docs/api/v2/#get-a-workflow-39-s-jobs
+type WorkflowJob struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	JobNumber         int      `json:"job_number"`
+	ProjectSlug       string   `json:"project_slug"`
+	ApprovalRequestID string   `json:"approval_request_id"`
+	Dependencies      []string `json:"dependencies"`
+	// build or approval
+	Type string `json:"type"`
+	// success, running, not_run, failed, retried, queued, not_running, infrastructure_fail,
+	// timedout, on_hold, terminated-unknown, blocked, canceled, unauthorized
+	Status    string     `json:"status"`
+	CreatedAt *time.Time `json:"created_at"`
+	QueuedAt  *time.Time `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+}
+
+// JobDetail ... represents a job object returned by calling
+// /project/:project-slug/job/:job-number on the CircleCI API v2
+// https://circleci.com/docs/api/v2/#get-job-details
+type JobDetail struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+	WebURL string `json:"web_url"`
+	// success, running, not_run, failed, retried, queued, not_running, infrastructure_fail,
+	// timedout, on_hold, terminated-unknown, blocked, canceled, unauthorized
+	Status    string     `json:"status"`
+	CreatedAt *time.Time `json:"created_at"`
+	QueuedAt  *time.Time `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+	//duration of the job in milliseconds
+	Duration       int64              `json:"duration"`
+	Parallelism    int                `json:"parallelism"`
+	Executor       *JobExecutor       `json:"executor"`
+	Contexts       []*JobContext      `json:"contexts"`
+	LatestWorkflow *JobLatestWorkflow `json:"latest_workflow"`
+}
+
+// JobExecutor ... represents the executor property of a JobDetail
+type JobExecutor struct {
+	// docker, machine, macos or windows
+	Type          string `json:"type"`
+	ResourceClass string `json:"resource_class"`
+}
+
+// JobContext ... represents a context used by a JobDetail
+type JobContext struct {
+	Name string `json:"name"`
+}
+
+// JobLatestWorkflow ... represents the latest_workflow property of a JobDetail
+type JobLatestWorkflow struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetJob ... returns the *JobDetail of the job matching jobNumber within the project,
+// a *ProjectNotFollowedError is returned if CircleCI cannot find the job
+// https://circleci.com/docs/api/v2/#get-job-details
+func (c *Client) GetJob(project *Project, logger io.Writer, jobNumber int) (*JobDetail, error) {
+	var job JobDetail
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%sproject/%s/job/%d", apiV2Path, project.Slug(), jobNumber)
+		err := c.requester(c, "GET", url, nil, nil, &job)
+		if err != nil {
+			logf(logger, "GetJob failed, GET %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, notFollowed(project, err)
+	}
+	return &job, nil
+}
+
+// WorkflowJobs ... returns all jobs within the workflow matching the given workflowID
+// https://circleci.com/docs/api/v2/#get-a-workflow-39-s-jobs
+func (c *Client) WorkflowJobs(workflowID string, logger io.Writer) ([]*WorkflowJob, error) {
+	var jobs []*WorkflowJob
+	url := fmt.Sprintf("%sworkflow/%s/job", apiV2Path, workflowID)
+	err := c.getAllPagesV2(url, logger, nil, func(items json.RawMessage) error {
+		var page []*WorkflowJob
+		err := json.Unmarshal(items, &page)
+		jobs = append(jobs, page...)
+		return err
+	})
+	if err != nil {
+		logf(logger, "WorkflowJobs failed, GET %s -> %v", url, err)
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// WorkflowJobNotFoundError ... a job was not found when calling GetWorkflowJobStatus
+type WorkflowJobNotFoundError struct {
+	Message string
+}
+
+func (e *WorkflowJobNotFoundError) Error() string {
+	return e.Message
+}
+
+// GetWorkflowJobStatus ... returns the job matching jobName within the workflow
+// matching the given workflowID, if the job name appears multiple times the most
+// recently started job is returned
+func (c *Client) GetWorkflowJobStatus(workflowID string, jobName string, logger io.Writer) (*WorkflowJob, error) {
+	jobs, err := c.WorkflowJobs(workflowID, logger)
+	if err != nil {
+		return nil, err
+	}
+	var job *WorkflowJob
+	for _, j := range jobs {
+		if j.Name != jobName {
+			continue
+		}
+		// prefer the most recently started job, jobs that have
+		// not started yet are only used if nothing else matched
+		if job == nil ||
+			(j.StartedAt != nil && (job.StartedAt == nil || j.StartedAt.After(*job.StartedAt))) {
+			job = j
+		}
+	}
+	if job == nil {
+		return nil, &WorkflowJobNotFoundError{Message: fmt.Sprintf("failed to locate job %q in workflow %s", jobName, workflowID)}
+	}
+	return job, nil
+}
+
+// ApproveJob ... approves the pending approval job matching approvalRequestID
+// within the workflow matching the given workflowID
+// https://circleci.com/docs/api/v2/#approve-a-job
+func (c *Client) ApproveJob(workflowID string, approvalRequestID string, logger io.Writer) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%sworkflow/%s/approve/%s", apiV2Path, workflowID, approvalRequestID)
+		err := c.requester(c, "POST", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "ApproveJob failed, POST %s -> %v", url, err)
+		}
+		return err
+	})
+}
+
+// CancelWorkflow ... cancels the workflow matching the given workflowID,
+// stopping all of its running jobs at once
+// https://circleci.com/docs/api/v2/#operation/cancelWorkflow
+func (c *Client) CancelWorkflow(workflowID string, logger io.Writer) error {
+	var resp messageResponse
+	return c.retry(logger, func() error {
+		url := fmt.Sprintf("%sworkflow/%s/cancel", apiV2Path, workflowID)
+		err := c.requester(c, "POST", url, nil, nil, &resp)
+		if err != nil {
+			logf(logger, "CancelWorkflow failed, POST %s -> %v", url, err)
+		}
+		return err
+	})
+}
+
+// rerunWorkflowInput ... used internally to represent the
+// request body when rerunning a workflow
+type rerunWorkflowInput struct {
+	FromFailed bool `json:"from_failed,omitempty"`
+}
+
+// rerunWorkflowOutput ... used internally to represent the
+// response body when rerunning a workflow
+type rerunWorkflowOutput struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+// RerunWorkflow ... reruns the workflow matching the given workflowID, only its
+// failed jobs are run again if fromFailed is true, returns the ID of the new workflow
+// https://circleci.com/docs/api/v2/#operation/rerunWorkflow
+func (c *Client) RerunWorkflow(workflowID string, logger io.Writer, fromFailed bool) (string, error) {
+	var output rerunWorkflowOutput
+	err := c.retry(logger, func() error {
+		url := fmt.Sprintf("%sworkflow/%s/rerun", apiV2Path, workflowID)
+		err := c.requester(c, "POST", url, nil, &rerunWorkflowInput{FromFailed: fromFailed}, &output)
+		if err != nil {
+			logf(logger, "RerunWorkflow failed, POST %s -> %v", url, err)
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return output.WorkflowID, nil
+}
+
+// rerunFailedJobs ... used internally to rerun the failed jobs of the given finished
+// workflow up to RerunFailedJobs times of the input, waiting for each rerun to finish,
+// returns the first rerun that succeeded or the last rerun
+func (c *Client) rerunFailedJobs(project *Project, logger io.Writer, input *BuildProjectInput, workflow *Workflow, jobTimeout time.Duration) (*Workflow, error) {
+	for attempt := 1; attempt <= input.RerunFailedJobs; attempt++ {
+		rerunID, err := c.RerunWorkflow(workflow.ID, logger, true)
+		if err != nil {
+			return nil, err
+		}
+		infof(logger, "workflow %s [%s] failed with status: %s, rerunning failed jobs as workflow %s (rerun %d of %d)\n",
+			project.Reponame, workflow.Name, workflow.Status, rerunID, attempt, input.RerunFailedJobs)
+		workflow, err = c.WaitForWorkflow(project, logger, rerunID, jobTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if workflow.Status == statusCanceled || workflow.Status == workflowStatusOnHold || c.workflowSucceeded(workflow) {
+			break
+		}
+	}
+	return workflow, nil
+}
+
+// rerunFailedBuild ... used internally by the BuildChain WaitStrategy when a build
+// fails and its failed jobs are rerun, waits for the workflow of the build to finish
+// before rerunning its failed jobs
+func (c *Client) rerunFailedBuild(project *Project, logger io.Writer, input *BuildProjectInput, build *Build, jobTimeout time.Duration, continueOnFail bool) error {
+	workflow, err := c.WaitForWorkflow(project, logger, build.Workflow.WorkflowID, jobTimeout)
+	if err != nil {
+		return err
+	}
+	if workflow.Status != statusCanceled && workflow.Status != workflowStatusOnHold && !c.workflowSucceeded(workflow) {
+		workflow, err = c.rerunFailedJobs(project, logger, input, workflow, jobTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	return c.workflowResult(project, logger, workflow, continueOnFail)
+}
+
+// workflowResult ... used internally to return an error if the finished
+// workflow was canceled or did not succeed, unless continueOnFail is true,
+// an *InfrastructureFailError is returned if the workflow only failed due to
+// a CircleCI infrastructure failure
+func (c *Client) workflowResult(project *Project, logger io.Writer, workflow *Workflow, continueOnFail bool) error {
+	if workflow.Status == statusCanceled {
+		return &BuildCanceledError{Message: fmt.Sprintf("workflow %s [%s] was canceled", project.Reponame, workflow.Name)}
+	}
+	if !c.workflowSucceeded(workflow) {
+		if continueOnFail {
+			infof(logger, "workflow %s [%s] failed, continue on failure is enabled for this project\n", project.Reponame, workflow.Name)
+			return nil
+		}
+		if c.infrastructureFailed(project, logger, workflow) {
+			return &InfrastructureFailError{Message: fmt.Sprintf("workflow %s [%s] failed due to a CircleCI infrastructure failure", project.Reponame, workflow.Name)}
+		}
+		return fmt.Errorf("workflow %s [%s] failed with status: %s", project.Reponame, workflow.Name, workflow.Status)
+	}
+	return nil
+}
+
+// infrastructureFailed ... used internally to return true if a job of the
+// failed workflow failed due to a CircleCI infrastructure failure and no job
+// failed otherwise, returns false if the jobs of the workflow are unavailable
+func (c *Client) infrastructureFailed(project *Project, logger io.Writer, workflow *Workflow) bool {
+	jobs, err := c.WorkflowJobs(workflow.ID, logger)
+	if err != nil {
+		logf(logger, "failed to get the jobs of workflow %s [%s] -> %v\n", project.Reponame, workflow.Name, err)
+		return false
+	}
+	var infrastructureFail bool
+	for _, j := range jobs {
+		switch j.Status {
+		case statusInfrastructureFail:
+			infrastructureFail = true
+		case "failed":
+			return false
+		}
+	}
+	return infrastructureFail
+}
+
+// autoApprove ... used internally to approve the approval jobs that are on hold
+// within the workflow matching the given workflowID, only jobs named in jobNames
+// are approved, returns the number of jobs approved
+func (c *Client) autoApprove(project *Project, logger io.Writer, workflowID string, jobNames []string) (int, error) {
+	jobs, err := c.WorkflowJobs(workflowID, logger)
+	if err != nil {
+		return 0, err
+	}
+	var approved int
+	for _, j := range jobs {
+		if j.Type != "approval" || j.Status != workflowStatusOnHold || !contains(jobNames, j.Name) {
+			continue
+		}
+		id := j.ApprovalRequestID
+		if len(id) == 0 {
+			id = j.ID
+		}
+		infof(logger, "approving job %s [%s] in workflow %s\n", project.Reponame, j.Name, workflowID)
+		err = c.ApproveJob(workflowID, id, logger)
+		if err != nil {
+			return approved, err
+		}
+		approved++
+	}
+	return approved, nil
+}
+
+// contains ... used internally to check if values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForApproval ... used internally to wait for a workflow that is on hold
+// to be approved, returns false if the workflow was not on hold, approval jobs
+// named in autoApproveJobs are approved automatically, any other approval jobs
+// are left on hold, the time spent waiting is bounded by the client's
+// ApprovalTimeout rather than the job timeout, a *JobTimeoutError is returned
+// if the workflow is not approved in time
+func (c *Client) waitForApproval(project *Project, logger io.Writer, workflowID string, autoApproveJobs []string) (bool, error) {
+	if c.ApprovalTimeout <= 0 && len(autoApproveJobs) == 0 {
+		return false, nil
+	}
+	workflow, err := c.GetWorkflow(workflowID, logger)
+	if err != nil || workflow.Status != workflowStatusOnHold {
+		// if the workflow status is unavailable, fallback
+		// to treating the workflow as not on hold
+		return false, nil
+	}
+	timeout := c.ApprovalTimeout
+	if len(autoApproveJobs) > 0 {
+		approved, err := c.autoApprove(project, logger, workflowID, autoApproveJobs)
+		if err != nil {
+			return false, fmt.Errorf("failed to approve jobs in workflow %s [%s] -> %v", project.Reponame, workflowID, err)
+		}
+		// approved jobs take a moment to start, even
+		// when not waiting for a person to approve them
+		if approved > 0 && timeout <= 0 {
+			timeout = time.Minute
+		}
+	}
+	if timeout <= 0 {
+		return false, nil
+	}
+	err = waiter(c.context(), c.pollInterval(), time.Now().Add(timeout), func(count int) (bool, error) {
+		if count%10 == 0 {
+			infof(logger, "waiting for workflow %s [%s] to be approved\n", project.Reponame, workflowID)
+		}
+		w, err := c.GetWorkflow(workflowID, logger)
+		if err != nil {
+			logf(logger, "failed to get workflow %s [%s] -> %v\n", project.Reponame, workflowID, err)
+			return false, nil
+		}
+		return w.Status != workflowStatusOnHold, nil
+	})
+	if err != nil {
+		if _, ok := err.(*timeoutExceededError); ok {
+			return false, &JobTimeoutError{Message: fmt.Sprintf("approval timeout exceeded while waiting for workflow %s [%s] to be approved", project.Reponame, workflowID)}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// waitForGeneratedWorkflows ... used internally to wait for the workflows generated
+// by a setup workflow when a pipeline uses dynamic configuration, does nothing unless
+// setup is identified as the setup workflow of the pipeline, the first workflow it
+// created, jobTimeout is the duration to wait for each generated workflow before
+// giving up, failed workflows are only logged when continueOnFail is true
+func (c *Client) waitForGeneratedWorkflows(project *Project, logger io.Writer, setup *Workflow, jobTimeout time.Duration, continueOnFail bool) error {
+	config, err := c.GetPipelineConfig(setup.PipelineID, logger)
+	if err != nil {
+		logf(logger, "failed to get pipeline config %s [%s], not checking for generated workflows -> %v\n", project.Reponame, setup.PipelineID, err)
+		return nil
+	}
+	if len(config.SetupConfig) == 0 {
+		return nil
+	}
+	workflows, err := c.PipelineWorkflows(setup.PipelineID, logger)
+	if err != nil {
+		return err
+	}
+	if !firstWorkflow(setup, workflows) {
+		infof(logger, "workflow %s [%s] is not the setup workflow of pipeline %s, not waiting for generated workflows\n", project.Reponame, setup.Name, setup.PipelineID)
+		return nil
+	}
+	for _, w := range workflows {
+		if w.ID == setup.ID {
+			continue
+		}
+		infof(logger, "waiting for workflow %s [%s] generated by setup workflow %s\n", project.Reponame, w.Name, setup.Name)
+		w, err = c.WaitForWorkflow(project, logger, w.ID, jobTimeout)
+		if err != nil {
+			return err
+		}
+		if w.Status == workflowStatusOnHold {
+			// matches the WorkflowStatus strategy, which
+			// stops waiting once a workflow is on hold
+			infof(logger, "workflow %s [%s] is on hold, not waiting for approval\n", project.Reponame, w.Name)
+			continue
+		}
+		if !c.workflowSucceeded(w) {
+			if continueOnFail {
+				infof(logger, "workflow %s [%s] failed, continue on failure is enabled for this project\n", project.Reponame, w.Name)
+				continue
+			}
+			return fmt.Errorf("workflow %s [%s] failed with status: %s", project.Reponame, w.Name, w.Status)
+		}
+	}
+	return nil
+}
+
+// firstWorkflow ... used internally to return true if w was created before every
+// other workflow of the pipeline, as a setup workflow creates the workflows it
+// generates, false is returned if the creation times are unknown
+func firstWorkflow(w *Workflow, workflows []*Workflow) bool {
+	if w.CreatedAt == nil {
+		return false
+	}
+	for _, other := range workflows {
+		if other.ID == w.ID {
+			continue
+		}
+		if other.CreatedAt == nil || !other.CreatedAt.After(*w.CreatedAt) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,634 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// nolint: funlen
+func TestWaitForWorkflow(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		timeout     time.Duration
+		resp        []string
+		expectedErr string
+		expected    *Workflow
+		slow        bool
+	}{
+		"workflow succeeded": {
+			timeout:  time.Minute,
+			resp:     []string{`{"id": "test", "name": "deploy", "status": "success"}`},
+			expected: &Workflow{ID: "test", Name: "deploy", Status: "success"},
+		},
+		"workflow failed": {
+			timeout:  time.Minute,
+			resp:     []string{`{"id": "test", "name": "deploy", "status": "failed"}`},
+			expected: &Workflow{ID: "test", Name: "deploy", Status: "failed"},
+		},
+		"workflow running then succeeded": {
+			timeout: time.Minute,
+			resp: []string{
+				`{"id": "test", "name": "deploy", "status": "running"}`,
+				`{"id": "test", "name": "deploy", "status": "success"}`,
+			},
+			expected: &Workflow{ID: "test", Name: "deploy", Status: "success"},
+			slow:     true,
+		},
+		"workflow on hold": {
+			timeout:  time.Minute,
+			resp:     []string{`{"id": "test", "name": "deploy", "status": "on_hold"}`},
+			expected: &Workflow{ID: "test", Name: "deploy", Status: "on_hold"},
+		},
+		"timeout exceeded": {
+			timeout:     time.Second,
+			resp:        []string{`{"id": "test", "name": "deploy", "status": "running"}`},
+			expectedErr: "timeout exceeded while waiting for workflow test1 [test] to finish",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			if tc.slow && testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+			var count int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				assert.Equal(t, "/api/v2/workflow/test", path)
+				resp := tc.resp[len(tc.resp)-1]
+				if count < len(tc.resp) {
+					resp = tc.resp[count]
+				}
+				count++
+				err := json.Unmarshal([]byte(resp), output)
+				if err != nil {
+					return fmt.Errorf("failed to decode response: %v", err)
+				}
+				return nil
+			})
+			actual, err := client.WaitForWorkflow(&project, os.Stdout, "test", tc.timeout)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+				_, ok := err.(*JobTimeoutError)
+				assert.Assert(t, ok, "expected *JobTimeoutError, got %T", err)
+			}
+			assert.DeepEqual(t, tc.expected, actual)
+		})
+	}
+}
+
+// nolint: funlen
+func TestGetWorkflowJobStatus(t *testing.T) {
+	pages := map[string]string{
+		"": `{"items": [
+			{"id": "1", "name": "build", "type": "build", "status": "success", "started_at": "2020-01-01T00:00:00Z"},
+			{"id": "2", "name": "hold", "type": "approval", "status": "success", "started_at": "2020-01-01T00:01:00Z"}
+		], "next_page_token": "page2"}`,
+		"page2": `{"items": [
+			{"id": "3", "name": "hold", "type": "approval", "status": "on_hold", "started_at": "2020-01-01T00:02:00Z"},
+			{"id": "4", "name": "integration-tests", "type": "build", "status": "blocked"}
+		]}`,
+	}
+	tt := map[string]struct {
+		jobName     string
+		expectedErr string
+		expectedID  string
+	}{
+		"single match":         {jobName: "build", expectedID: "1"},
+		"most recent match":    {jobName: "hold", expectedID: "3"},
+		"match on second page": {jobName: "integration-tests", expectedID: "4"},
+		"no match": {
+			jobName:     "deploy",
+			expectedErr: `failed to locate job "deploy" in workflow test`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				assert.Equal(t, "/api/v2/workflow/test/job", path)
+				err := json.Unmarshal([]byte(pages[params.Get("page-token")]), output)
+				if err != nil {
+					return fmt.Errorf("failed to decode response: %v", err)
+				}
+				return nil
+			})
+			actual, err := client.GetWorkflowJobStatus("test", tc.jobName, os.Stdout)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+				assert.Equal(t, tc.expectedID, actual.ID)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		assert.Equal(t, "/api/v2/project/gh/org/test1/job/42", path)
+		return json.Unmarshal([]byte(`{
+				"number": 42,
+				"name": "test",
+				"status": "success",
+				"started_at": "2020-01-01T00:00:00Z",
+				"stopped_at": "2020-01-01T00:01:30Z",
+				"duration": 90000,
+				"parallelism": 4,
+				"executor": {"type": "docker", "resource_class": "medium"},
+				"contexts": [{"name": "deploy"}]
+			}`), output)
+	})
+	actual, err := client.GetJob(&project, os.Stdout, 42)
+	assert.NilError(t, err)
+	assert.Equal(t, 42, actual.Number)
+	assert.Equal(t, int64(90000), actual.Duration)
+	assert.Equal(t, 4, actual.Parallelism)
+	assert.Equal(t, "docker", actual.Executor.Type)
+	assert.Equal(t, "deploy", actual.Contexts[0].Name)
+	assert.Equal(t, 90*time.Second, actual.StoppedAt.Sub(*actual.StartedAt))
+}
+
+func TestCancelWorkflow(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		requests = append(requests, method+" "+path)
+		return json.Unmarshal([]byte(`{"message": "Accepted."}`), output)
+	})
+	assert.NilError(t, client.CancelWorkflow("test", os.Stdout))
+	assert.DeepEqual(t, []string{"POST /api/v2/workflow/test/cancel"}, requests)
+}
+
+func TestRerunWorkflow(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		requests = append(requests, method+" "+path)
+		assert.DeepEqual(t, &rerunWorkflowInput{FromFailed: true}, input)
+		return json.Unmarshal([]byte(`{"workflow_id": "rerun"}`), output)
+	})
+	workflowID, err := client.RerunWorkflow("test", os.Stdout, true)
+	assert.NilError(t, err)
+	assert.Equal(t, "rerun", workflowID)
+	assert.DeepEqual(t, []string{"POST /api/v2/workflow/test/rerun"}, requests)
+}
+
+func TestRerunFailedJobs(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		strategy    WaitStrategy
+		reruns      int
+		statuses    map[string]string
+		expected    []string
+		expectedErr string
+	}{
+		"rerun succeeded": {
+			strategy: WorkflowStatus,
+			reruns:   2,
+			statuses: map[string]string{"test": "failed", "rerun-1": "success"},
+			expected: []string{"test"},
+		},
+		"reruns failed": {
+			strategy:    WorkflowStatus,
+			reruns:      2,
+			statuses:    map[string]string{"test": "failed", "rerun-1": "failed", "rerun-2": "failed"},
+			expected:    []string{"test", "rerun-1"},
+			expectedErr: "workflow test1 [deploy] failed with status: failed",
+		},
+		"reruns disabled": {
+			strategy:    WorkflowStatus,
+			statuses:    map[string]string{"test": "failed"},
+			expectedErr: "workflow test1 [deploy] failed with status: failed",
+		},
+		"build chain rerun succeeded": {
+			strategy: BuildChain,
+			reruns:   1,
+			statuses: map[string]string{"test": "failed", "rerun-1": "success"},
+			expected: []string{"test"},
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var rerun []string
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch o := output.(type) {
+				case *Build:
+					*o = Build{BuildNum: 42, Lifecycle: lifecycleFinished, Status: "failed", Failed: boolPtr(true), Workflow: &BuildWorkflow{WorkflowID: "test"}}
+				case *Workflow:
+					id := path[len(apiV2Path+"workflow/"):]
+					*o = Workflow{ID: id, Name: "deploy", PipelineID: "pipeline", Status: tc.statuses[id]}
+				case *rerunWorkflowOutput:
+					id := path[len(apiV2Path+"workflow/") : len(path)-len("/rerun")]
+					rerun = append(rerun, id)
+					o.WorkflowID = fmt.Sprintf("rerun-%d", len(rerun))
+				case *PipelineConfig:
+					// not a setup workflow
+				default:
+					return fmt.Errorf("unknown output type: %T", output)
+				}
+				return nil
+			})
+			client.WaitStrategy = tc.strategy
+			client.PollInterval = time.Millisecond
+			input := &BuildProjectInput{Branch: "master", RerunFailedJobs: tc.reruns}
+			summary := &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}}
+			err := client.WaitForProjectBuild(&project, os.Stdout, input, summary, time.Minute, time.Minute, false)
+			if len(tc.expectedErr) > 0 {
+				assert.Error(t, err, tc.expectedErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.DeepEqual(t, tc.expected, rerun)
+		})
+	}
+}
+
+func TestWaitForApproval(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		approvalTimeout time.Duration
+		statuses        []string
+		expectedErr     string
+		expected        bool
+	}{
+		"disabled": {
+			statuses: []string{"on_hold"},
+		},
+		"not on hold": {
+			approvalTimeout: time.Minute,
+			statuses:        []string{"success"},
+		},
+		"approved": {
+			approvalTimeout: time.Minute,
+			statuses:        []string{"on_hold", "running"},
+			expected:        true,
+		},
+		"approval timeout exceeded": {
+			approvalTimeout: time.Second,
+			statuses:        []string{"on_hold"},
+			expectedErr:     "approval timeout exceeded while waiting for workflow test1 [test] to be approved",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var count int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				status := tc.statuses[len(tc.statuses)-1]
+				if count < len(tc.statuses) {
+					status = tc.statuses[count]
+				}
+				count++
+				output.(*Workflow).Status = status
+				return nil
+			})
+			client.ApprovalTimeout = tc.approvalTimeout
+			actual, err := client.waitForApproval(&project, os.Stdout, "test", nil)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+				_, ok := err.(*JobTimeoutError)
+				assert.Assert(t, ok, "expected *JobTimeoutError, got %T", err)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+// nolint: funlen
+func TestAutoApprove(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	jobs := []*WorkflowJob{
+		{ID: "1", Name: "build", Type: "build", Status: "success"},
+		{ID: "2", Name: "hold-for-staging", Type: "approval", Status: "on_hold", ApprovalRequestID: "staging"},
+		{ID: "3", Name: "hold-for-prod", Type: "approval", Status: "on_hold", ApprovalRequestID: "prod"},
+	}
+	tt := map[string]struct {
+		autoApproveJobs []string
+		expectedIDs     []string
+		expected        bool
+	}{
+		"disabled": {},
+		"approve matching job": {
+			autoApproveJobs: []string{"hold-for-staging"},
+			expectedIDs:     []string{"staging"},
+			expected:        true,
+		},
+		"no matching jobs": {
+			autoApproveJobs: []string{"hold-for-qa"},
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var approvedIDs []string
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch o := output.(type) {
+				case *Workflow:
+					o.Status = "on_hold"
+					if len(approvedIDs) > 0 {
+						o.Status = "running"
+					}
+				case *pageV2:
+					assert.Equal(t, "/api/v2/workflow/test/job", path)
+					items, err := json.Marshal(jobs)
+					if err != nil {
+						return err
+					}
+					o.Items = items
+				case *messageResponse:
+					assert.Equal(t, "POST", method)
+					id := path[len("/api/v2/workflow/test/approve/"):]
+					approvedIDs = append(approvedIDs, id)
+					o.Message = "Accepted."
+				default:
+					return fmt.Errorf("unknown output type: %T", output)
+				}
+				return nil
+			})
+			actual, err := client.waitForApproval(&project, os.Stdout, "test", tc.autoApproveJobs)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.DeepEqual(t, tc.expectedIDs, approvedIDs)
+		})
+	}
+}
+
+// nolint: funlen
+func TestWaitForGeneratedWorkflows(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	created := time.Now()
+	tt := map[string]struct {
+		setupConfig string
+		workflows   map[string]string
+		workflow    string
+		expectedErr string
+		expected    []string
+	}{
+		"not a setup workflow": {
+			workflows: map[string]string{"build": "success"},
+		},
+		"generated workflow": {
+			setupConfig: "version: 2.1",
+			workflows:   map[string]string{"build": "success", "deploy": "success"},
+			workflow:    "build",
+		},
+		"generated workflows succeeded": {
+			setupConfig: "version: 2.1",
+			workflows:   map[string]string{"build": "success", "deploy": "success"},
+			expected:    []string{"build", "deploy"},
+		},
+		"generated workflow failed": {
+			setupConfig: "version: 2.1",
+			workflows:   map[string]string{"build": "success", "deploy": "failed"},
+			expectedErr: "workflow test1 [deploy] failed with status: failed",
+			expected:    []string{"build", "deploy"},
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var waited []string
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch o := output.(type) {
+				case *Workflow:
+					name := path[len("/api/v2/workflow/"):]
+					waited = append(waited, name)
+					*o = Workflow{ID: name, Name: name, PipelineID: "pipeline", Status: tc.workflows[name]}
+				case *PipelineConfig:
+					assert.Equal(t, "/api/v2/pipeline/pipeline/config", path)
+					o.SetupConfig = tc.setupConfig
+				case *pageV2:
+					assert.Equal(t, "/api/v2/pipeline/pipeline/workflow", path)
+					workflows := []*Workflow{{ID: "setup", Name: "setup", CreatedAt: &created}}
+					generated := created.Add(time.Second)
+					for _, name := range []string{"build", "deploy"} {
+						if _, ok := tc.workflows[name]; ok {
+							workflows = append(workflows, &Workflow{ID: name, Name: name, CreatedAt: &generated})
+						}
+					}
+					items, err := json.Marshal(workflows)
+					if err != nil {
+						return err
+					}
+					o.Items = items
+				default:
+					return fmt.Errorf("unknown output type: %T", output)
+				}
+				return nil
+			})
+			setup := &Workflow{ID: "setup", Name: "setup", PipelineID: "pipeline", Status: "success", CreatedAt: &created}
+			if len(tc.workflow) > 0 {
+				generated := created.Add(time.Second)
+				setup = &Workflow{ID: tc.workflow, Name: tc.workflow, PipelineID: "pipeline", Status: "success", CreatedAt: &generated}
+			}
+			err := client.waitForGeneratedWorkflows(&project, os.Stdout, setup, time.Minute, false)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+			assert.DeepEqual(t, tc.expected, waited)
+		})
+	}
+}
+
+func TestParseWaitStrategy(t *testing.T) {
+	for _, s := range []WaitStrategy{BuildChain, WorkflowStatus} {
+		actual, err := ParseWaitStrategy(s.String())
+		assert.NilError(t, err)
+		assert.Equal(t, s, actual)
+	}
+	_, err := ParseWaitStrategy("chain")
+	assert.Error(t, err, `unknown wait strategy: "chain", must be one of buildchain or workflow`)
+}
+
+// nolint: funlen
+func TestWaitForWorkflowStatus(t *testing.T) {
+	project := Project{
+		Username: "org",
+		Reponame: "test1",
+		Vcs:      "github",
+		VcsURL:   "https://github.com/org/test1",
+	}
+	tt := map[string]struct {
+		summary         *BuildSummaryOutput
+		statuses        []string
+		jobs            string
+		approvalTimeout time.Duration
+		continueOnFail  bool
+		succeeded       func(*Workflow) bool
+		expectedErr     string
+		slow            bool
+	}{
+		"workflow succeeded": {
+			summary:  &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses: []string{"success"},
+		},
+		"workflow resolved from build": {
+			summary:  &BuildSummaryOutput{BuildNum: 42},
+			statuses: []string{"success"},
+		},
+		"workflow failed": {
+			summary:     &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:    []string{"failed"},
+			expectedErr: "workflow test1 [deploy] failed with status: failed",
+		},
+		"workflow infrastructure failure": {
+			summary:     &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:    []string{"failed"},
+			jobs:        `{"items": [{"status": "success"}, {"status": "infrastructure_fail"}]}`,
+			expectedErr: "workflow test1 [deploy] failed due to a CircleCI infrastructure failure",
+		},
+		"workflow failed with infrastructure failure": {
+			summary:     &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:    []string{"failed"},
+			jobs:        `{"items": [{"status": "failed"}, {"status": "infrastructure_fail"}]}`,
+			expectedErr: "workflow test1 [deploy] failed with status: failed",
+		},
+		"workflow failed with continue on fail": {
+			summary:        &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:       []string{"failed"},
+			continueOnFail: true,
+		},
+		"workflow failed accepted by predicate": {
+			summary:   &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:  []string{"failed"},
+			succeeded: func(w *Workflow) bool { return w.Status != "error" },
+		},
+		"workflow succeeded rejected by predicate": {
+			summary:     &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:    []string{"success"},
+			succeeded:   func(w *Workflow) bool { return false },
+			expectedErr: "workflow test1 [deploy] failed with status: success",
+		},
+		"workflow canceled": {
+			summary:     &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:    []string{"canceled"},
+			expectedErr: "workflow test1 [deploy] was canceled",
+		},
+		"workflow on hold": {
+			summary:  &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses: []string{"on_hold"},
+		},
+		"workflow approved then failed": {
+			summary:         &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}},
+			statuses:        []string{"on_hold", "on_hold", "running", "failed"},
+			approvalTimeout: time.Minute,
+			expectedErr:     "workflow test1 [deploy] failed with status: failed",
+			slow:            true,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			if tc.slow && testing.Short() {
+				t.Skip("skipping test in short mode.")
+			}
+			var count int
+			client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+				switch o := output.(type) {
+				case *Build:
+					assert.Equal(t, "project/github/org/test1/42", path)
+					o.Workflow = &BuildWorkflow{WorkflowID: "test"}
+				case *Workflow:
+					assert.Equal(t, "/api/v2/workflow/test", path)
+					status := tc.statuses[len(tc.statuses)-1]
+					if count < len(tc.statuses) {
+						status = tc.statuses[count]
+					}
+					count++
+					*o = Workflow{ID: "test", Name: "deploy", PipelineID: "pipeline", Status: status}
+				case *PipelineConfig:
+					// not a setup workflow
+				case *pageV2:
+					assert.Equal(t, "/api/v2/workflow/test/job", path)
+					jobs := tc.jobs
+					if jobs == "" {
+						jobs = `{"items": []}`
+					}
+					return json.Unmarshal([]byte(jobs), o)
+				default:
+					return fmt.Errorf("unknown output type: %T", output)
+				}
+				return nil
+			})
+			client.WaitStrategy = WorkflowStatus
+			client.ApprovalTimeout = tc.approvalTimeout
+			client.WorkflowSucceeded = tc.succeeded
+			err := client.WaitForProjectBuild(&project, os.Stdout, &BuildProjectInput{}, tc.summary, time.Minute, time.Minute, tc.continueOnFail)
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestWaitForProjectBuildDeadline(t *testing.T) {
+	project := Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client := newTestClient(t, func(c *Client, method string, path string, params url.Values, input interface{}, output interface{}) error {
+		switch o := output.(type) {
+		case *Build:
+			o.Workflow = &BuildWorkflow{WorkflowID: "test"}
+		case *Workflow:
+			// the build is still running when the deadline passes
+			*o = Workflow{ID: "test", Name: "deploy", PipelineID: "pipeline", Status: "running"}
+		case *PipelineConfig:
+		default:
+			return fmt.Errorf("unknown output type: %T", output)
+		}
+		return nil
+	})
+	client.WaitStrategy = WorkflowStatus
+	client.PollInterval = 5 * time.Millisecond
+	start := time.Now()
+	summary := &BuildSummaryOutput{BuildNum: 42, Workflow: &BuildWorkflow{WorkflowID: "test"}}
+	err := client.WithContext(ctx).WaitForProjectBuild(&project, os.Stdout, &BuildProjectInput{}, summary, time.Minute, time.Minute, false)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+	// the in-flight build is abandoned rather than waited on for the job timeout
+	assert.Assert(t, time.Since(start) < time.Second, "expected the build to be abandoned, waited %s", time.Since(start))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// flagEnv ... the environment variables that set the default of a flag, keyed by flag name
+var flagEnv = map[string]string{
+	"jobtimeout":   "CIRCLECI_JOB_TIMEOUT",
+	"skipdays":     "CIRCLECI_SKIP_DAYS",
+	"retries":      "CIRCLECI_RETRIES",
+	"pollinterval": "CIRCLECI_POLL_INTERVAL",
+}
+
+// tokenEnv ... the environment variables containing access tokens, which are never printed
+var tokenEnv = []string{"CIRCLECI_TOKEN", "GITHUB_TOKEN"}
+
+// printConfig ... writes an aligned table of the resolved value of each flag in fs
+// to w, with the source of the value, either the flag, an environment variable or
+// the default, access tokens are redacted
+func printConfig(w io.Writer, fs *flag.FlagSet, client *circleci.Client) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	fmt.Fprintf(tw, "baseurl\t%s\tdefault\n", client.BaseURL())
+	for _, name := range tokenEnv {
+		value := "not set"
+		if len(os.Getenv(name)) > 0 {
+			value = "[redacted]"
+		}
+		fmt.Fprintf(tw, "%s\t%s\tenv\n", name, value)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		source := "default"
+		if set[f.Name] {
+			source = "flag"
+		} else if env, ok := flagEnv[f.Name]; ok && len(os.Getenv(env)) > 0 {
+			source = "env " + env
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, f.Value, source)
+	})
+	err := tw.Flush()
+	if err != nil {
+		log.Printf("failed to print config -> %v\n", err)
+	}
+}
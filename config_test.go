@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+func TestPrintConfig(t *testing.T) {
+	os.Setenv("CIRCLECI_TOKEN", "secret-token")
+	defer os.Unsetenv("CIRCLECI_TOKEN")
+	os.Setenv("CIRCLECI_SKIP_DAYS", "7")
+	defer os.Unsetenv("CIRCLECI_SKIP_DAYS")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("jobtimeout", 20, "")
+	fs.Int("skipdays", envInt("CIRCLECI_SKIP_DAYS", 30), "")
+	fs.Bool("noskip", false, "")
+	err := fs.Parse([]string{"-jobtimeout", "45"})
+	if err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+	var buf bytes.Buffer
+	printConfig(&buf, fs, circleci.NewClient(nil, "secret-token"))
+	out := buf.String()
+	if strings.Contains(out, "secret-token") {
+		t.Errorf("printConfig() failed: the access token was not redacted:\n%s", out)
+	}
+	for _, expected := range [][]string{
+		{"baseurl", "https://circleci.com/api/v1.1/", "default"},
+		{"CIRCLECI_TOKEN", "[redacted]", "env"},
+		{"jobtimeout", "45", "flag"},
+		{"skipdays", "7", "env CIRCLECI_SKIP_DAYS"},
+		{"noskip", "false", "default"},
+	} {
+		found := false
+		for _, line := range strings.Split(out, "\n") {
+			if strings.Join(strings.Fields(line), " ") == strings.Join(expected, " ") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("printConfig() failed: expected %v in:\n%s", expected, out)
+		}
+	}
+}
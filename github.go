@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// githubAPIURL ... the base url of the GitHub REST API
+const githubAPIURL = "https://api.github.com"
+
+// githubClient ... used to verify the refs of entries and report the
+// status of builds using the GitHub REST API
+type githubClient struct {
+	client  *http.Client
+	baseURL string
+	//optional, GitHub access token used to authenticate requests
+	token string
+}
+
+// newGitHubClient ... returns a *githubClient using the given GitHub access token,
+// if token is empty, requests are unauthenticated and only public repositories
+// can be read
+func newGitHubClient(token string) *githubClient {
+	return &githubClient{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: githubAPIURL,
+		token:   token,
+	}
+}
+
+// do ... sends a request to the GitHub REST API, input is encoded as the JSON body
+func (v *githubClient) do(method string, u string, input interface{}) (*http.Response, error) {
+	var body bytes.Buffer
+	if input != nil {
+		err := json.NewEncoder(&body).Encode(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, u, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	if len(v.token) > 0 {
+		req.Header.Set("Authorization", "token "+v.token)
+	}
+	return v.client.Do(req)
+}
+
+// closeBody ... closes the body of resp, logging any failure
+func closeBody(resp *http.Response) {
+	err := resp.Body.Close()
+	if err != nil {
+		log.Printf("failed to close response body -> %v\n", err)
+	}
+}
+
+// exists ... returns true if a GET request to u succeeds, and false if
+// GitHub reports the ref does not exist
+func (v *githubClient) exists(u string) (bool, error) {
+	resp, err := v.do("GET", u, nil)
+	if err != nil {
+		return false, err
+	}
+	defer closeBody(resp)
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	// unknown commits return 422 Unprocessable Entity
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity:
+		return false, nil
+	}
+	return false, fmt.Errorf("non-success status code returned %s", resp.Status)
+}
+
+// statusContextPrefix ... prefixes the entry name in the context of commit statuses
+const statusContextPrefix = "grace-circleci-builder/"
+
+// commitStatus ... used internally to represent the request body
+// when creating a commit status using the GitHub REST API
+// https://docs.github.com/en/rest/reference/repos#create-a-commit-status
+type commitStatus struct {
+	// error, failure, pending or success
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+}
+
+// postStatus ... creates a commit status for the revision of the given build
+// summary, named after the entry, state is error, failure, pending or success
+// https://docs.github.com/en/rest/reference/repos#create-a-commit-status
+func (v *githubClient) postStatus(project *circleci.Project, name string, summary *circleci.BuildSummaryOutput, state string, description string) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/statuses/%s", v.baseURL, project.Username, project.Reponame, summary.Revision)
+	resp, err := v.do("POST", u, &commitStatus{
+		State:       state,
+		TargetURL:   summary.BuildURL,
+		Description: description,
+		Context:     statusContextPrefix + name,
+	})
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("non-success status code returned %s", resp.Status)
+	}
+	return nil
+}
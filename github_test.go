@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+func TestReportStatus(t *testing.T) {
+	var (
+		path   string
+		auth   string
+		status commitStatus
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		err := json.NewDecoder(r.Body).Decode(&status)
+		if err != nil {
+			t.Fatalf("failed to decode commit status: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	gh := newGitHubClient("token")
+	gh.baseURL = srv.URL
+	project := &circleci.Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	summary := &circleci.BuildSummaryOutput{Revision: "d8cbe5e", BuildURL: "https://circleci.com/gh/org/test1/42"}
+	tt := map[string]struct {
+		err   error
+		state string
+	}{
+		"success":  {state: "success"},
+		"failure":  {err: errors.New("build failed"), state: "failure"},
+		"timeout":  {err: &circleci.JobTimeoutError{}, state: "error"},
+		"canceled": {err: &circleci.BuildCanceledError{}, state: "error"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			status = commitStatus{}
+			reportStatus(gh, project, &entry{Name: "deploy"}, summary, tc.err)
+			if path != "/repos/org/test1/statuses/d8cbe5e" || auth != "token token" {
+				t.Errorf("reportStatus() failed: unexpected request: %s (%s)", path, auth)
+			}
+			expected := commitStatus{State: tc.state, TargetURL: summary.BuildURL, Context: "grace-circleci-builder/deploy", Description: status.Description}
+			if status != expected {
+				t.Errorf("reportStatus() failed: Expected: %+v\nGot: %+v", expected, status)
+			}
+		})
+	}
+}
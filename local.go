@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// detachedHead ... the branch name reported by git when HEAD is not a branch
+const detachedHead = "HEAD"
+
+// gitCommand ... runs git with args in the repository at dir and returns
+// the trimmed output, replaced in tests
+var gitCommand = func(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed -> %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// localBuildInput ... returns a *circleci.BuildProjectInput for the current HEAD
+// of the git repository at dir, the branch is empty if HEAD is detached
+func localBuildInput(dir string) (*circleci.BuildProjectInput, error) {
+	revision, err := gitCommand(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	branch, err := gitCommand(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if branch == detachedHead {
+		branch = ""
+	}
+	return &circleci.BuildProjectInput{Branch: branch, Revision: revision}, nil
+}
+
+// localEntry ... returns an entry building the current HEAD of the git repository
+// at dir, the project is derived from the URL of the origin remote
+func localEntry(dir string) (*entry, error) {
+	remote, err := gitCommand(dir, "remote", "get-url", "origin")
+	if err != nil {
+		return nil, err
+	}
+	project, err := circleci.ProjectFromURL(remote)
+	if err != nil {
+		return nil, err
+	}
+	input, err := localBuildInput(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{
+		Name:   project.Reponame,
+		URL:    project.VcsURL,
+		Branch: input.Branch,
+		Commit: input.Revision,
+	}, nil
+}
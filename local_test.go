@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocalEntry(t *testing.T) {
+	tt := map[string]struct {
+		outputs     map[string]string
+		expected    *entry
+		expectedErr string
+	}{
+		"branch": {
+			outputs: map[string]string{
+				"remote get-url origin":       "git@github.com:org/test1.git",
+				"rev-parse HEAD":              "abc123",
+				"rev-parse --abbrev-ref HEAD": "feature",
+			},
+			expected: &entry{Name: "test1", URL: "https://github.com/org/test1", Branch: "feature", Commit: "abc123"},
+		},
+		"detached head": {
+			outputs: map[string]string{
+				"remote get-url origin":       "https://github.com/org/test1.git",
+				"rev-parse HEAD":              "abc123",
+				"rev-parse --abbrev-ref HEAD": "HEAD",
+			},
+			expected: &entry{Name: "test1", URL: "https://github.com/org/test1", Commit: "abc123"},
+		},
+		"no origin": {
+			outputs:     map[string]string{},
+			expectedErr: "git remote get-url origin failed",
+		},
+	}
+	defer func(f func(string, ...string) (string, error)) { gitCommand = f }(gitCommand)
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			gitCommand = func(dir string, args ...string) (string, error) {
+				if dir != "repo" {
+					t.Errorf("gitCommand() failed: expected dir: repo, got: %s", dir)
+				}
+				out, ok := tc.outputs[strings.Join(args, " ")]
+				if !ok {
+					return "", fmt.Errorf("git %s failed", strings.Join(args, " "))
+				}
+				return out, nil
+			}
+			actual, err := localEntry("repo")
+			if len(tc.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("localEntry() failed: expected error: %s, got: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("localEntry() failed: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("localEntry() failed: expected: %#v, got: %#v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// logOutput ... the writer used for progress logged by the circleci client,
+// it is replaced by a *jsonLogger writing to stderr when structured logging
+// is enabled, so that stdout only carries the summary table and listings
+var logOutput io.Writer = os.Stdout
+
+// quietLogs ... when true progress messages logged by infof are discarded,
+// so that only failures and the results of the run are logged
+var quietLogs bool
+
+// infof ... logs a progress message unless quiet logging is enabled
+func infof(format string, args ...interface{}) {
+	if quietLogs {
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// setQuietLogs ... enables quiet logging, progress messages logged by
+// the runner and the circleci client are discarded
+func setQuietLogs() {
+	quietLogs = true
+	logOutput = &circleci.QuietWriter{Writer: logOutput}
+}
+
+// jsonLogger ... an io.Writer that emits each write as a single
+// JSON object on its own line, for ingestion by log aggregators
+type jsonLogger struct {
+	mu      sync.Mutex
+	w       io.Writer
+	level   string
+	project string
+	now     func() time.Time
+}
+
+// jsonLogLine ... the structure of each line written by a jsonLogger
+type jsonLogLine struct {
+	Timestamp string `json:"ts"`
+	Level     string `json:"level"`
+	Project   string `json:"project,omitempty"`
+	Message   string `json:"msg"`
+}
+
+// newJSONLogger ... returns a *jsonLogger writing lines of the given level to w
+func newJSONLogger(w io.Writer, level string) *jsonLogger {
+	return &jsonLogger{w: w, level: level, now: time.Now}
+}
+
+// setProject ... sets the project included with each line written,
+// an empty name removes the project from subsequent lines
+func (l *jsonLogger) setProject(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.project = name
+}
+
+// Write ... encodes p as the message of a single JSON line, surrounding
+// whitespace is removed and empty messages are discarded
+func (l *jsonLogger) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimSpace(p))
+	if len(msg) == 0 {
+		return len(p), nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := json.NewEncoder(l.w).Encode(&jsonLogLine{
+		Timestamp: l.now().UTC().Format(time.RFC3339Nano),
+		Level:     l.level,
+		Project:   l.project,
+		Message:   msg,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setLogProject ... sets the project included with structured log lines,
+// it has no effect unless structured logging is enabled
+func setLogProject(name string) {
+	w := logOutput
+	if q, ok := w.(*circleci.QuietWriter); ok {
+		w = q.Writer
+	}
+	if l, ok := w.(*jsonLogger); ok {
+		l.setProject(name)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := newJSONLogger(&buf, "info")
+	l.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	fmt.Fprintf(l, "Searching for project with url: %s\n", "https://github.com/org/test1")
+	l.setProject("test1")
+	fmt.Fprintf(l, "Building \"quoted\" project")
+	fmt.Fprint(l, "\n")
+
+	expected := `{"ts":"2020-01-02T03:04:05Z","level":"info","msg":"Searching for project with url: https://github.com/org/test1"}
+{"ts":"2020-01-02T03:04:05Z","level":"info","project":"test1","msg":"Building \"quoted\" project"}
+`
+	if buf.String() != expected {
+		t.Errorf("jsonLogger.Write() failed: Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestQuietLogs(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer, quiet bool) {
+		logOutput = w
+		quietLogs = quiet
+		log.SetOutput(os.Stderr)
+	}(logOutput, quietLogs)
+	logOutput = newJSONLogger(&buf, "info")
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer log.SetFlags(log.LstdFlags)
+
+	setQuietLogs()
+	infof("Building project %q\n", "test1")
+	log.Printf("Building project %q, failed\n", "test1")
+	if buf.String() != "Building project \"test1\", failed\n" {
+		t.Errorf("infof() failed: expected progress messages to be discarded, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	setLogProject("test1")
+	fmt.Fprintf(logOutput, "failed to get build\n")
+	if !strings.Contains(buf.String(), `"project":"test1"`) {
+		t.Errorf("setLogProject() failed: expected the project to be set through the quiet writer, got:\n%s", buf.String())
+	}
+}
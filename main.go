@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/GSA/grace-circleci-builder/circleci"
 )
@@ -13,27 +19,244 @@ func main() {
 	if len(token) == 0 {
 		log.Fatal("CIRCLECI_TOKEN environment variable must contain the access key to authenticate to circleci.com")
 	}
-	buildFilePtr := flag.String("file", "Buildfile", "provides the location of the JSON formatted build file to process")
-	jobTimeoutPtr := flag.Int("jobtimeout", 20, "specifies the number of minutes that a build job can take before timing out")
-	skipDaysPtr := flag.Int("skipdays", 30, "specifies the number of days to consider a previous build relevant for skipping")
+	buildFilePtr := flag.String("file", "Buildfile", "provides the location of the JSON formatted build file to process, a directory or glob pattern of build files, - reads from stdin")
+	herePtr := flag.Bool("here", false, "builds the current HEAD of the git repository in the current directory instead of the build file, the project is derived from the origin remote")
+	jobTimeoutPtr := flag.Int("jobtimeout", envInt(flagEnv["jobtimeout"], 20), "specifies the number of minutes that a build job can take before timing out")
+	activeTimeoutPtr := flag.Bool("activetimeout", false, "starts the jobtimeout of each build once it is running, so time spent queued is not counted (buildchain waitstrategy only)")
+	queueTimeoutPtr := flag.Int("queuetimeout", 0, "specifies the number of minutes a build can remain queued before failing as never started (0 disables the timeout, buildchain waitstrategy only)")
+	approvalTimeoutPtr := flag.Int("approvaltimeout", 0, "specifies the number of minutes to wait for a workflow that is on hold to be approved")
+	skipDaysPtr := flag.Int("skipdays", envInt(flagEnv["skipdays"], 30), "specifies the number of days to consider a previous build relevant for skipping")
+	skipStatusPtr := flag.String("skipstatus", defaultSkipStatus, "specifies the workflow status of previous builds relevant for skipping, any skips regardless of status")
 	noSkipPtr := flag.Bool("noskip", false, "prevents skipping of previously built entries")
+	failOnSkipPtr := flag.Bool("failonskip", false, "fails the run if any entry was skipped because it was previously built")
+	retriesPtr := flag.Int("retries", envInt(flagEnv["retries"], 3), "specifies the number of attempts made for each request to CircleCI")
+	retryIntervalPtr := flag.Int("retryinterval", 30, "specifies the number of seconds to wait between failed requests to CircleCI")
+	requestTimeoutPtr := flag.Int("requesttimeout", 0, "specifies the number of seconds each request to CircleCI can take before it is retried (0 disables the timeout)")
+	pollIntervalPtr := flag.Int("pollinterval", envInt(flagEnv["pollinterval"], 2), "specifies the number of seconds between each poll of the status of a build while waiting for it to finish")
+	noFollowPtr := flag.Bool("nofollow", false, "prevents following projects, entries for projects that are not followed will fail")
+	drainRunningPtr := flag.Bool("drainrunning", false, "waits for builds of each project that are already running to finish before building it, for at most the jobtimeout")
+	noWaitPtr := flag.Bool("nowait", false, "triggers builds using the CircleCI API v2 without waiting for them to complete")
+	buildActorPtr := flag.String("buildactor", "", "specifies the username triggered builds are attributed to, defaults to the owner of CIRCLECI_TOKEN")
+	debugPtr := flag.Bool("debug", false, "logs each request and response sent to CircleCI, the access key is redacted")
+	junitPtr := flag.String("junit", "", "provides the path of a file the results are written to as JUnit XML, each entry is a test case")
+	summaryPtr := flag.Bool("summary", false, "prints a summary table of the results after all builds complete")
+	waitStrategyPtr := flag.String("waitstrategy", "buildchain", "specifies how builds are waited on, buildchain follows each build, workflow polls the workflow status using the CircleCI API v2")
+	apiVersionPtr := flag.String("apiversion", "v1.1", "specifies the CircleCI API version used to read builds where both versions are implemented, either v1.1 or v2")
+	cacheDirPtr := flag.String("cachedir", "", "provides a directory where the current user, the list of projects and the project of each entry are cached between runs, to avoid requesting them on every run")
+	cacheTTLPtr := flag.Int("cachettl", 60, "specifies the number of minutes the responses cached in cachedir are used before being requested again")
+	stateFilePtr := flag.String("statefile", "", "provides the path to a file used to resume an interrupted run, entries completed before the interruption are not built again")
+	runTimeoutPtr := flag.Int("runtimeout", 0, "specifies the number of minutes the whole run can take before it is stopped, reporting the entries completed, in flight and not started (0 is unlimited)")
+	maxMinutesPtr := flag.Int("maxminutes", 0, "specifies the number of minutes of builds after which no more entries are built (0 is unlimited)")
+	timeoutRetriesPtr := flag.Int("timeoutretries", 0, "specifies the number of times a build is triggered again after exceeding the jobtimeout")
+	verifyRefsPtr := flag.Bool("verifyrefs", false, "verifies the branch, tag and commit of each GitHub entry exist before building, using GITHUB_TOKEN if it is set")
+	commitStatusPtr := flag.Bool("commitstatus", false, "posts the result of each GitHub entry as a commit status to the built commit, using GITHUB_TOKEN")
+	auditPtr := flag.Bool("audit", false, "reports the entries whose project is not followed and the followed projects without an entry, without building any entries")
+	preflightPtr := flag.Bool("preflight", false, "confirms the project of every entry exists and can be followed, without building any entries")
+	cancelPtr := flag.Bool("canceloninterrupt", false, "cancels the in-flight build when interrupted by SIGINT or SIGTERM")
+	strictEnvPtr := flag.Bool("strictenv", false, "fails if the build file references an environment variable that is not defined")
+	listProjectsPtr := flag.Bool("listprojects", false, "prints every project visible to CIRCLECI_TOKEN with its slug and whether it is followed, then exits without building")
+	outputPtr := flag.String("output", "table", "specifies the format printed by listprojects, either table or json")
+	printConfigPtr := flag.Bool("printconfig", false, "prints the resolved value and source of each setting, with access tokens redacted, then exits")
+	quietPtr := flag.Bool("quiet", false, "logs only failures and the results of the run, progress messages are discarded")
+	servePtr := flag.String("serve", "", "listens on the address, such as :8080, for GitHub push webhooks signed with GITHUB_WEBHOOK_SECRET and builds the entries matching each push")
+	jsonLogsPtr := flag.Bool("jsonlogs", false, "writes each log line to stderr as a JSON object with ts, level, project and msg properties")
 	flag.Parse()
 
+	if *jsonLogsPtr {
+		logOutput = newJSONLogger(os.Stderr, "info")
+		log.SetFlags(0)
+		log.SetOutput(logOutput)
+	}
+	if *quietPtr {
+		setQuietLogs()
+	}
+
 	if len(*buildFilePtr) == 0 {
 		flag.Usage()
 	}
 	if *jobTimeoutPtr < 0 {
 		log.Fatal("jobtimeout must be greater than zero")
 	}
+	if *retriesPtr < 1 {
+		log.Fatal("retries must be greater than zero")
+	}
+	if *retryIntervalPtr < 0 {
+		log.Fatal("retryinterval must be greater than or equal to zero")
+	}
+	if *requestTimeoutPtr < 0 {
+		log.Fatal("requesttimeout must be greater than or equal to zero")
+	}
+	if *pollIntervalPtr < 1 {
+		log.Fatal("pollinterval must be greater than zero")
+	}
+	if *maxMinutesPtr < 0 {
+		log.Fatal("maxminutes must be greater than or equal to zero")
+	}
+	if *runTimeoutPtr < 0 {
+		log.Fatal("runtimeout must be greater than or equal to zero")
+	}
+	if *timeoutRetriesPtr < 0 {
+		log.Fatal("timeoutretries must be greater than or equal to zero")
+	}
+	if *commitStatusPtr && len(os.Getenv("GITHUB_TOKEN")) == 0 {
+		log.Fatal("GITHUB_TOKEN environment variable must contain a GitHub access token when commitstatus is set")
+	}
+	if len(*servePtr) > 0 && len(os.Getenv("GITHUB_WEBHOOK_SECRET")) == 0 {
+		log.Fatal("GITHUB_WEBHOOK_SECRET environment variable must contain the secret of the GitHub webhook when serve is set")
+	}
+	if *queueTimeoutPtr < 0 {
+		log.Fatal("queuetimeout must be greater than or equal to zero")
+	}
+	if *cacheTTLPtr < 1 {
+		log.Fatal("cachettl must be greater than zero")
+	}
+	if *approvalTimeoutPtr < 0 {
+		log.Fatal("approvaltimeout must be greater than or equal to zero")
+	}
+	if *auditPtr && *herePtr {
+		log.Fatal("audit cannot be used with here, the build file is audited")
+	}
 
-	entries, err := parseEntries(*buildFilePtr)
+	waitStrategy, err := circleci.ParseWaitStrategy(*waitStrategyPtr)
 	if err != nil {
 		log.Fatal(err)
 	}
+	apiVersion, err := circleci.ParseAPIVersion(*apiVersionPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clientOpts := []circleci.Option{circleci.WithRetry(*retriesPtr, *retryIntervalPtr)}
+	if len(*buildActorPtr) > 0 {
+		clientOpts = append(clientOpts, circleci.WithBuildActor(*buildActorPtr))
+	}
+	if len(*cacheDirPtr) > 0 {
+		clientOpts = append(clientOpts, circleci.WithCache(*cacheDirPtr, time.Duration(*cacheTTLPtr)*time.Minute))
+	}
+	client := circleci.NewClient(nil, token, clientOpts...)
+	client.ApprovalTimeout = time.Duration(*approvalTimeoutPtr) * time.Minute
+	client.WaitStrategy = waitStrategy
+	client.APIVersion = apiVersion
+	client.ActiveJobTimeout = *activeTimeoutPtr
+	client.QueueTimeout = time.Duration(*queueTimeoutPtr) * time.Minute
+	client.PollInterval = time.Duration(*pollIntervalPtr) * time.Second
+	client.RequestTimeout = time.Duration(*requestTimeoutPtr) * time.Second
+	if *debugPtr {
+		client.DebugLogger = os.Stderr
+		if *jsonLogsPtr {
+			client.DebugLogger = newJSONLogger(os.Stderr, "debug")
+		}
+	}
+	if *printConfigPtr {
+		printConfig(os.Stdout, flag.CommandLine, client)
+		return
+	}
+	if *listProjectsPtr {
+		err = listProjects(os.Stdout, client, *outputPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var entries []*entry
+	if *herePtr {
+		var e *entry
+		e, err = localEntry(".")
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries = []*entry{e}
+	} else {
+		entries, err = parseEntries(*buildFilePtr, *strictEnvPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	client := circleci.NewClient(nil, token)
-	err = runBuilds(client, *jobTimeoutPtr, *skipDaysPtr, *noSkipPtr, entries)
+	opts := &options{
+		JobTimeout: *jobTimeoutPtr,
+		SkipDays:   *skipDaysPtr,
+		NoSkip:     *noSkipPtr,
+		FailOnSkip: *failOnSkipPtr,
+		SkipStatus: *skipStatusPtr,
+		NoFollow:   *noFollowPtr,
+		Summary:    *summaryPtr,
+		NoWait:     *noWaitPtr,
+
+		CancelOnInterrupt: *cancelPtr,
+		StateFile:         *stateFilePtr,
+		TimeoutRetries:    *timeoutRetriesPtr,
+		MaxMinutes:        *maxMinutesPtr,
+		DrainRunning:      *drainRunningPtr,
+		JUnit:             *junitPtr,
+		RunTimeout:        time.Duration(*runTimeoutPtr) * time.Minute,
+	}
+	if len(*cacheDirPtr) > 0 {
+		opts.ProjectCache = filepath.Join(*cacheDirPtr, projectCacheFile)
+	}
+	gh := newGitHubClient(os.Getenv("GITHUB_TOKEN"))
+	if *verifyRefsPtr {
+		opts.Refs = gh
+	}
+	if *commitStatusPtr {
+		opts.Statuses = gh
+	}
+	// only used by entries with a path filter
+	opts.Changes = gh
+	if *auditPtr {
+		err = audit(os.Stdout, client, entries)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Audit passed for %d entries\n", len(entries))
+		return
+	}
+	if *preflightPtr {
+		err = preflight(client, opts, entries)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Preflight passed for %d entries\n", len(entries))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Printf("received %s, stopping builds...\n", sig)
+		case <-ctx.Done():
+		}
+		// a second signal terminates immediately
+		signal.Stop(sigs)
+		cancel()
+	}()
+
+	if len(*servePtr) > 0 {
+		err = serve(ctx, *servePtr, os.Getenv("GITHUB_WEBHOOK_SECRET"), client, opts, entries)
+	} else {
+		err = runBuilds(ctx, client, opts, entries)
+	}
+	// cancel before exiting, log.Fatal does not run deferred calls
+	cancel()
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// envInt ... returns the integer value of the environment variable name, used
+// as the default of a flag, or def if the variable is not set
+func envInt(name string, def int) int {
+	value, ok := os.LookupEnv(name)
+	if !ok || len(value) == 0 {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s environment variable must contain an integer, got: %q", name, value)
+	}
+	return i
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvInt(t *testing.T) {
+	tt := map[string]struct {
+		value    *string
+		expected int
+	}{
+		"unset": {expected: 20},
+		"empty": {value: strPtr(""), expected: 20},
+		"set":   {value: strPtr("5"), expected: 5},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			os.Unsetenv("TEST_ENV_INT")
+			if tc.value != nil {
+				os.Setenv("TEST_ENV_INT", *tc.value)
+				defer os.Unsetenv("TEST_ENV_INT")
+			}
+			actual := envInt("TEST_ENV_INT", 20)
+			if actual != tc.expected {
+				t.Errorf("envInt() failed. Expected: %d, got: %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
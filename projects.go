@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// output formats of listProjects
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// projectListing ... used internally to represent a project listed by listProjects
+type projectListing struct {
+	Slug     string `json:"slug"`
+	Org      string `json:"org"`
+	Repo     string `json:"repo"`
+	Vcs      string `json:"vcs"`
+	URL      string `json:"url"`
+	Followed bool   `json:"followed"`
+}
+
+// listProjects ... writes every project visible to the access key to w, sorted
+// by slug, as an aligned table, or a JSON array when output is json
+func listProjects(w io.Writer, client circleci.API, output string) error {
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unknown output: %q, must be one of table or json", output)
+	}
+	projects, err := client.Projects(logOutput)
+	if err != nil {
+		return fmt.Errorf("failed to list projects -> %v", err)
+	}
+	listings := make([]*projectListing, 0, len(projects))
+	for _, p := range projects {
+		listings = append(listings, &projectListing{
+			Slug:     p.Slug(),
+			Org:      p.Username,
+			Repo:     p.Reponame,
+			Vcs:      p.VCS().V1(),
+			URL:      p.VcsURL,
+			Followed: p.Followed,
+		})
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		return listings[i].Slug < listings[j].Slug
+	})
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SLUG\tORG\tREPO\tVCS\tFOLLOWED")
+	for _, l := range listings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", l.Slug, l.Org, l.Repo, l.Vcs, l.Followed)
+	}
+	return tw.Flush()
+}
+
+// audit ... cross-references the entries with the projects visible to the access
+// key, writing the entries whose project is not visible or not followed, and the
+// followed projects without an entry to w, returns an error if any were found,
+// the projects are always requested so that a cached list is never audited
+func audit(w io.Writer, client circleci.API, entries []*entry) error {
+	projects, err := client.RefreshProjects(logOutput)
+	if err != nil {
+		return fmt.Errorf("failed to list projects -> %v", err)
+	}
+	// projects are matched by url, as resolveProject does
+	visible := make(map[string]*circleci.Project)
+	for _, p := range projects {
+		visible[p.VcsURL] = p
+	}
+	referenced := make(map[string]bool)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var drifted int
+	for _, e := range entries {
+		if len(e.URL) == 0 || len(e.Name) == 0 {
+			continue
+		}
+		problem := ""
+		p, err := circleci.ProjectFromURL(e.URL)
+		switch {
+		case err != nil:
+			problem = "invalid repository"
+		case visible[p.VcsURL] == nil:
+			problem = "not visible"
+		case !visible[p.VcsURL].Followed:
+			problem = "not followed"
+		}
+		if err == nil {
+			referenced[p.VcsURL] = true
+		}
+		if len(problem) == 0 {
+			continue
+		}
+		if drifted == 0 {
+			fmt.Fprintln(tw, "ENTRY\tREPOSITORY\tPROBLEM")
+		}
+		drifted++
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Name, e.URL, problem)
+	}
+	var missing []string
+	for _, p := range projects {
+		if p.Followed && !referenced[p.VcsURL] {
+			missing = append(missing, p.Slug())
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		if drifted > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintln(tw, "FOLLOWED PROJECT WITHOUT AN ENTRY")
+		fmt.Fprintln(tw, strings.Join(missing, "\n"))
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+	if drifted > 0 || len(missing) > 0 {
+		return fmt.Errorf("audit found %d entries without a followed project and %d followed projects without an entry", drifted, len(missing))
+	}
+	return nil
+}
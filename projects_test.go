@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+type projectsClient struct {
+	circleci.API
+}
+
+func (p projectsClient) Projects(w io.Writer) ([]*circleci.Project, error) {
+	return []*circleci.Project{
+		{Username: "org", Reponame: "test2", Vcs: "bitbucket", VcsURL: "https://bitbucket.org/org/test2"},
+		{Username: "org", Reponame: "test1", Vcs: "github", VcsURL: "https://github.com/org/test1", Followed: true},
+		{Username: "org", Reponame: "test4", Vcs: "github", VcsURL: "https://github.com/org/test4", Followed: true},
+	}, nil
+}
+
+// auditClient ... fails if the cached projects are used
+type auditClient struct {
+	projectsClient
+}
+
+func (a auditClient) Projects(w io.Writer) ([]*circleci.Project, error) {
+	return nil, fmt.Errorf("cached projects used")
+}
+
+func (a auditClient) RefreshProjects(w io.Writer) ([]*circleci.Project, error) {
+	return a.projectsClient.Projects(w)
+}
+
+func TestListProjects(t *testing.T) {
+	tt := map[string]struct {
+		output      string
+		expected    string
+		expectedErr string
+	}{
+		"table": {
+			output: outputTable,
+			expected: `SLUG          ORG  REPO   VCS        FOLLOWED
+bb/org/test2  org  test2  bitbucket  false
+gh/org/test1  org  test1  github     true
+gh/org/test4  org  test4  github     true
+`,
+		},
+		"json": {
+			output: outputJSON,
+			expected: `[
+  {
+    "slug": "bb/org/test2",
+    "org": "org",
+    "repo": "test2",
+    "vcs": "bitbucket",
+    "url": "https://bitbucket.org/org/test2",
+    "followed": false
+  },
+  {
+    "slug": "gh/org/test1",
+    "org": "org",
+    "repo": "test1",
+    "vcs": "github",
+    "url": "https://github.com/org/test1",
+    "followed": true
+  },
+  {
+    "slug": "gh/org/test4",
+    "org": "org",
+    "repo": "test4",
+    "vcs": "github",
+    "url": "https://github.com/org/test4",
+    "followed": true
+  }
+]
+`,
+		},
+		"unknown output": {
+			output:      "yaml",
+			expectedErr: `unknown output: "yaml", must be one of table or json`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := listProjects(&buf, projectsClient{}, tc.output)
+			if len(tc.expectedErr) > 0 {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("listProjects() failed: expected error: %s, got: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("listProjects() failed: %v", err)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("listProjects() failed: Expected:\n%s\nGot:\n%s", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	tt := map[string]struct {
+		entries     []*entry
+		expected    string
+		expectedErr string
+	}{
+		"in sync": {
+			entries: []*entry{
+				{Name: "test1", URL: "https://github.com/org/test1"},
+				{Name: "test4", URL: "https://github.com/org/test4"},
+			},
+		},
+		"drift": {
+			entries: []*entry{
+				{Name: "test1", URL: "https://github.com/org/test1"},
+				{Name: "test2", URL: "https://bitbucket.org/org/test2"},
+				{Name: "test3", URL: "https://github.com/org/test3"},
+				{},
+			},
+			expected: `ENTRY  REPOSITORY                       PROBLEM
+test2  https://bitbucket.org/org/test2  not followed
+test3  https://github.com/org/test3     not visible
+
+FOLLOWED PROJECT WITHOUT AN ENTRY
+gh/org/test4
+`,
+			expectedErr: "audit found 2 entries without a followed project and 1 followed projects without an entry",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := audit(&buf, auditClient{}, tc.entries)
+			if len(tc.expectedErr) > 0 {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("audit() failed: expected error: %s, got: %v", tc.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("audit() failed: %v", err)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("audit() failed: Expected:\n%s\nGot:\n%s", tc.expected, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+// ref ... used internally to represent a branch, tag or commit being verified
+type ref struct {
+	kind string
+	name string
+	path string
+}
+
+// verify ... returns an error if the branch, tag or commit of input does not
+// exist in the project, projects not hosted on GitHub are not verified
+func (v *githubClient) verify(project *circleci.Project, input *circleci.BuildProjectInput) error {
+	if project.VCS() != circleci.VCSGitHub {
+		infof("Not verifying refs of project %q, only GitHub projects can be verified\n", project.Reponame)
+		return nil
+	}
+	var refs []ref
+	if len(input.Tag) > 0 {
+		refs = append(refs, ref{kind: "tag", name: input.Tag, path: "git/ref/tags/" + url.PathEscape(input.Tag)})
+	}
+	if len(input.Branch) > 0 {
+		refs = append(refs, ref{kind: "branch", name: input.Branch, path: "git/ref/heads/" + url.PathEscape(input.Branch)})
+	}
+	if len(input.Revision) > 0 {
+		refs = append(refs, ref{kind: "commit", name: input.Revision, path: "commits/" + url.PathEscape(input.Revision)})
+	}
+	for _, r := range refs {
+		found, err := v.exists(fmt.Sprintf("%s/repos/%s/%s/%s", v.baseURL, project.Username, project.Reponame, r.path))
+		if err != nil {
+			return fmt.Errorf("failed to verify %s %q of project %s -> %v", r.kind, r.name, project.Reponame, err)
+		}
+		if !found {
+			if len(v.token) == 0 {
+				return fmt.Errorf("%s %q not found in project %s, private repositories require GITHUB_TOKEN", r.kind, r.name, project.Reponame)
+			}
+			return fmt.Errorf("%s %q not found in project %s", r.kind, r.name, project.Reponame)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+func TestRefVerifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/repos/org/test1/git/ref/tags/v0.1", "/repos/org/test1/git/ref/heads/feature%2Fx", "/repos/org/test1/commits/d8cbe5e":
+			_, _ = w.Write([]byte(`{}`))
+		case "/repos/org/test1/commits/bad":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case "/repos/org/test1/git/ref/heads/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	v := newGitHubClient("token")
+	v.baseURL = srv.URL
+	project := &circleci.Project{Username: "org", Reponame: "test1", Vcs: "github"}
+	tt := map[string]struct {
+		input    circleci.BuildProjectInput
+		vcs      string
+		expected string
+	}{
+		"tag":             {input: circleci.BuildProjectInput{Tag: "v0.1"}},
+		"branch and sha":  {input: circleci.BuildProjectInput{Branch: "feature/x", Revision: "d8cbe5e"}},
+		"missing tag":     {input: circleci.BuildProjectInput{Tag: "v9.9.9"}, expected: `tag "v9.9.9" not found in project test1`},
+		"missing commit":  {input: circleci.BuildProjectInput{Branch: "feature/x", Revision: "bad"}, expected: `commit "bad" not found in project test1`},
+		"request failure": {input: circleci.BuildProjectInput{Branch: "error"}, expected: `failed to verify branch "error" of project test1 -> non-success status code returned 500 Internal Server Error`},
+		"default branch":  {},
+		"not github":      {input: circleci.BuildProjectInput{Tag: "v9.9.9"}, vcs: "bitbucket"},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			p := *project
+			if len(tc.vcs) > 0 {
+				p.Vcs = tc.vcs
+			}
+			err := v.verify(&p, &tc.input)
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Errorf("verify() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("verify() failed: Expected: %q\nGot: %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	statusSuccess     = "success"
+	statusFailed      = "failed"
+	statusSkipped     = "skipped"
+	statusTriggered   = "triggered"
+	statusInterrupted = "interrupted"
+	statusCanceled    = "canceled"
+	statusBudget      = "over budget"
+	statusTimedOut    = "timed out"
+)
+
+// result ... contains the outcome of processing a single entry
+type result struct {
+	//entry name
+	Name string
+	//circleci project name
+	Project string
+	//success, failed, skipped, triggered, interrupted, canceled, over budget or timed out
+	Status string
+	//number of the first build job that was started
+	BuildNum int
+	//wall-clock time spent building the entry
+	Duration time.Duration
+	//true if the entry was skipped
+	Skipped bool
+	//error text of an entry that failed to build
+	Err string
+}
+
+// printSummary ... writes an aligned table of the results to w
+func printSummary(w io.Writer, results []*result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tSTATUS\tBUILD #\tDURATION\tSKIPPED")
+	for _, r := range results {
+		buildNum := "-"
+		if r.BuildNum > 0 {
+			buildNum = strconv.Itoa(r.BuildNum)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", r.Project, r.Status, buildNum, r.Duration.Round(time.Second), r.Skipped)
+	}
+	err := tw.Flush()
+	if err != nil {
+		log.Printf("failed to print summary -> %v\n", err)
+	}
+}
+
+// reportTimeout ... logs the entries completed, in flight and not
+// started when the run was stopped by the runtimeout
+func reportTimeout(timeout time.Duration, results []*result, notStarted []string) {
+	var completed, inFlight []string
+	for _, r := range results {
+		if r.Status == statusTimedOut || r.Status == statusInterrupted || len(r.Status) == 0 {
+			inFlight = append(inFlight, r.Name)
+			continue
+		}
+		completed = append(completed, r.Name)
+	}
+	log.Printf("WARNING: the run exceeded the runtimeout of %s, completed: [%s], in flight: [%s], not started: [%s]\n",
+		timeout, strings.Join(completed, ", "), strings.Join(inFlight, ", "), strings.Join(notStarted, ", "))
+}
+
+// junitTestSuite ... used internally to represent the run as a JUnit XML test suite
+type junitTestSuite struct {
+	XMLName   xml.Name         `xml:"testsuite"`
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase ... used internally to represent an entry as a JUnit XML test case
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+// junitMessage ... used internally to represent a JUnit XML failure or skipped element
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSeconds ... formats d as the seconds of a JUnit XML time attribute
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// writeJUnit ... writes the results to w as a JUnit XML test suite, each entry
+// is a test case, skipped entries are skipped and failed entries are failures
+func writeJUnit(w io.Writer, results []*result, duration time.Duration) error {
+	suite := &junitTestSuite{
+		Name:  "grace-circleci-builder",
+		Tests: len(results),
+		Time:  junitSeconds(duration),
+	}
+	for _, r := range results {
+		tc := &junitTestCase{Name: r.Name, ClassName: r.Project, Time: junitSeconds(r.Duration)}
+		switch {
+		case r.Skipped:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: r.Status}
+		case r.Status == statusFailed || r.Status == statusCanceled || r.Status == statusInterrupted || r.Status == statusTimedOut:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: r.Status, Text: r.Err}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(suite)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// saveJUnit ... writes the results as a JUnit XML test suite to the file at path
+func saveJUnit(path string, results []*result, duration time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeJUnit(f, results, duration)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
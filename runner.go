@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/GSA/grace-circleci-builder/circleci"
@@ -18,6 +22,8 @@ type entry struct {
 	Name string `json:"name"`
 	//version control system url
 	URL string `json:"repository"`
+	//circleci project slug in the format vcs/org/repo, an alternative to repository
+	Slug string `json:"slug"`
 	//version control system branch to build
 	Branch string `json:"branch"`
 	//version control system tag to build (cannot be used with branch or commit)
@@ -26,100 +32,713 @@ type entry struct {
 	Commit string `json:"commit"`
 	//skip on failure
 	ContinueOnFail bool `json:"continue_on_fail"`
+	//pipeline parameters (triggers the build using the CircleCI API v2)
+	Parameters map[string]interface{} `json:"parameters"`
+	//always build, ignoring previous builds and the skip settings
+	ForceBuild bool `json:"force_build"`
+	//names of approval jobs to approve automatically
+	AutoApproveJobs []string `json:"auto_approve_jobs"`
+	//builds a forked pull request, accepting builds attributed to the fork author
+	Fork bool `json:"fork"`
+	//version control system type, overrides the type derived from the repository url
+	Vcs string `json:"vcs"`
+	//names of workflows to run, each is sent as a boolean pipeline parameter
+	Workflows []string `json:"workflows"`
+	//name of the pipeline parameter for each workflow, %s is replaced by the workflow name
+	WorkflowParameter string `json:"workflow_parameter"`
+	//number of times to trigger the build again after timing out, overrides the timeoutretries flag
+	TimeoutRetries *int `json:"timeout_retries"`
+	//number of times to trigger the build again after timing out or an infrastructure failure
+	RetryCount int `json:"retry_count"`
+	//number of seconds to wait before triggering the build again
+	RetryBackoffSeconds int `json:"retry_backoff_seconds"`
+	//glob patterns of paths, the entry is skipped if no matching files changed since the last build
+	PathFilter []string `json:"path_filter"`
+	//number of a GitHub pull request, the merge of the pull request into its base is built
+	PullRequestMerge int `json:"pull_request_merge"`
+	//number of times only the failed jobs of the workflow are rerun when it fails, for flaky entries
+	RerunFailed int `json:"rerun_failed"`
+	//version control system commits to build in order, each is built and skipped independently
+	Commits []string `json:"commits"`
 }
 
-func (e *entry) Build(client circleci.API, logger io.Writer, project *circleci.Project, input *circleci.BuildProjectInput, jobTimeout int) error {
-	summary, err := client.BuildProject(project, logger, &circleci.BuildProjectInput{
-		Branch:   e.Branch,
-		Revision: e.Commit,
-		Tag:      e.Tag,
-	}, time.Minute)
+// shortCommitLength ... the length of the abbreviated commit included in
+// the name of each entry expanded from the commits of an entry
+const shortCommitLength = 7
+
+// expandCommits ... replaces each entry that has commits with an entry per commit,
+// in order, named after the entry and the abbreviated commit, so that each commit
+// is skipped, built and reported independently
+func expandCommits(entries []*entry) ([]*entry, error) {
+	var expanded []*entry
+	for _, e := range entries {
+		if len(e.Commits) == 0 {
+			expanded = append(expanded, e)
+			continue
+		}
+		if len(e.Commit) > 0 || len(e.Tag) > 0 || e.PullRequestMerge > 0 {
+			return nil, fmt.Errorf("commits cannot be used with commit, tag or pull_request_merge for entry: %s", e.Name)
+		}
+		for _, commit := range e.Commits {
+			c := *e
+			c.Commit, c.Commits = commit, nil
+			short := commit
+			if len(short) > shortCommitLength {
+				short = short[:shortCommitLength]
+			}
+			c.Name = fmt.Sprintf("%s@%s", e.Name, short)
+			expanded = append(expanded, &c)
+		}
+	}
+	return expanded, nil
+}
+
+// defaultWorkflowParameter ... the pipeline parameter for each workflow
+// when the entry does not provide a workflow_parameter
+const defaultWorkflowParameter = "run_%s"
+
+// parameters ... returns the pipeline parameters of the entry, each name in
+// Workflows is added as a boolean parameter set to true, the parameter name is
+// derived from WorkflowParameter, parameters given explicitly take precedence
+func (e *entry) parameters() (map[string]interface{}, error) {
+	if len(e.Workflows) == 0 {
+		return e.Parameters, nil
+	}
+	format := e.WorkflowParameter
+	if len(format) == 0 {
+		format = defaultWorkflowParameter
+	}
+	if strings.Count(format, "%s") != 1 {
+		return nil, fmt.Errorf("workflow_parameter must contain %%s exactly once: %q", format)
+	}
+	params := make(map[string]interface{}, len(e.Parameters)+len(e.Workflows))
+	for _, w := range e.Workflows {
+		params[strings.Replace(format, "%s", w, 1)] = true
+	}
+	for k, v := range e.Parameters {
+		params[k] = v
+	}
+	return params, nil
+}
+
+// Build ... triggers a build of the entry and waits for it to complete,
+// returns the summary of the first build job that was started, if ctx is
+// canceled while waiting, the build is abandoned and optionally canceled
+func (e *entry) Build(ctx context.Context, client circleci.API, logger io.Writer, project *circleci.Project, input *circleci.BuildProjectInput, opts *options) (*circleci.BuildSummaryOutput, error) {
+	backoff := time.Duration(e.RetryBackoffSeconds) * time.Second
+	for attempt := 0; ; attempt++ {
+		summary, err := e.build(ctx, client, logger, project, input, opts)
+		retries := e.retries(opts, err)
+		if err == nil || attempt >= retries || ctx.Err() != nil {
+			return summary, err
+		}
+		log.Printf("Build %d of project %q failed, canceling and triggering again in %s (retry %d of %d) -> %v\n",
+			summary.BuildNum, project.Reponame, backoff, attempt+1, retries, err)
+		cancelBuild(client, logger, project, summary)
+		select {
+		case <-ctx.Done():
+			return summary, err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// maxEntryRetries ... the upper limit of retries for an entry
+const maxEntryRetries = 5
+
+// retries ... returns the number of times the entry is triggered again after
+// failing with err, only timeouts and infrastructure failures are retried,
+// when RetryCount is not set, only timeouts are retried using TimeoutRetries
+// of the entry or the options, in that order
+func (e *entry) retries(opts *options, err error) int {
+	var retries int
+	switch err.(type) {
+	case *circleci.JobTimeoutError:
+		retries = opts.TimeoutRetries
+		if e.TimeoutRetries != nil {
+			retries = *e.TimeoutRetries
+		}
+		if e.RetryCount > 0 {
+			retries = e.RetryCount
+		}
+	case *circleci.InfrastructureFailError:
+		retries = e.RetryCount
+	}
+	if retries < 0 {
+		return 0
+	}
+	if retries > maxEntryRetries {
+		return maxEntryRetries
+	}
+	return retries
+}
+
+// build ... triggers a single build of the entry and waits for it to complete,
+// the project has already been resolved, so it is built without following it
+// again, unless it was resolved by a previous run and is no longer followed
+func (e *entry) build(ctx context.Context, client circleci.API, logger io.Writer, project *circleci.Project, input *circleci.BuildProjectInput, opts *options) (*circleci.BuildSummaryOutput, error) {
+	summary, err := client.BuildKnownProject(project, logger, input, time.Minute)
+	if _, ok := err.(*circleci.ProjectNotFollowedError); ok && !opts.NoFollow {
+		infof("Following project %q again, it was not found -> %v\n", project.Reponame, err)
+		err = client.FollowProject(project, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to follow project with URL: %s -> %v", project.VcsURL, err)
+		}
+		summary, err = client.BuildProject(project, logger, input, time.Minute)
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+	err = client.WaitForProjectBuild(project, logger, input, summary, time.Duration(opts.JobTimeout)*time.Minute, time.Minute, e.ContinueOnFail)
+	if err != nil && ctx.Err() != nil && opts.CancelOnInterrupt {
+		// the client stops once ctx is done, so cancel using one that does not
+		cancelBuild(client.WithContext(context.Background()), logger, project, summary)
+	}
+	return summary, err
+}
+
+// cancelBuild ... cancels the workflow of the given build, stopping all of its
+// jobs at once, if the build has no workflow only the build is canceled,
+// failures are logged since the build may have already stopped
+func cancelBuild(client circleci.API, logger io.Writer, project *circleci.Project, summary *circleci.BuildSummaryOutput) {
+	if summary.Workflow != nil && len(summary.Workflow.WorkflowID) > 0 {
+		infof("Canceling workflow %s of project %q\n", summary.Workflow.WorkflowID, project.Reponame)
+		err := client.CancelWorkflow(summary.Workflow.WorkflowID, logger)
+		if err == nil {
+			return
+		}
+		log.Printf("failed to cancel workflow %s of project %q, canceling build %d -> %v\n", summary.Workflow.WorkflowID, project.Reponame, summary.BuildNum, err)
+	}
+	infof("Canceling build %d of project %q\n", summary.BuildNum, project.Reponame)
+	_, err := client.CancelBuild(project, logger, summary.BuildNum)
+	if err != nil {
+		log.Printf("failed to cancel build %d of project %q -> %v\n", summary.BuildNum, project.Reponame, err)
 	}
-	return client.WaitForProjectBuild(project, logger, input, summary, time.Duration(jobTimeout)*time.Minute, time.Minute, e.ContinueOnFail)
 }
 
+// options ... contains the settings used when running builds
+type options struct {
+	//number of minutes that a build job can take before timing out
+	JobTimeout int
+	//number of days to consider a previous build relevant for skipping
+	SkipDays int
+	//prevents skipping of previously built entries
+	NoSkip bool
+	//workflow status of previous builds relevant for skipping, or any
+	SkipStatus string
+	//prevents following projects, unfollowed projects will fail the run
+	NoFollow bool
+	//prints a summary table of the results after all builds complete
+	Summary bool
+	//triggers builds without waiting for them to complete
+	NoWait bool
+	//cancels the in-flight build when the run is interrupted
+	CancelOnInterrupt bool
+	//path to the file used to resume an interrupted run
+	StateFile string
+	//number of times to trigger a build again after timing out
+	TimeoutRetries int
+	//if set, the branch, tag and commit of each entry are verified before building
+	Refs *githubClient
+	//if set, the result of each build is posted as a GitHub commit status
+	Statuses *githubClient
+	//if set, entries with a path filter are skipped when no matching files changed
+	Changes *githubClient
+	//number of minutes of builds, as reported by CircleCI, after which no more entries are built, 0 is unlimited
+	MaxMinutes int
+	//fails the run after all entries are processed if any entry was skipped
+	FailOnSkip bool
+	//waits for builds of the project that are already running before building
+	DrainRunning bool
+	//path of the file the results are written to as JUnit XML
+	JUnit string
+	//path of the file the resolved projects are cached in between runs
+	ProjectCache string
+	//wall-clock time the whole run can take before it is stopped, 0 is unlimited
+	RunTimeout time.Duration
+}
+
+const (
+	// defaultSkipStatus ... the workflow status of previous builds relevant for skipping
+	defaultSkipStatus = "success"
+	// skipStatusAny ... previous builds are relevant for skipping regardless of status
+	skipStatusAny = "any"
+)
+
 //nolint: gocyclo
-func runBuilds(client circleci.API, jobTimeout int, skipDays int, noSkip bool, entries []*entry) error {
+func runBuilds(ctx context.Context, client circleci.API, opts *options, entries []*entry) error {
+	entries, err := expandCommits(entries)
+	if err != nil {
+		return err
+	}
+	state, err := loadState(opts.StateFile)
+	if err != nil {
+		return err
+	}
+	// parent is only stopped when the run is interrupted
+	parent := ctx
+	if opts.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.RunTimeout)
+		defer cancel()
+	}
+	// requests and waiting stop once the run is interrupted or times out
+	client = client.WithContext(ctx)
+	var (
+		results    []*result
+		consumed   time.Duration
+		overBudget []string
+		skipped    []string
+	)
+	projects := loadProjectCache(opts.ProjectCache)
+	runStart := time.Now()
+	defer func() {
+		setLogProject("")
+		err := projects.save(opts.ProjectCache)
+		if err != nil {
+			log.Printf("failed to save the project cache to %s -> %v\n", opts.ProjectCache, err)
+		}
+		log.Printf("Run completed in %s, %d entries processed\n", time.Since(runStart).Round(time.Second), len(results))
+		// always summarize what was launched when interrupted
+		if opts.Summary || ctx.Err() != nil {
+			printSummary(os.Stdout, results)
+		}
+		if timedOut(parent, ctx) {
+			reportTimeout(opts.RunTimeout, results, notStarted(entries, results, state))
+		}
+		if len(opts.JUnit) > 0 {
+			err := saveJUnit(opts.JUnit, results, time.Since(runStart))
+			if err != nil {
+				log.Printf("failed to write JUnit XML to %s -> %v\n", opts.JUnit, err)
+			}
+		}
+	}()
 	// loop over circleci project entries, resolving each project
 	// and executing a full build, if anything fails, return
 	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s before building entry: %s -> %v", stopReason(parent, ctx), entry.Name, ctx.Err())
+		}
 		if len(entry.URL) == 0 || len(entry.Name) == 0 {
-			log.Printf("skipping blank entry...\n")
+			infof("skipping blank entry...\n")
 			continue
 		}
-		p, err := circleci.ProjectFromURL(entry.URL)
+		if state.completed(entry) {
+			infof("Skipping entry %q, it was completed before the run was interrupted\n", entry.Name)
+			continue
+		}
+		entry := entry // pin!
+		setLogProject(entry.Name)
+		project, err := projects.resolve(client, opts, entry)
 		if err != nil {
 			return err
 		}
-		log.Printf("Following project with url: %s\n", entry.URL)
-		err = client.FollowProject(p, os.Stdout)
+		params, err := entry.parameters()
 		if err != nil {
-			return fmt.Errorf("failed to follow project with URL: %s -> %v", entry.URL, err)
+			return fmt.Errorf("invalid parameters for entry: %s -> %v", entry.Name, err)
 		}
+		input := &circleci.BuildProjectInput{
+			Branch:     entry.Branch,
+			Revision:   entry.Commit,
+			Tag:        entry.Tag,
+			Parameters: params,
 
-		log.Printf("Searching for project with url: %s\n", entry.URL)
-		entry := entry // pin!
-		project, err := client.FindProject(os.Stdout, func(p *circleci.Project) bool {
-			return p.VcsURL == entry.URL
-		})
-		if err != nil {
-			return err
+			AutoApproveJobs:  entry.AutoApproveJobs,
+			Fork:             entry.Fork,
+			PullRequestMerge: entry.PullRequestMerge,
+			RerunFailedJobs:  entry.RerunFailed,
 		}
-		input := &circleci.BuildProjectInput{
-			Branch:   entry.Branch,
-			Revision: entry.Commit,
-			Tag:      entry.Tag,
+		if len(input.Branch) == 0 && len(input.Tag) == 0 && len(input.Revision) == 0 && input.PullRequestMerge == 0 {
+			input.Branch = defaultBranch(client, project)
+		}
+		if opts.Refs != nil {
+			err = opts.Refs.verify(project, input)
+			if err != nil {
+				return err
+			}
 		}
-		if !noSkip {
+		res := &result{Name: entry.Name, Project: project.Reponame}
+		results = append(results, res)
+		if entry.ForceBuild {
+			infof("Forcing build of project %q, skipping is disabled for this entry\n", project.Reponame)
+		} else if !opts.NoSkip {
 			var skip bool
-			log.Printf("Searching for builds in project %q, matching %s within %d days to skip\n", project.Reponame, input, skipDays)
-			skip, err = shouldSkip(client, project, input, skipDays)
+			infof("Searching for builds in project %q, matching %s within %d days to skip\n", project.Reponame, input, opts.SkipDays)
+			skip, err = shouldSkip(client, project, input, opts.SkipDays, opts.SkipStatus)
 			if err != nil {
 				return fmt.Errorf("failed to query information about previous project builds for project %s -> %v", project.Reponame, err)
 			}
 			if skip {
-				log.Printf("Skipping project %q, a previous build was found within %d days for %s\n", project.Reponame, skipDays, input)
+				infof("Skipping project %q, a previous build was found within %d days for %s\n", project.Reponame, opts.SkipDays, input)
+				res.Status, res.Skipped = statusSkipped, true
+				skipped = append(skipped, entry.Name)
+				err = state.complete(entry)
+				if err != nil {
+					return fmt.Errorf("failed to save state file: %s -> %v", opts.StateFile, err)
+				}
 				continue
 			}
+			if len(entry.PathFilter) > 0 && opts.Changes != nil {
+				skip, err = unchangedPaths(client, opts.Changes, project, input, entry, opts.SkipStatus)
+				if err != nil {
+					return fmt.Errorf("failed to find the files changed in project %s -> %v", project.Reponame, err)
+				}
+				if skip {
+					infof("Skipping project %q, no files matching %v changed since the last build of %s\n", project.Reponame, entry.PathFilter, input)
+					res.Status, res.Skipped = statusSkipped, true
+					skipped = append(skipped, entry.Name)
+					err = state.complete(entry)
+					if err != nil {
+						return fmt.Errorf("failed to save state file: %s -> %v", opts.StateFile, err)
+					}
+					continue
+				}
+			}
+		}
+		if opts.MaxMinutes > 0 && consumed >= time.Duration(opts.MaxMinutes)*time.Minute {
+			infof("Skipping project %q, %s of builds exceeds the budget of %d minutes\n", project.Reponame, consumed.Round(time.Second), opts.MaxMinutes)
+			res.Status, res.Skipped = statusBudget, true
+			overBudget = append(overBudget, entry.Name)
+			continue
+		}
+		if opts.DrainRunning {
+			err = waitForRunning(ctx, client, project, time.Duration(opts.JobTimeout)*time.Minute)
+			if err != nil {
+				res.Status, res.Err = statusFailed, err.Error()
+				return fmt.Errorf("failed waiting for running builds of project: %s -> %v", project.Reponame, err)
+			}
+		}
+		if opts.NoWait {
+			infof("Triggering project %q\n", project.Reponame)
+			pipeline, err := client.TriggerOnly(project, logOutput, input)
+			if err != nil {
+				res.Status, res.Err = statusFailed, err.Error()
+				return fmt.Errorf("failed to trigger project: %s -> %v", project.Reponame, err)
+			}
+			res.Status = statusTriggered
+			infof("Triggering project %q, started pipeline %d\n", project.Reponame, pipeline.Number)
+			err = state.complete(entry)
+			if err != nil {
+				return fmt.Errorf("failed to save state file: %s -> %v", opts.StateFile, err)
+			}
+			continue
+		}
+		infof("Building project %q\n", project.Reponame)
+		start := time.Now()
+		summary, err := entry.Build(ctx, client, logOutput, project, input, opts)
+		res.Duration = time.Since(start)
+		if opts.MaxMinutes > 0 {
+			consumed += buildDuration(client, project, summary, res.Duration)
+		}
+		if summary != nil {
+			res.BuildNum = summary.BuildNum
+		}
+		if opts.Statuses != nil {
+			reportStatus(opts.Statuses, project, entry, summary, err)
 		}
-		log.Printf("Building project %q\n", project.Reponame)
-		err = entry.Build(client, os.Stdout, project, input, jobTimeout)
 		if err != nil {
+			log.Printf("Building project %q, failed after %s\n", project.Reponame, res.Duration.Round(time.Second))
+			res.Err = err.Error()
+			if ctx.Err() != nil {
+				res.Status = statusInterrupted
+				if timedOut(parent, ctx) {
+					res.Status = statusTimedOut
+				}
+				return fmt.Errorf("%s while building project: %s -> %v", stopReason(parent, ctx), project.Reponame, err)
+			}
+			res.Status = statusFailed
+			if _, ok := err.(*circleci.BuildCanceledError); ok {
+				res.Status = statusCanceled
+			}
 			return fmt.Errorf("failed to build project: %s -> %v", project.Reponame, err)
 		}
-		log.Printf("Building project %q, completed successfully\n", project.Reponame)
+		res.Status = statusSuccess
+		infof("Building project %q, completed successfully in %s\n", project.Reponame, res.Duration.Round(time.Second))
+		err = state.complete(entry)
+		if err != nil {
+			return fmt.Errorf("failed to save state file: %s -> %v", opts.StateFile, err)
+		}
 	}
-	return nil
+	if opts.FailOnSkip && len(skipped) > 0 {
+		return fmt.Errorf("%d entries were skipped and failonskip is set, use noskip to build them: %s",
+			len(skipped), strings.Join(skipped, ", "))
+	}
+	if len(overBudget) > 0 {
+		// entries skipped for budget are built when the run is resumed
+		log.Printf("WARNING: %d entries were not built, the budget of %d minutes was exceeded: %s\n",
+			len(overBudget), opts.MaxMinutes, strings.Join(overBudget, ", "))
+		return nil
+	}
+	// the run completed, so the next run starts from the beginning
+	return state.clear()
 }
 
-func shouldSkip(client circleci.API, project *circleci.Project, input *circleci.BuildProjectInput, skipDays int) (bool, error) {
-	// this may need to be optimized to accept an 'after' date
-	// so we can stop iterating over old/stale job data
-	rawBuilds, err := client.FindBuildSummaries(project, os.Stdout, input)
+// buildDuration ... returns the time the builds of the workflow of summary spent
+// running, as reported by CircleCI, so time spent queued or waiting on approvals
+// is not counted against the budget, elapsed is returned if the durations
+// cannot be requested, and zero if no build was triggered
+func buildDuration(client circleci.API, project *circleci.Project, summary *circleci.BuildSummaryOutput, elapsed time.Duration) time.Duration {
+	if summary == nil {
+		return 0
+	}
+	if summary.Workflow != nil && len(summary.Workflow.WorkflowID) > 0 {
+		summaries, err := client.BuildSummary(project, logOutput, nil)
+		if err != nil {
+			log.Printf("failed to get the build durations of project %q, counting %s against the budget -> %v\n", project.Reponame, elapsed.Round(time.Second), err)
+			return elapsed
+		}
+		var d time.Duration
+		for _, s := range summaries {
+			if s.Workflow != nil && s.Workflow.WorkflowID == summary.Workflow.WorkflowID {
+				d += s.RunDuration()
+			}
+		}
+		return d
+	}
+	s, err := client.GetBuildSummary(project, logOutput, summary.BuildNum)
 	if err != nil {
-		return false, err
+		log.Printf("failed to get the duration of build %d of project %q, counting %s against the budget -> %v\n", summary.BuildNum, project.Reponame, elapsed.Round(time.Second), err)
+		return elapsed
 	}
-	filteredBuilds := circleci.FilterBuildSummariesByWorkflowStatus(rawBuilds, "success")
-	var lastSuccess *time.Time
-	// loop over all successful build summaries
-	for _, b := range filteredBuilds {
-		// if StoppedAt is not set, skip the summary
-		if b.StoppedAt == nil {
+	return s.RunDuration()
+}
+
+// timedOut ... returns true if the run was stopped by the runtimeout of ctx,
+// rather than by parent, the context of the run before the runtimeout
+func timedOut(parent context.Context, ctx context.Context) bool {
+	return ctx.Err() != nil && parent.Err() == nil
+}
+
+// stopReason ... describes why the run was stopped, either the
+// runtimeout expired or the run was interrupted
+func stopReason(parent context.Context, ctx context.Context) string {
+	if timedOut(parent, ctx) {
+		return "run timed out"
+	}
+	return "interrupted"
+}
+
+// notStarted ... returns the names of the entries that were not started
+// by the run, excluding blank entries and entries completed by an earlier run
+func notStarted(entries []*entry, results []*result, state *runState) []string {
+	started := make(map[string]bool)
+	for _, r := range results {
+		started[r.Name] = true
+	}
+	var names []string
+	for _, e := range entries {
+		if len(e.URL) == 0 || len(e.Name) == 0 || started[e.Name] || state.completed(e) {
 			continue
 		}
-		// if this is the first summary that made it this far
-		// set lastSuccess to the stop_time of this summary
-		if lastSuccess == nil {
-			lastSuccess = b.StoppedAt
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// drainInterval ... the interval between checks for running builds
+var drainInterval = 10 * time.Second
+
+// waitForRunning ... waits until no builds of the project are running,
+// returns an error if builds are still running after timeout, a timeout
+// of zero waits indefinitely
+func waitForRunning(ctx context.Context, client circleci.API, project *circleci.Project, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		running, err := client.RunningBuilds(project, logOutput)
+		if err != nil {
+			return err
+		}
+		if len(running) == 0 {
+			return nil
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			return fmt.Errorf("%d builds still running after %s", len(running), timeout)
+		}
+		infof("Waiting for %d running builds of project %q to finish, the latest is build %d\n", len(running), project.Reponame, running[0].BuildNum)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(drainInterval):
+		}
+	}
+}
+
+// reportStatus ... posts the result of building the entry as a commit status
+// to the built revision, failures are logged since the status is informational
+func reportStatus(gh *githubClient, project *circleci.Project, entry *entry, summary *circleci.BuildSummaryOutput, buildErr error) {
+	if summary == nil || len(summary.Revision) == 0 || project.VCS() != circleci.VCSGitHub {
+		return
+	}
+	state, description := "success", "build succeeded"
+	switch buildErr.(type) {
+	case nil:
+	case *circleci.BuildCanceledError:
+		state, description = "error", "build was canceled"
+	case *circleci.JobTimeoutError, *circleci.InfrastructureFailError:
+		state, description = "error", "build did not complete"
+	case *circleci.QueueTimeoutError:
+		state, description = "error", "build never started"
+	default:
+		state, description = "failure", "build failed"
+		if buildErr == context.Canceled {
+			state, description = "error", "build was interrupted"
+		}
+	}
+	err := gh.postStatus(project, entry.Name, summary, state, description)
+	if err != nil {
+		log.Printf("failed to post commit status of project %q -> %v\n", project.Reponame, err)
+	}
+}
+
+// resolveProject ... follows the project of the entry, unless following is
+// disabled, then returns the matching project visible to the current user
+func resolveProject(client circleci.API, opts *options, entry *entry) (*circleci.Project, error) {
+	p, err := circleci.ProjectFromURL(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(entry.Vcs) > 0 {
+		vcs, err := circleci.ParseVCS(entry.Vcs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vcs for entry: %s -> %v", entry.Name, err)
+		}
+		p.Vcs = vcs.V1()
+	}
+	if !opts.NoFollow {
+		infof("Following project with url: %s\n", entry.URL)
+		err = client.FollowProject(p, logOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to follow project with URL: %s -> %v", entry.URL, err)
+		}
+	}
+
+	infof("Searching for project with url: %s\n", entry.URL)
+	project, err := client.FindProject(logOutput, func(fp *circleci.Project) bool {
+		return fp.VcsURL == p.VcsURL
+	})
+	if err != nil {
+		if _, ok := err.(*circleci.ProjectNotFoundError); ok && opts.NoFollow {
+			return nil, fmt.Errorf("project with URL: %s is not followed and following is disabled", entry.URL)
+		}
+		return nil, err
+	}
+	return project, nil
+}
+
+// projectCache ... contains the projects resolved during a run, keyed by the
+// repository url and vcs of the entry, so that entries building the same
+// project are only followed and searched for once, when persisted the
+// projects resolved by previous runs are not followed or searched for again
+type projectCache map[string]*circleci.Project
+
+// projectCacheFile ... the name of the file within the cache directory
+// the projects resolved by each run are cached in
+const projectCacheFile = "resolved-projects.json"
+
+// loadProjectCache ... returns the projectCache persisted to file, if file is
+// empty, does not exist or cannot be parsed, an empty projectCache is returned,
+// since the cache only avoids requests
+func loadProjectCache(file string) projectCache {
+	pc := projectCache{}
+	if len(file) == 0 {
+		return pc
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return pc
+	}
+	err = json.Unmarshal(data, &pc)
+	if err != nil {
+		log.Printf("ignoring project cache: %s -> %v\n", file, err)
+		return projectCache{}
+	}
+	return pc
+}
+
+// save ... persists the projectCache to file, if file is empty it is not persisted
+func (pc projectCache) save(file string) error {
+	if len(file) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(pc)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0600)
+}
+
+// resolve ... returns the cached project of the entry, if the project is not
+// cached it is resolved using resolveProject and added to the cache
+func (pc projectCache) resolve(client circleci.API, opts *options, entry *entry) (*circleci.Project, error) {
+	key := entry.URL + "|" + entry.Vcs
+	if project, ok := pc[key]; ok {
+		infof("Using previously resolved project %q for url: %s\n", project.Reponame, entry.URL)
+		return project, nil
+	}
+	project, err := resolveProject(client, opts, entry)
+	if err != nil {
+		return nil, err
+	}
+	pc[key] = project
+	return project, nil
+}
+
+// preflight ... confirms the project of every entry exists and can be followed,
+// without triggering any builds, all missing projects are reported together
+func preflight(client circleci.API, opts *options, entries []*entry) error {
+	var failures []string
+	for _, entry := range entries {
+		if len(entry.URL) == 0 || len(entry.Name) == 0 {
+			continue
+		}
+		setLogProject(entry.Name)
+		project, err := resolveProject(client, opts, entry)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", entry.Name, err))
 			continue
 		}
-		// if this summary's stop_time is newer than the value
-		// stored in lastSuccess, update lastSuccess to this value
-		if b.StoppedAt.After(*lastSuccess) {
-			lastSuccess = b.StoppedAt
+		if opts.Refs != nil {
+			err = opts.Refs.verify(project, &circleci.BuildProjectInput{Branch: entry.Branch, Tag: entry.Tag, Revision: entry.Commit})
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", entry.Name, err))
+				continue
+			}
 		}
+		infof("Preflight found project %q for entry %q\n", project.Reponame, entry.Name)
+	}
+	setLogProject("")
+	if len(failures) > 0 {
+		return fmt.Errorf("preflight failed for %d of %d entries:\n%s", len(failures), len(entries), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// defaultBranch ... returns the default branch of the project, or an empty
+// string if it could not be resolved, leaving CircleCI to choose the branch
+func defaultBranch(client circleci.API, project *circleci.Project) string {
+	details, err := client.GetProject(project, logOutput)
+	if err != nil || len(details.VcsInfo.DefaultBranch) == 0 {
+		log.Printf("WARNING: no ref specified and the default branch of project %q could not be resolved -> %v\n", project.Reponame, err)
+		return ""
+	}
+	infof("no ref specified, using default branch %q for project %q\n", details.VcsInfo.DefaultBranch, project.Reponame)
+	return details.VcsInfo.DefaultBranch
+}
+
+func shouldSkip(client circleci.API, project *circleci.Project, input *circleci.BuildProjectInput, skipDays int, skipStatus string) (bool, error) {
+	// this may need to be optimized to accept an 'after' date
+	// so we can stop iterating over old/stale job data
+	rawBuilds, err := client.FindBuildSummaries(project, logOutput, input)
+	if err != nil {
+		return false, err
+	}
+	var lastSuccess *time.Time
+	if last := lastStoppedBuild(filterSkipStatus(rawBuilds, skipStatus)); last != nil {
+		lastSuccess = last.StoppedAt
 	}
 	// if we found the stop_time of at least one successful job
 	if lastSuccess != nil {
@@ -135,15 +754,181 @@ func shouldSkip(client circleci.API, project *circleci.Project, input *circleci.
 	return false, nil
 }
 
-func parseEntries(file string) (entries []*entry, err error) {
-	var f *os.File
-	if _, err = os.Stat(filepath.Clean(file)); os.IsNotExist(err) {
-		return
+// filterSkipStatus ... returns the builds relevant for skipping, those
+// with a workflow status matching skipStatus, or all builds for any, only
+// the latest attempt of builds that were retried is relevant
+func filterSkipStatus(builds []*circleci.BuildSummaryOutput, skipStatus string) []*circleci.BuildSummaryOutput {
+	builds = circleci.FilterBuildSummariesByLatestRetry(builds)
+	switch skipStatus {
+	case skipStatusAny:
+		// any completed build is relevant, builds that
+		// have not stopped are ignored by lastStoppedBuild
+		return builds
+	case "":
+		return circleci.FilterBuildSummariesByWorkflowStatus(builds, defaultSkipStatus)
+	}
+	return circleci.FilterBuildSummariesByWorkflowStatus(builds, skipStatus)
+}
+
+// lastStoppedBuild ... returns the build with the newest stop_time,
+// builds that have not stopped are ignored, nil if no build has stopped
+func lastStoppedBuild(builds []*circleci.BuildSummaryOutput) *circleci.BuildSummaryOutput {
+	var last *circleci.BuildSummaryOutput
+	for _, b := range builds {
+		// if StoppedAt is not set, skip the summary
+		if b.StoppedAt == nil {
+			continue
+		}
+		// keep this summary if it is the first to stop, or
+		// its stop_time is newer than the last one found
+		if last == nil || b.StoppedAt.After(*last.StoppedAt) {
+			last = b
+		}
 	}
-	f, err = os.Open(filepath.Clean(file))
+	return last
+}
+
+// parseEntries ... returns the entries of the build file, file may be a single
+// build file, a directory containing build files with a .json extension, or a
+// glob pattern, entries of multiple build files are concatenated in order of
+// their file names, entry names duplicated across build files return an error
+func parseEntries(file string, strictEnv bool) (entries []*entry, err error) {
+	if file == stdinFile {
+		return decodeEntries(stdin, strictEnv)
+	}
+	files, err := buildFiles(file)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if len(files) == 1 {
+		return parseEntriesFile(files[0], strictEnv)
+	}
+	// the build file each entry name was first found in
+	names := make(map[string]string)
+	for _, f := range files {
+		var fileEntries []*entry
+		fileEntries, err = parseEntriesFile(f, strictEnv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse build file: %s -> %v", f, err)
+		}
+		for _, e := range fileEntries {
+			if other, ok := names[e.Name]; ok && other != f && len(e.Name) > 0 {
+				return nil, fmt.Errorf("duplicate entry name %q in build files: %s and %s", e.Name, other, f)
+			}
+			names[e.Name] = f
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+// buildFiles ... returns the build files matching file, the .json files
+// within a directory, the files matching a glob pattern, or file itself
+func buildFiles(file string) ([]string, error) {
+	pattern := filepath.Clean(file)
+	info, err := os.Stat(pattern)
+	switch {
+	case err == nil && info.IsDir():
+		pattern = filepath.Join(pattern, "*.json")
+	case err == nil:
+		return []string{pattern}, nil
+	case !os.IsNotExist(err) || !strings.ContainsAny(file, "*?["):
+		return nil, err
+	}
+	// filepath.Glob returns the matches sorted by name
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no build files found matching: %s", file)
 	}
-	err = json.NewDecoder(f).Decode(&entries)
+	return files, nil
+}
+
+// parseEntriesFile ... returns the entries of a single build file
+func parseEntriesFile(file string, strictEnv bool) ([]*entry, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := f.Close()
+		if err != nil {
+			log.Printf("failed to close build file: %s -> %v\n", file, err)
+		}
+	}()
+	return decodeEntries(f, strictEnv)
+}
+
+// stdinFile ... the file name that reads the build file from stdin
+const stdinFile = "-"
+
+// stdin ... the reader used when the build file is read from stdin
+var stdin io.Reader = os.Stdin
+
+// decodeEntries ... decodes the JSON formatted entries read from r, after
+// expanding ${VAR} placeholders from the environment, see expandEnv
+func decodeEntries(r io.Reader, strictEnv bool) (entries []*entry, err error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := expandEnv(string(raw), strictEnv)
+	if err != nil {
+		return nil, err
+	}
+	err = json.NewDecoder(strings.NewReader(expanded)).Decode(&entries)
+	if err != nil {
+		return nil, err
+	}
+	err = resolveSlugs(entries)
 	return
 }
+
+// resolveSlugs ... sets the repository url of each entry referencing its
+// project by slug, to the url of the project synthesized from the slug
+func resolveSlugs(entries []*entry) error {
+	for _, e := range entries {
+		if len(e.Slug) == 0 {
+			continue
+		}
+		if len(e.URL) > 0 {
+			return fmt.Errorf("slug cannot be used with repository for entry: %s", e.Name)
+		}
+		p, err := circleci.ProjectFromSlug(e.Slug)
+		if err != nil {
+			return fmt.Errorf("invalid slug for entry: %s -> %v", e.Name, err)
+		}
+		e.URL = p.VcsURL
+	}
+	return nil
+}
+
+// envPattern ... matches an escaped $${ or a ${VAR} placeholder
+var envPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv ... replaces each ${VAR} placeholder in s with the value of the
+// environment variable VAR, escaped for use within a JSON string, $${ is
+// replaced by a literal ${ and any other $ is left untouched, when strict is
+// true, placeholders for undefined variables return an error
+func expandEnv(s string, strict bool) (string, error) {
+	var missing []string
+	expanded := envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "$${" {
+			return "${"
+		}
+		name := m[2 : len(m)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok && strict {
+			missing = append(missing, name)
+		}
+		// escape the value for JSON, trimming the surrounding quotes
+		b, _ := json.Marshal(value)
+		return string(b[1 : len(b)-1])
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined environment variables in build file: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,7 +18,47 @@ import (
 
 type mockClient struct {
 	circleci.API
-	Project circleci.Project
+	Project  circleci.Project
+	NotFound bool
+	//blocks WaitForProjectBuild until the context is done
+	Hang     bool
+	Canceled *int
+	Built    *int
+	//returned by GetProject, if empty GetProject fails
+	DefaultBranch string
+	//number of times WaitForProjectBuild fails before succeeding
+	Failures *int
+	//returned by WaitForProjectBuild while failing, a timeout if nil
+	Failure error
+	//set to the ID of the workflow canceled by CancelWorkflow
+	CanceledWorkflow *string
+	//number of calls to FindProject
+	Found *int
+	//revision of the build returned by FindBuildSummaries
+	Revision string
+	//number of calls to RunningBuilds that return a running build
+	Running *int
+	//the duration of each build reported by GetBuildSummary
+	BuildMinutes int
+	//number of calls to GetBuildSummary
+	Durations *int
+	//BuildKnownProject fails as if the project is no longer followed
+	Unfollowed bool
+	//set by WithContext
+	ctx context.Context
+}
+
+func (m mockClient) WithContext(ctx context.Context) circleci.API {
+	m.ctx = ctx
+	return m
+}
+
+func (m mockClient) RunningBuilds(p *circleci.Project, w io.Writer) ([]*circleci.BuildSummaryOutput, error) {
+	if m.Running == nil || *m.Running == 0 {
+		return nil, nil
+	}
+	*m.Running--
+	return []*circleci.BuildSummaryOutput{{BuildNum: 41, Lifecycle: "running"}}, nil
 }
 
 func (m mockClient) FollowProject(p *circleci.Project, w io.Writer) error {
@@ -20,6 +66,12 @@ func (m mockClient) FollowProject(p *circleci.Project, w io.Writer) error {
 }
 
 func (m mockClient) FindProject(w io.Writer, fn func(*circleci.Project) bool) (*circleci.Project, error) {
+	if m.Found != nil {
+		*m.Found++
+	}
+	if m.NotFound {
+		return nil, &circleci.ProjectNotFoundError{Message: "failed to locate a project using the given matcher"}
+	}
 	return &m.Project, nil
 }
 
@@ -32,6 +84,7 @@ func (m mockClient) FindBuildSummaries(p *circleci.Project, w io.Writer, in *cir
 		Reponame:  m.Project.Reponame,
 		StoppedAt: &buildTime,
 		Status:    "success",
+		Revision:  m.Revision,
 		Workflow:  &circleci.BuildWorkflow{WorkflowID: "test"},
 	}}
 	return resp, nil
@@ -39,6 +92,9 @@ func (m mockClient) FindBuildSummaries(p *circleci.Project, w io.Writer, in *cir
 
 // nolint: gomnd
 func (m mockClient) BuildProject(p *circleci.Project, w io.Writer, in *circleci.BuildProjectInput, _ time.Duration) (*circleci.BuildSummaryOutput, error) {
+	if m.Built != nil {
+		*m.Built++
+	}
 	resp := &circleci.BuildSummaryOutput{
 		BuildNum: 42,
 		Username: m.Project.Username,
@@ -47,6 +103,18 @@ func (m mockClient) BuildProject(p *circleci.Project, w io.Writer, in *circleci.
 	return resp, nil
 }
 
+func (m mockClient) BuildKnownProject(p *circleci.Project, w io.Writer, in *circleci.BuildProjectInput, d time.Duration) (*circleci.BuildSummaryOutput, error) {
+	if m.Unfollowed {
+		return nil, &circleci.ProjectNotFollowedError{Message: "project was not found"}
+	}
+	return m.BuildProject(p, w, in, d)
+}
+
+// nolint: gomnd
+func (m mockClient) TriggerOnly(p *circleci.Project, w io.Writer, in *circleci.BuildProjectInput) (*circleci.Pipeline, error) {
+	return &circleci.Pipeline{ID: "test", Number: 42}, nil
+}
+
 func (m mockClient) WaitForProjectBuild(
 	p *circleci.Project,
 	w io.Writer,
@@ -55,9 +123,44 @@ func (m mockClient) WaitForProjectBuild(
 	_ time.Duration,
 	_ time.Duration,
 	_ bool) error {
+	if m.Hang {
+		<-m.ctx.Done()
+		return m.ctx.Err()
+	}
+	if m.Failures != nil && *m.Failures > 0 {
+		*m.Failures--
+		if m.Failure != nil {
+			return m.Failure
+		}
+		return &circleci.JobTimeoutError{Message: "job timeout exceeded"}
+	}
 	return nil
 }
 
+// nolint: gomnd
+func (m mockClient) GetBuildSummary(p *circleci.Project, w io.Writer, buildNum int) (*circleci.BuildSummaryOutput, error) {
+	if m.Durations != nil {
+		*m.Durations++
+	}
+	stopped := time.Now()
+	started := stopped.Add(-time.Duration(m.BuildMinutes) * time.Minute)
+	return &circleci.BuildSummaryOutput{BuildNum: buildNum, StartTime: &started, StoppedAt: &stopped}, nil
+}
+
+func (m mockClient) GetProject(p *circleci.Project, w io.Writer) (*circleci.ProjectDetails, error) {
+	if len(m.DefaultBranch) == 0 {
+		return nil, errors.New("test error")
+	}
+	return &circleci.ProjectDetails{VcsInfo: circleci.ProjectVcsInfo{DefaultBranch: m.DefaultBranch}}, nil
+}
+
+func (m mockClient) CancelBuild(p *circleci.Project, w io.Writer, buildNum int) (*circleci.Build, error) {
+	if m.Canceled != nil {
+		*m.Canceled = buildNum
+	}
+	return &circleci.Build{}, nil
+}
+
 //nolint: gomnd
 func TestParseEntries(t *testing.T) {
 	tests := []struct {
@@ -104,13 +207,35 @@ func TestParseEntries(t *testing.T) {
 					Commit:         "test000002",
 					ContinueOnFail: false}},
 			ExpectLength: 2,
+		},
+		{
+			Name:         "directory",
+			File:         "test_data/buildfiles",
+			ExpectLength: 3,
+		},
+		{
+			Name:         "glob",
+			File:         "test_data/buildfiles/team-*.json",
+			ExpectLength: 3,
+		},
+		{
+			Name: "glob without matches",
+			File: "test_data/buildfiles/*.yaml",
+			Err:  errors.New("no build files found matching: test_data/buildfiles/*.yaml"),
+		},
+		{
+			Name: "duplicate names",
+			File: "test_data/duplicates",
+			Err:  errors.New(`duplicate entry name "test2" in build files: test_data/duplicates/team-a.json and test_data/duplicates/team-b.json`),
 		}}
 	for _, st := range tests {
 		tc := st
 		t.Run(tc.Name, func(t *testing.T) {
-			got, err := parseEntries(tc.File)
+			got, err := parseEntries(tc.File, false)
 			if err == nil && tc.Err != nil {
 				t.Errorf("parseEntries() failed: expected error %v (%T)\nGot: %v (%T)\n", tc.Err, tc.Err, err, err)
+			} else if err != nil && tc.Err != nil && !strings.Contains(err.Error(), tc.Err.Error()) {
+				t.Errorf("parseEntries() failed: expected error %v\nGot: %v\n", tc.Err, err)
 			} else if err != nil && tc.Err == nil {
 				t.Errorf("parseEntries() failed: did not expect error %v (%T)\n", err, err)
 			}
@@ -122,20 +247,199 @@ func TestParseEntries(t *testing.T) {
 }
 
 func TestRunBuilds(t *testing.T) {
-	client := mockClient{
-		Project: circleci.Project{
-			Username: "tester",
-			Reponame: "github.com/org/test1",
-			Vcs:      "test",
-			VcsURL:   "test",
-		}}
-	entries, err := parseEntries("test_data/test.json")
+	project := circleci.Project{
+		Username: "tester",
+		Reponame: "github.com/org/test1",
+		Vcs:      "test",
+		VcsURL:   "test",
+	}
+	entries, err := parseEntries("test_data/test.json", false)
 	if err != nil {
 		t.Fatalf("RunBuilds() failed: %v", err)
 	}
-	err = runBuilds(client, 90, 1, false, entries)
+	tt := map[string]struct {
+		client  mockClient
+		opts    options
+		timeout time.Duration
+		update  func(*entry)
+		builds  int
+		//number of builds whose duration is requested
+		durations int
+		expected  string
+	}{
+		"follow": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 1},
+			builds: 2,
+		},
+		"drain running": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 1, DrainRunning: true},
+			builds: 2,
+		},
+		"within max minutes": {
+			client:    mockClient{Project: project},
+			opts:      options{JobTimeout: 90, SkipDays: 1, MaxMinutes: 1},
+			builds:    2,
+			durations: 2,
+		},
+		"max minutes exceeded": {
+			client:    mockClient{Project: project, BuildMinutes: 2},
+			opts:      options{JobTimeout: 90, SkipDays: 1, MaxMinutes: 1},
+			builds:    1,
+			durations: 1,
+		},
+		"nowait": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 1, NoWait: true},
+		},
+		"nofollow with followed project": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 1, NoFollow: true},
+			builds: 2,
+		},
+		"nofollow with unfollowed project": {
+			client:   mockClient{Project: project, NotFound: true},
+			opts:     options{JobTimeout: 90, SkipDays: 1, NoFollow: true},
+			expected: "project with URL: https://github.com/org/test1 is not followed and following is disabled",
+		},
+		"skip previously built": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 3},
+		},
+		"fail on skip": {
+			client:   mockClient{Project: project},
+			opts:     options{JobTimeout: 90, SkipDays: 3, FailOnSkip: true},
+			expected: "2 entries were skipped and failonskip is set, use noskip to build them: test1, test2",
+		},
+		"fail on skip with nothing skipped": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 1, FailOnSkip: true},
+			builds: 2,
+		},
+		"force build overrides skip": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 3},
+			update: func(e *entry) { e.ForceBuild = true },
+			builds: 2,
+		},
+		"vcs override": {
+			client: mockClient{Project: project},
+			opts:   options{JobTimeout: 90, SkipDays: 1},
+			update: func(e *entry) { e.Vcs = "bitbucket" },
+			builds: 2,
+		},
+		"invalid vcs override": {
+			client:   mockClient{Project: project},
+			opts:     options{JobTimeout: 90, SkipDays: 1},
+			update:   func(e *entry) { e.Vcs = "gitlab" },
+			expected: `invalid vcs for entry: test1 -> unsupported version control system: "gitlab", must be one of github (gh) or bitbucket (bb)`,
+		},
+		"interrupted before building": {
+			client:   mockClient{Project: project},
+			opts:     options{JobTimeout: 90, SkipDays: 1},
+			timeout:  -1,
+			expected: "interrupted before building entry: test1 -> context deadline exceeded",
+		},
+		"interrupted while building": {
+			client:   mockClient{Project: project, Hang: true},
+			opts:     options{JobTimeout: 90, SkipDays: 1, CancelOnInterrupt: true},
+			timeout:  100 * time.Millisecond,
+			builds:   1,
+			expected: "interrupted while building project: github.com/org/test1 -> context deadline exceeded",
+		},
+		"run timeout while building": {
+			client:   mockClient{Project: project, Hang: true},
+			opts:     options{JobTimeout: 90, SkipDays: 1, RunTimeout: 100 * time.Millisecond},
+			builds:   1,
+			expected: "run timed out while building project: github.com/org/test1 -> context deadline exceeded",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			var canceled, built, durations int
+			tc.client.Canceled = &canceled
+			tc.client.Built = &built
+			tc.client.Durations = &durations
+			entries := entries
+			if tc.update != nil {
+				updated := make([]*entry, 0, len(entries))
+				for _, e := range entries {
+					e := *e
+					tc.update(&e)
+					updated = append(updated, &e)
+				}
+				entries = updated
+			}
+			err := runBuilds(ctx, tc.client, &tc.opts, entries)
+			if tc.expected == "" {
+				if err != nil {
+					t.Fatalf("RunBuilds() failed: %v", err)
+				}
+			} else if err == nil || err.Error() != tc.expected {
+				t.Fatalf("RunBuilds() failed: expected error %q\nGot: %v", tc.expected, err)
+			}
+			if tc.opts.CancelOnInterrupt && canceled != 42 {
+				t.Fatalf("RunBuilds() failed: expected build 42 to be canceled\nGot: %d", canceled)
+			}
+			if tc.builds != built {
+				t.Fatalf("RunBuilds() failed: expected %d builds\nGot: %d", tc.builds, built)
+			}
+			// the durations are only requested when maxminutes is set
+			if tc.durations != durations {
+				t.Fatalf("RunBuilds() failed: expected the duration of %d builds to be requested\nGot: %d", tc.durations, durations)
+			}
+		})
+	}
+}
+
+// nolint: gomnd
+func TestFilterSkipStatus(t *testing.T) {
+	stopped := time.Now()
+	retryOf := 42
+	builds := []*circleci.BuildSummaryOutput{{
+		BuildNum:  43,
+		Status:    "failed",
+		RetryOf:   &retryOf,
+		StoppedAt: &stopped,
+		Workflow:  &circleci.BuildWorkflow{WorkflowID: "retry"},
+	}, {
+		BuildNum:  42,
+		Status:    "success",
+		StoppedAt: &stopped,
+		Workflow:  &circleci.BuildWorkflow{WorkflowID: "original"},
+	}}
+	// the successful build was retried and the retry failed
+	if actual := filterSkipStatus(builds, defaultSkipStatus); len(actual) != 0 {
+		t.Errorf("filterSkipStatus() failed: expected no builds, got: %d", len(actual))
+	}
+	if actual := filterSkipStatus(builds, skipStatusAny); len(actual) != 1 || actual[0].BuildNum != 43 {
+		t.Errorf("filterSkipStatus() failed: expected build 43, got: %v", actual)
+	}
+}
+
+func TestWaitForRunning(t *testing.T) {
+	drainInterval = time.Millisecond
+	project := &circleci.Project{Reponame: "test1"}
+	running := 2
+	err := waitForRunning(context.Background(), mockClient{Running: &running}, project, time.Minute)
 	if err != nil {
-		t.Fatalf("RunBuilds() failed: %v", err)
+		t.Fatalf("waitForRunning() failed: %v", err)
+	}
+	if running != 0 {
+		t.Errorf("waitForRunning() failed: expected running builds to drain, %d remaining", running)
+	}
+	running = 5
+	err = waitForRunning(context.Background(), mockClient{Running: &running}, project, time.Nanosecond)
+	if err == nil || err.Error() != "1 builds still running after 1ns" {
+		t.Errorf("waitForRunning() failed: expected timeout, got: %v", err)
 	}
 }
 
@@ -150,9 +454,10 @@ func TestShouldSkip(t *testing.T) {
 	client := mockClient{Project: project}
 	input := &circleci.BuildProjectInput{}
 	tests := []struct {
-		Name     string
-		SkipDays int
-		Expect   bool
+		Name       string
+		SkipDays   int
+		SkipStatus string
+		Expect     bool
 	}{{
 		Name:     "always skip if successful",
 		SkipDays: -1,
@@ -165,11 +470,21 @@ func TestShouldSkip(t *testing.T) {
 		Name:     "skip days greater than last success",
 		SkipDays: 3,
 		Expect:   true,
+	}, {
+		Name:       "do not skip if not failed",
+		SkipDays:   3,
+		SkipStatus: "failed",
+		Expect:     false,
+	}, {
+		Name:       "skip any completed build",
+		SkipDays:   3,
+		SkipStatus: "any",
+		Expect:     true,
 	}}
 	for _, st := range tests {
 		tc := st
 		t.Run(tc.Name, func(t *testing.T) {
-			got, err := shouldSkip(&client, &project, input, tc.SkipDays)
+			got, err := shouldSkip(&client, &project, input, tc.SkipDays, tc.SkipStatus)
 			if err != nil {
 				t.Errorf("shouldSkip() failed: %v\n", err)
 			}
@@ -179,3 +494,471 @@ func TestShouldSkip(t *testing.T) {
 		})
 	}
 }
+
+func TestPrintSummary(t *testing.T) {
+	var buf bytes.Buffer
+	printSummary(&buf, []*result{{
+		Name:     "test1",
+		Project:  "test1",
+		Status:   statusSuccess,
+		BuildNum: 42,
+		Duration: 90 * time.Second,
+	}, {
+		Name:    "test2",
+		Project: "test2-long-name",
+		Status:  statusSkipped,
+		Skipped: true,
+	}})
+	expected := `PROJECT          STATUS   BUILD #  DURATION  SKIPPED
+test1            success  42       1m30s     false
+test2-long-name  skipped  -        0s        true
+`
+	if buf.String() != expected {
+		t.Errorf("printSummary() failed: Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestNotStarted(t *testing.T) {
+	entries := []*entry{
+		{Name: "test1", URL: "https://github.com/org/test1"},
+		{Name: "test2", URL: "https://github.com/org/test2"},
+		{Name: "test3", URL: "https://github.com/org/test3"},
+		{Name: "test4", URL: "https://github.com/org/test4"},
+		{},
+	}
+	state := &runState{Completed: []string{entries[0].key()}}
+	actual := notStarted(entries, []*result{{Name: "test2", Status: statusTimedOut}}, state)
+	expected := []string{"test3", "test4"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("notStarted() failed: expected: %v, got: %v", expected, actual)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeJUnit(&buf, []*result{{
+		Name:     "test1",
+		Project:  "test1",
+		Status:   statusSuccess,
+		BuildNum: 42,
+		Duration: 90 * time.Second,
+	}, {
+		Name:    "test2",
+		Project: "test2",
+		Status:  statusSkipped,
+		Skipped: true,
+	}, {
+		Name:     "test3",
+		Project:  "test3",
+		Status:   statusFailed,
+		Duration: 1500 * time.Millisecond,
+		Err:      "build failed: <exit 1>",
+	}}, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("writeJUnit() failed: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="grace-circleci-builder" tests="3" failures="1" skipped="1" time="120.000">
+  <testcase name="test1" classname="test1" time="90.000"></testcase>
+  <testcase name="test2" classname="test2" time="0.000">
+    <skipped message="skipped"></skipped>
+  </testcase>
+  <testcase name="test3" classname="test3" time="1.500">
+    <failure message="failed">build failed: &lt;exit 1&gt;</failure>
+  </testcase>
+</testsuite>
+`
+	if buf.String() != expected {
+		t.Errorf("writeJUnit() failed: Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestEntryParameters(t *testing.T) {
+	tt := map[string]struct {
+		entry    entry
+		expected map[string]interface{}
+		err      string
+	}{
+		"no workflows": {
+			entry:    entry{Parameters: map[string]interface{}{"parallelism": 4}},
+			expected: map[string]interface{}{"parallelism": 4},
+		},
+		"default parameter": {
+			entry:    entry{Workflows: []string{"deploy", "test"}},
+			expected: map[string]interface{}{"run_deploy": true, "run_test": true},
+		},
+		"custom parameter": {
+			entry:    entry{Workflows: []string{"deploy"}, WorkflowParameter: "%s-enabled"},
+			expected: map[string]interface{}{"deploy-enabled": true},
+		},
+		"explicit parameter wins": {
+			entry:    entry{Workflows: []string{"deploy"}, Parameters: map[string]interface{}{"run_deploy": false}},
+			expected: map[string]interface{}{"run_deploy": false},
+		},
+		"invalid parameter": {
+			entry: entry{Workflows: []string{"deploy"}, WorkflowParameter: "deploy"},
+			err:   `workflow_parameter must contain %s exactly once: "deploy"`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := tc.entry.parameters()
+			if len(tc.err) > 0 {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("parameters() failed: Expected error: %q\nGot: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parameters() failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("parameters() failed: Expected: %v\nGot: %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpandCommits(t *testing.T) {
+	tt := map[string]struct {
+		entries  []*entry
+		expected []*entry
+		err      string
+	}{
+		"no commits": {
+			entries:  []*entry{{Name: "test1", Branch: "master"}},
+			expected: []*entry{{Name: "test1", Branch: "master"}},
+		},
+		"commits": {
+			entries: []*entry{
+				{Name: "test1", Branch: "master", Commits: []string{"d8cbe5e2df067ba5a7eba66376911b064b48a4bf", "abc"}},
+				{Name: "test2", Tag: "v0.1"},
+			},
+			expected: []*entry{
+				{Name: "test1@d8cbe5e", Branch: "master", Commit: "d8cbe5e2df067ba5a7eba66376911b064b48a4bf"},
+				{Name: "test1@abc", Branch: "master", Commit: "abc"},
+				{Name: "test2", Tag: "v0.1"},
+			},
+		},
+		"commits with tag": {
+			entries: []*entry{{Name: "test1", Tag: "v0.1", Commits: []string{"abc"}}},
+			err:     "commits cannot be used with commit, tag or pull_request_merge for entry: test1",
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := expandCommits(tc.entries)
+			if len(tc.err) > 0 {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expandCommits() failed: Expected error: %q\nGot: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandCommits() failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expandCommits() failed: Expected: %+v\nGot: %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func (m mockClient) CancelWorkflow(workflowID string, w io.Writer) error {
+	*m.CanceledWorkflow = workflowID
+	return nil
+}
+
+func TestCancelBuild(t *testing.T) {
+	project := &circleci.Project{Reponame: "test1"}
+	canceled, canceledWorkflow := 0, ""
+	client := mockClient{Canceled: &canceled, CanceledWorkflow: &canceledWorkflow}
+
+	cancelBuild(client, os.Stdout, project, &circleci.BuildSummaryOutput{BuildNum: 42, Workflow: &circleci.BuildWorkflow{WorkflowID: "test"}})
+	if canceled != 0 || canceledWorkflow != "test" {
+		t.Errorf("cancelBuild() failed: expected workflow test to be canceled, got build: %d, workflow: %q", canceled, canceledWorkflow)
+	}
+
+	canceledWorkflow = ""
+	cancelBuild(client, os.Stdout, project, &circleci.BuildSummaryOutput{BuildNum: 42})
+	if canceled != 42 || canceledWorkflow != "" {
+		t.Errorf("cancelBuild() failed: expected build 42 to be canceled, got build: %d, workflow: %q", canceled, canceledWorkflow)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestEntryBuildRetries(t *testing.T) {
+	project := &circleci.Project{Reponame: "test1"}
+	infra := &circleci.InfrastructureFailError{Message: "infrastructure failure"}
+	tt := map[string]struct {
+		failures int
+		failure  error
+		entry    entry
+		opts     options
+		builds   int
+		err      bool
+	}{
+		"no retries":              {failures: 1, builds: 1, err: true},
+		"retried":                 {failures: 2, opts: options{TimeoutRetries: 2}, builds: 3},
+		"retries exhausted":       {failures: 3, opts: options{TimeoutRetries: 2}, builds: 3, err: true},
+		"entry overrides":         {failures: 1, entry: entry{TimeoutRetries: intPtr(0)}, opts: options{TimeoutRetries: 2}, builds: 1, err: true},
+		"retries are limited":     {failures: 10, entry: entry{TimeoutRetries: intPtr(100)}, builds: maxEntryRetries + 1, err: true},
+		"retry count timeout":     {failures: 2, entry: entry{RetryCount: 2}, builds: 3},
+		"retry count infra":       {failures: 1, failure: infra, entry: entry{RetryCount: 1, RetryBackoffSeconds: 1}, builds: 2},
+		"infra without count":     {failures: 1, failure: infra, opts: options{TimeoutRetries: 2}, builds: 1, err: true},
+		"build failure not retry": {failures: 1, failure: errors.New("build failed"), entry: entry{RetryCount: 2}, builds: 1, err: true},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			built, failures := 0, tc.failures
+			client := mockClient{Project: *project, Built: &built, Failures: &failures, Failure: tc.failure}
+			e := tc.entry
+			_, err := e.Build(context.Background(), client, os.Stdout, project, &circleci.BuildProjectInput{}, &tc.opts)
+			if tc.err != (err != nil) {
+				t.Errorf("Build() failed: expected error: %t, got: %v", tc.err, err)
+			}
+			if built != tc.builds {
+				t.Errorf("Build() failed: expected %d builds, got: %d", tc.builds, built)
+			}
+		})
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	project := &circleci.Project{Reponame: "test1"}
+	if actual := defaultBranch(mockClient{DefaultBranch: "main"}, project); actual != "main" {
+		t.Errorf("defaultBranch() failed: Expected: %q\nGot: %q", "main", actual)
+	}
+	if actual := defaultBranch(mockClient{}, project); actual != "" {
+		t.Errorf("defaultBranch() failed: Expected: %q\nGot: %q", "", actual)
+	}
+}
+
+func TestParseEntriesStdin(t *testing.T) {
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = strings.NewReader(`[{"name":"test1","repository":"https://github.com/org/test1","branch":"master"}]`)
+	got, err := parseEntries("-", false)
+	if err != nil {
+		t.Fatalf("parseEntries() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "test1" || got[0].Branch != "master" {
+		t.Errorf("parseEntries() failed: unexpected entries: %v", got)
+	}
+}
+
+func TestResolveSlugs(t *testing.T) {
+	tt := map[string]struct {
+		entry       *entry
+		expectedURL string
+		expectedErr string
+	}{
+		"slug":       {entry: &entry{Name: "test1", Slug: "gh/org/test1"}, expectedURL: "https://github.com/org/test1"},
+		"repository": {entry: &entry{Name: "test1", URL: "https://github.com/org/test1"}, expectedURL: "https://github.com/org/test1"},
+		"both": {
+			entry:       &entry{Name: "test1", URL: "https://github.com/org/test1", Slug: "gh/org/test1"},
+			expectedErr: "slug cannot be used with repository for entry: test1",
+		},
+		"invalid": {
+			entry:       &entry{Name: "test1", Slug: "org/test1"},
+			expectedErr: `invalid slug for entry: test1 -> slug not properly formatted: "org/test1", must be vcs/org/repo`,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			err := resolveSlugs([]*entry{tc.entry})
+			if len(tc.expectedErr) > 0 {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("resolveSlugs() failed: expected error: %s, got: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveSlugs() failed: %v", err)
+			}
+			if tc.entry.URL != tc.expectedURL {
+				t.Errorf("resolveSlugs() failed: expected url: %s, got: %s", tc.expectedURL, tc.entry.URL)
+			}
+		})
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("BUILDER_TEST_BRANCH", "release/1.0")
+	os.Setenv("BUILDER_TEST_QUOTE", `say "hi"`)
+	defer os.Unsetenv("BUILDER_TEST_BRANCH")
+	defer os.Unsetenv("BUILDER_TEST_QUOTE")
+	tt := map[string]struct {
+		input    string
+		strict   bool
+		expected string
+		err      string
+	}{
+		"defined":          {input: `"${BUILDER_TEST_BRANCH}"`, expected: `"release/1.0"`},
+		"json escaped":     {input: `"${BUILDER_TEST_QUOTE}"`, expected: `"say \"hi\""`},
+		"undefined":        {input: `"${BUILDER_TEST_MISSING}"`, expected: `""`},
+		"undefined strict": {input: `"${BUILDER_TEST_MISSING}" "${BUILDER_TEST_BRANCH}"`, strict: true, err: "undefined environment variables in build file: BUILDER_TEST_MISSING"},
+		"escaped":          {input: `"$${BUILDER_TEST_BRANCH}"`, expected: `"${BUILDER_TEST_BRANCH}"`},
+		"bare dollar":      {input: `"$BUILDER_TEST_BRANCH $5"`, expected: `"$BUILDER_TEST_BRANCH $5"`},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := expandEnv(tc.input, tc.strict)
+			if len(tc.err) > 0 {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expandEnv() failed: Expected error: %q\nGot: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandEnv() failed: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expandEnv() failed: Expected: %s\nGot: %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProjectCache(t *testing.T) {
+	found := 0
+	client := mockClient{Project: circleci.Project{Reponame: "test1"}, Found: &found}
+	projects := projectCache{}
+	for _, e := range []*entry{
+		{Name: "test1", URL: "https://github.com/org/test1", Branch: "master"},
+		{Name: "test1", URL: "https://github.com/org/test1", Branch: "develop"},
+		{Name: "test1", URL: "https://github.com/org/test1", Vcs: "bitbucket"},
+	} {
+		project, err := projects.resolve(client, &options{}, e)
+		if err != nil {
+			t.Fatalf("resolve() failed: %v", err)
+		}
+		if project.Reponame != "test1" {
+			t.Errorf("resolve() failed: Expected: %q\nGot: %q", "test1", project.Reponame)
+		}
+	}
+	if found != 2 {
+		t.Errorf("resolve() failed: expected 2 projects to be resolved, got: %d", found)
+	}
+}
+
+type durationClient struct {
+	circleci.API
+	summaries []*circleci.BuildSummaryOutput
+	err       error
+}
+
+func (c durationClient) BuildSummary(p *circleci.Project, w io.Writer, in *circleci.BuildSummaryInput) ([]*circleci.BuildSummaryOutput, error) {
+	return c.summaries, c.err
+}
+
+func TestBuildDuration(t *testing.T) {
+	project := &circleci.Project{Reponame: "test1"}
+	start := time.Now()
+	stop := start.Add(3 * time.Minute)
+	workflow := func(id string) *circleci.BuildWorkflow { return &circleci.BuildWorkflow{WorkflowID: id} }
+	summaries := []*circleci.BuildSummaryOutput{
+		{BuildNum: 43, Workflow: workflow("test"), StartTime: &start, StoppedAt: &stop},
+		{BuildNum: 42, Workflow: workflow("test"), StartTime: &start, StoppedAt: &stop},
+		{BuildNum: 41, Workflow: workflow("other"), StartTime: &start, StoppedAt: &stop},
+	}
+	summary := &circleci.BuildSummaryOutput{BuildNum: 42, Workflow: workflow("test")}
+	tt := map[string]struct {
+		client   durationClient
+		summary  *circleci.BuildSummaryOutput
+		expected time.Duration
+	}{
+		"builds of the workflow": {client: durationClient{summaries: summaries}, summary: summary, expected: 6 * time.Minute},
+		"request failed":         {client: durationClient{err: errors.New("test error")}, summary: summary, expected: time.Hour},
+		"not triggered":          {client: durationClient{summaries: summaries}},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual := buildDuration(tc.client, project, tc.summary, time.Hour)
+			if actual != tc.expected {
+				t.Errorf("buildDuration() failed: expected %s, got: %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProjectCachePersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entries, err := parseEntries("test_data/test.json", false)
+	if err != nil {
+		t.Fatalf("parseEntries() failed: %v", err)
+	}
+	opts := &options{JobTimeout: 90, NoSkip: true, ProjectCache: filepath.Join(dir, projectCacheFile)}
+	for run, expected := range []int{2, 0} {
+		found, built := 0, 0
+		client := mockClient{Project: circleci.Project{Reponame: "test1", Username: "org", Vcs: "github"}, Found: &found, Built: &built}
+		err = runBuilds(context.Background(), client, opts, entries)
+		if err != nil {
+			t.Fatalf("runBuilds() failed: %v", err)
+		}
+		if found != expected {
+			t.Errorf("runBuilds() failed: expected %d projects to be resolved by run %d, got: %d", expected, run+1, found)
+		}
+		if built != 2 {
+			t.Errorf("runBuilds() failed: expected 2 builds by run %d, got: %d", run+1, built)
+		}
+	}
+}
+
+func TestEntryBuildUnfollowed(t *testing.T) {
+	project := &circleci.Project{Reponame: "test1"}
+	for _, noFollow := range []bool{false, true} {
+		built := 0
+		client := mockClient{Project: *project, Built: &built, Unfollowed: true}
+		_, err := (&entry{}).Build(context.Background(), client, os.Stdout, project, &circleci.BuildProjectInput{}, &options{NoFollow: noFollow})
+		if noFollow {
+			if _, ok := err.(*circleci.ProjectNotFollowedError); !ok {
+				t.Errorf("Build() failed: expected *circleci.ProjectNotFollowedError when following is disabled, got: %v", err)
+			}
+			continue
+		}
+		if err != nil || built != 1 {
+			t.Errorf("Build() failed: expected the project to be followed and built again, got %d builds -> %v", built, err)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	project := circleci.Project{Reponame: "test1", Username: "org", VcsURL: "https://github.com/org/test1"}
+	entries := []*entry{
+		{Name: "test1", URL: "https://github.com/org/test1"},
+		{Name: "test2", URL: "https://github.com/org/test2", Vcs: "gitlab"},
+	}
+	built := 0
+	err := preflight(mockClient{Project: project, Built: &built}, &options{}, entries)
+	if err == nil || !strings.Contains(err.Error(), "preflight failed for 1 of 2 entries") ||
+		!strings.Contains(err.Error(), "test2: invalid vcs for entry") {
+		t.Errorf("preflight() failed: expected one invalid entry, got: %v", err)
+	}
+
+	err = preflight(mockClient{Project: project, NotFound: true, Built: &built}, &options{}, entries)
+	if err == nil || !strings.Contains(err.Error(), "preflight failed for 2 of 2 entries") ||
+		!strings.Contains(err.Error(), "test1: failed to locate a project") {
+		t.Errorf("preflight() failed: expected missing project, got: %v", err)
+	}
+
+	err = preflight(mockClient{Project: project, Built: &built}, &options{}, entries[:1])
+	if err != nil {
+		t.Errorf("preflight() failed: %v", err)
+	}
+	if built != 0 {
+		t.Errorf("preflight() failed: expected no builds, got: %d", built)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GSA/grace-circleci-builder/circleci"
+)
+
+const (
+	// webhookSignatureHeader ... the header containing the HMAC SHA-256
+	// signature of the body of a GitHub webhook delivery
+	webhookSignatureHeader = "X-Hub-Signature-256"
+	// webhookEventHeader ... the header containing the GitHub event type
+	webhookEventHeader = "X-GitHub-Event"
+	// maxWebhookBody ... the maximum number of bytes read from a webhook delivery
+	maxWebhookBody = 1 << 20
+	// webhookQueueSize ... the number of pushes queued while a build is running
+	webhookQueueSize = 10
+)
+
+// pushEvent ... used internally to represent the body of a GitHub push webhook
+// https://docs.github.com/en/developers/webhooks-and-events/webhook-events-and-payloads#push
+type pushEvent struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Deleted    bool   `json:"deleted"`
+	Repository struct {
+		HTMLURL       string `json:"html_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+}
+
+// webhookHandler ... an http.Handler receiving GitHub push webhooks, the entries
+// of the build file matching each push are queued to be built at the pushed commit
+type webhookHandler struct {
+	secret  []byte
+	entries []*entry
+	queue   chan []*entry
+}
+
+// newWebhookHandler ... returns a *webhookHandler verifying deliveries using secret
+func newWebhookHandler(secret string, entries []*entry) *webhookHandler {
+	return &webhookHandler{
+		secret:  []byte(secret),
+		entries: entries,
+		queue:   make(chan []*entry, webhookQueueSize),
+	}
+}
+
+// ServeHTTP ... verifies the signature of the delivery and queues the entries
+// matching a push, other events are acknowledged without building anything
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !h.verify(body, r.Header.Get(webhookSignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get(webhookEventHeader) != "push" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var push pushEvent
+	err = json.Unmarshal(body, &push)
+	if err != nil {
+		http.Error(w, "invalid push event", http.StatusBadRequest)
+		return
+	}
+	entries := h.match(&push)
+	if len(entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	select {
+	case h.queue <- entries:
+		infof("Received push to %s of %s, queued %d entries\n", push.Ref, push.Repository.HTMLURL, len(entries))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		log.Printf("failed to queue push to %s of %s, too many builds are queued\n", push.Ref, push.Repository.HTMLURL)
+		http.Error(w, "too many builds are queued", http.StatusServiceUnavailable)
+	}
+}
+
+// verify ... returns true if signature is the HMAC SHA-256 of body
+// using the secret of the webhook, in the format sha256=<hex>
+func (h *webhookHandler) verify(body []byte, signature string) bool {
+	const prefix = "sha256="
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	actual, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	_, _ = mac.Write(body)
+	return hmac.Equal(actual, mac.Sum(nil))
+}
+
+// match ... returns a copy of each entry of the pushed repository whose branch
+// or tag matches the pushed ref, set to build the pushed commit, entries without
+// a branch, tag or commit match pushes to the default branch of the repository
+func (h *webhookHandler) match(push *pushEvent) []*entry {
+	if push.Deleted {
+		return nil
+	}
+	pushed, err := circleci.ProjectFromURL(push.Repository.HTMLURL)
+	if err != nil {
+		return nil
+	}
+	branch := strings.TrimPrefix(push.Ref, "refs/heads/")
+	tag := strings.TrimPrefix(push.Ref, "refs/tags/")
+	var matched []*entry
+	for _, e := range h.entries {
+		project, err := circleci.ProjectFromURL(e.URL)
+		if err != nil || !strings.EqualFold(project.Username, pushed.Username) || !strings.EqualFold(project.Reponame, pushed.Reponame) {
+			continue
+		}
+		m := *e
+		switch {
+		case len(e.Commit) > 0 || len(e.Commits) > 0 || e.PullRequestMerge > 0:
+			continue
+		case len(e.Tag) > 0:
+			if tag == push.Ref || e.Tag != tag {
+				continue
+			}
+		case len(e.Branch) > 0:
+			if branch == push.Ref || e.Branch != branch {
+				continue
+			}
+			m.Commit = push.After
+		default:
+			if branch == push.Ref || branch != push.Repository.DefaultBranch {
+				continue
+			}
+			m.Branch, m.Commit = branch, push.After
+		}
+		matched = append(matched, &m)
+	}
+	return matched
+}
+
+// serve ... listens on addr for GitHub push webhooks, building the entries matching
+// each push one push at a time, until ctx is canceled
+func serve(ctx context.Context, addr string, secret string, client circleci.API, opts *options, entries []*entry) error {
+	h := newWebhookHandler(secret, entries)
+	srv := &http.Server{Addr: addr, Handler: h, ReadTimeout: 30 * time.Second, WriteTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := srv.Shutdown(shutdown)
+		if err != nil {
+			log.Printf("failed to stop the webhook server -> %v\n", err)
+		}
+	}()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case queued := <-h.queue:
+				err := runBuilds(ctx, client, opts, queued)
+				if err != nil {
+					log.Printf("failed to build pushed entries -> %v\n", err)
+				}
+			}
+		}
+	}()
+	log.Printf("Listening for GitHub push webhooks on %s\n", addr)
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
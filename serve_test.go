@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sign ... returns the X-Hub-Signature-256 header GitHub sends for body
+func sign(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	entries := []*entry{
+		{Name: "default", URL: "https://github.com/org/test1"},
+		{Name: "feature", URL: "https://github.com/org/test1", Branch: "feature"},
+		{Name: "release", URL: "https://github.com/org/test1", Tag: "v1.0.0"},
+		{Name: "pinned", URL: "https://github.com/org/test1", Commit: "abc123"},
+		{Name: "other", URL: "https://github.com/org/test2"},
+	}
+	push := func(ref string) string {
+		return `{"ref": "` + ref + `", "after": "def456", "repository": {"html_url": "https://github.com/Org/Test1", "default_branch": "master"}}`
+	}
+	tt := map[string]struct {
+		event     string
+		body      string
+		signature string
+		expected  int
+		queued    []*entry
+	}{
+		"default branch": {
+			event:    "push",
+			body:     push("refs/heads/master"),
+			expected: http.StatusAccepted,
+			queued:   []*entry{{Name: "default", URL: "https://github.com/org/test1", Branch: "master", Commit: "def456"}},
+		},
+		"branch": {
+			event:    "push",
+			body:     push("refs/heads/feature"),
+			expected: http.StatusAccepted,
+			queued:   []*entry{{Name: "feature", URL: "https://github.com/org/test1", Branch: "feature", Commit: "def456"}},
+		},
+		"tag": {
+			event:    "push",
+			body:     push("refs/tags/v1.0.0"),
+			expected: http.StatusAccepted,
+			queued:   []*entry{{Name: "release", URL: "https://github.com/org/test1", Tag: "v1.0.0"}},
+		},
+		"no match": {
+			event:    "push",
+			body:     push("refs/heads/other"),
+			expected: http.StatusNoContent,
+		},
+		"deleted": {
+			event:    "push",
+			body:     strings.Replace(push("refs/heads/master"), `"after"`, `"deleted": true, "after"`, 1),
+			expected: http.StatusNoContent,
+		},
+		"ping": {
+			event:    "ping",
+			body:     `{"zen": "Keep it logically awesome."}`,
+			expected: http.StatusNoContent,
+		},
+		"invalid signature": {
+			event:     "push",
+			body:      push("refs/heads/master"),
+			signature: sign("wrong", push("refs/heads/master")),
+			expected:  http.StatusUnauthorized,
+		},
+		"no signature": {
+			event:     "push",
+			body:      push("refs/heads/master"),
+			signature: "none",
+			expected:  http.StatusUnauthorized,
+		},
+	}
+	for name, tc := range tt {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			h := newWebhookHandler("secret", entries)
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(webhookEventHeader, tc.event)
+			switch tc.signature {
+			case "":
+				req.Header.Set(webhookSignatureHeader, sign("secret", tc.body))
+			case "none":
+			default:
+				req.Header.Set(webhookSignatureHeader, tc.signature)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.expected {
+				t.Fatalf("ServeHTTP() failed: expected status: %d, got: %d", tc.expected, rec.Code)
+			}
+			var actual []*entry
+			select {
+			case actual = <-h.queue:
+			default:
+			}
+			if !reflect.DeepEqual(tc.queued, actual) {
+				t.Errorf("ServeHTTP() failed: expected queued: %#v, got: %#v", tc.queued, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// runState ... contains the progress of a run, persisted to a state
+// file so that an interrupted run can be resumed
+type runState struct {
+	//path to the state file, if empty the state is not persisted
+	file string
+	//keys of the entries completed by the run
+	Completed []string `json:"completed"`
+}
+
+// key ... returns the key identifying the entry in the state file,
+// the ref is included so that changed entries are built again
+func (e *entry) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s", e.Name, e.URL, e.Branch, e.Tag, e.Commit)
+}
+
+// loadState ... returns the *runState persisted to file, if file
+// is empty or does not exist, an empty *runState is returned
+func loadState(file string) (*runState, error) {
+	state := &runState{file: file}
+	if len(file) == 0 {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %s -> %v", file, err)
+	}
+	return state, nil
+}
+
+// completed ... returns true if the entry was completed by the run
+func (s *runState) completed(e *entry) bool {
+	key := e.key()
+	for _, k := range s.Completed {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// complete ... marks the entry as completed and persists the state
+func (s *runState) complete(e *entry) error {
+	if s.completed(e) {
+		return nil
+	}
+	s.Completed = append(s.Completed, e.key())
+	return s.save()
+}
+
+// save ... persists the state, the state file is replaced atomically
+// so that it is never left partially written if the process dies
+func (s *runState) save() error {
+	if len(s.file) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.file), filepath.Base(s.file)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.file)
+}
+
+// clear ... removes the state file, called once the run completes
+func (s *runState) clear() error {
+	if len(s.file) == 0 {
+		return nil
+	}
+	err := os.Remove(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
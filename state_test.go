@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "state.json")
+	e1 := &entry{Name: "test1", URL: "https://github.com/org/test1", Branch: "master"}
+	e2 := &entry{Name: "test2", URL: "https://github.com/org/test2", Branch: "master"}
+
+	state, err := loadState(file)
+	if err != nil {
+		t.Fatalf("loadState() failed: %v", err)
+	}
+	if state.completed(e1) {
+		t.Fatal("completed() failed: expected new state to have no completed entries")
+	}
+	err = state.complete(e1)
+	if err != nil {
+		t.Fatalf("complete() failed: %v", err)
+	}
+
+	state, err = loadState(file)
+	if err != nil {
+		t.Fatalf("loadState() failed: %v", err)
+	}
+	if !state.completed(e1) || state.completed(e2) {
+		t.Fatalf("completed() failed: expected only test1 to be completed, got %v", state.Completed)
+	}
+	changed := *e1
+	changed.Branch = "develop"
+	if state.completed(&changed) {
+		t.Fatal("completed() failed: expected entry with a changed ref to not be completed")
+	}
+
+	err = state.clear()
+	if err != nil {
+		t.Fatalf("clear() failed: %v", err)
+	}
+	if _, err = os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("clear() failed: expected state file to be removed, got %v", err)
+	}
+}
+
+func TestRunBuildsResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "state.json")
+	entries, err := parseEntries("test_data/test.json", false)
+	if err != nil {
+		t.Fatalf("parseEntries() failed: %v", err)
+	}
+	state := &runState{file: file}
+	err = state.complete(entries[0])
+	if err != nil {
+		t.Fatalf("complete() failed: %v", err)
+	}
+
+	var built int
+	client := mockClient{Built: &built}
+	err = runBuilds(context.Background(), client, &options{JobTimeout: 90, SkipDays: 1, StateFile: file}, entries)
+	if err != nil {
+		t.Fatalf("runBuilds() failed: %v", err)
+	}
+	if built != 1 {
+		t.Errorf("runBuilds() failed: expected only the incomplete entry to be built, got %d builds", built)
+	}
+	if _, err = os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("runBuilds() failed: expected state file to be removed after the run completed, got %v", err)
+	}
+}